@@ -1,10 +1,21 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
+	buildcmd "github.com/mouad4949/DAAB/internal/build"
+	configcmd "github.com/mouad4949/DAAB/internal/config"
+	deploycmd "github.com/mouad4949/DAAB/internal/deploy"
+	detectcmd "github.com/mouad4949/DAAB/internal/detect"
+	generatecmd "github.com/mouad4949/DAAB/internal/generate"
+	graphcmd "github.com/mouad4949/DAAB/internal/graph"
 	initcmd "github.com/mouad4949/DAAB/internal/init"
+	pushcmd "github.com/mouad4949/DAAB/internal/push"
+	servicecmd "github.com/mouad4949/DAAB/internal/service"
+	statuscmd "github.com/mouad4949/DAAB/internal/status"
+	upcmd "github.com/mouad4949/DAAB/internal/up"
 
 	"github.com/spf13/cobra"
 )
@@ -22,12 +33,35 @@ func main() {
 		Long: `DAAB is a CLI tool that automates deployment workflows.
 It helps you deploy your applications to the cloud with zero friction.`,
 		Version: fmt.Sprintf("%s (commit: %s, built: %s)", version, commit, date),
+		// Errors are printed below, once
+		SilenceErrors: true,
 	}
 
+	rootCmd.PersistentFlags().String("env", "", "Environment overlay to use (e.g. development, staging, production)")
+
 	rootCmd.AddCommand(initcmd.NewInitCommand())
+	rootCmd.AddCommand(configcmd.NewConfigCommand())
+	rootCmd.AddCommand(statuscmd.NewStatusCommand())
+	rootCmd.AddCommand(detectcmd.NewDetectCommand())
+	rootCmd.AddCommand(generatecmd.NewGenerateCommand())
+	rootCmd.AddCommand(servicecmd.NewServiceCommand())
+	rootCmd.AddCommand(graphcmd.NewGraphCommand())
+	rootCmd.AddCommand(buildcmd.NewBuildCommand())
+	rootCmd.AddCommand(pushcmd.NewPushCommand())
+	rootCmd.AddCommand(deploycmd.NewDeployCommand())
+	rootCmd.AddCommand(deploycmd.NewHistoryCommand())
+	rootCmd.AddCommand(deploycmd.NewRollbackCommand())
+	rootCmd.AddCommand(upcmd.NewUpCommand())
+	rootCmd.AddCommand(upcmd.NewDownCommand())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+
+		// Commands such as detect use dedicated exit codes for expected failures
+		var exitErr interface{ ExitCode() int }
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
 		os.Exit(1)
 	}
 }
@@ -1,3 +1,17 @@
 module github.com/mouad4949/DAAB
 
 go 1.25.1
+
+require (
+	filippo.io/age v1.2.1
+	github.com/spf13/cobra v1.10.2
+	golang.org/x/term v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/spf13/pflag v1.0.9 // indirect
+	golang.org/x/crypto v0.24.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+)
@@ -0,0 +1,125 @@
+package buildcmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mouad4949/DAAB/internal/fsutil"
+	"github.com/mouad4949/DAAB/pkg/build"
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+	"github.com/spf13/cobra"
+)
+
+type BuildFlags struct {
+	ProjectPath string
+	Env         string
+	Services    []string
+	Builder     string
+	Concurrency int
+	Force       bool
+	Tag         string
+}
+
+func NewBuildCommand() *cobra.Command {
+	flags := &BuildFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "build",
+		Short: "Build the container images of your project",
+		Long: `Build an image for the project (monolith) or for every service (microservices) with
+the Dockerfile of its folder, or a generated one when there is none. Images are tagged
+<container_registry>/<name>:<git-sha>.
+
+Services whose folder did not change since their last build are skipped, the builds
+are recorded in .init/builds.yaml. The builder backend is picked with --builder:
+docker, buildkit (buildctl, rootless buildkitd), buildah (daemonless, no privileges) or
+oci. The oci builder needs no container tool at all: it compiles go applications (or
+copies static sites) on the host and writes a reproducible OCI image layout to the
+.init/oci folder of the service, ready to be pushed or loaded, e.g. with
+'skopeo copy oci:<service>/.init/oci docker-daemon:<image>'.`,
+		Example: `  daab build
+  daab build --service users-api --service web
+  daab build --builder buildah --concurrency 2
+  daab build --builder oci --service users-api
+  daab build --env production --force`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags.Env, _ = cmd.Flags().GetString("env")
+			cmd.SilenceUsage = true
+			return runBuild(cmd, flags)
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.ProjectPath, "project-path", ".", "Path to the project directory")
+	cmd.Flags().StringSliceVar(&flags.Services, "service", nil, "Only build these services (repeatable)")
+	cmd.Flags().StringVar(&flags.Builder, "builder", "auto", fmt.Sprintf("Builder backend: auto, %v", build.Builders()))
+	cmd.Flags().IntVar(&flags.Concurrency, "concurrency", runtime.NumCPU(), "Number of images built at the same time")
+	cmd.Flags().BoolVar(&flags.Force, "force", false, "Build every service, even when it did not change")
+	cmd.Flags().StringVar(&flags.Tag, "tag", "", "Tag of the images (default: the git commit)")
+
+	return cmd
+}
+
+func runBuild(cmd *cobra.Command, flags *BuildFlags) error {
+	project, err := configProject.Load(flags.ProjectPath, flags.Env)
+	if err != nil {
+		return err
+	}
+
+	apps, err := build.SelectApps(project, flags.Services)
+	if err != nil {
+		return err
+	}
+
+	builder, err := build.New(flags.Builder)
+	if err != nil {
+		return err
+	}
+
+	state, err := build.LoadState(build.StatePath(flags.ProjectPath))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🔨 Building %d images with %s (concurrency %d)...\n", len(apps), builder.Name(), flags.Concurrency)
+	results := build.BuildAll(cmd.Context(), apps, build.Options{
+		Builder:     builder,
+		Concurrency: flags.Concurrency,
+		Force:       flags.Force,
+		Tag:         flags.Tag,
+		State:       state,
+		Output:      os.Stdout,
+	})
+
+	// Record the successful builds even when others failed
+	data, err := state.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFileAtomic(state.Path(), data, 0644); err != nil {
+		return fmt.Errorf("failed to save build state: %w", err)
+	}
+
+	fmt.Println()
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			failed++
+			fmt.Fprintf(w, "❌ %s\t%v\n", result.App, result.Err)
+		case result.Skipped:
+			fmt.Fprintf(w, "⏭️  %s\t%s (unchanged)\n", result.App, result.Image)
+		default:
+			fmt.Fprintf(w, "✅ %s\t%s (%s)\n", result.App, result.Image, result.Duration.Round(100*time.Millisecond))
+		}
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d images failed to build", failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package cloud
+
+import (
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed regions.yaml
+var regionsYAML []byte
+
+type providerRegions struct {
+	Default string   `yaml:"default"`
+	Regions []string `yaml:"regions"`
+}
+
+var catalogue map[string]providerRegions
+
+func init() {
+	if err := yaml.Unmarshal(regionsYAML, &catalogue); err != nil {
+		panic(fmt.Sprintf("invalid embedded regions catalogue: %v", err))
+	}
+}
+
+// Providers returns the supported cloud providers.
+func Providers() []string {
+	return []string{"aws", "gcp", "azure"}
+}
+
+// Regions returns the known regions of provider, nil for an unknown provider.
+func Regions(provider string) []string {
+	return catalogue[provider].Regions
+}
+
+// DefaultRegion returns the region proposed by default for provider.
+func DefaultRegion(provider string) string {
+	return catalogue[provider].Default
+}
+
+// ValidateRegion checks that region exists for provider and suggests
+// the closest known region on a typo.
+func ValidateRegion(provider, region string) error {
+	regions, ok := catalogue[provider]
+	if !ok {
+		return fmt.Errorf("unknown cloud provider %q (expected one of %s)", provider, strings.Join(Providers(), ", "))
+	}
+	if region == "" {
+		return fmt.Errorf("region cannot be empty")
+	}
+
+	for _, r := range regions.Regions {
+		if r == region {
+			return nil
+		}
+	}
+
+	if suggestion := ClosestRegion(provider, region); suggestion != "" {
+		return fmt.Errorf("unknown %s region %q, did you mean %q?", provider, region, suggestion)
+	}
+	return fmt.Errorf("unknown %s region %q", provider, region)
+}
+
+// SearchRegions returns the regions of provider containing query, ignoring case.
+func SearchRegions(provider, query string) []string {
+	query = strings.ToLower(strings.TrimSpace(query))
+	var matches []string
+	for _, r := range Regions(provider) {
+		if strings.Contains(r, query) {
+			matches = append(matches, r)
+		}
+	}
+	return matches
+}
+
+// ClosestRegion returns the region of provider with the smallest edit distance to region,
+// or "" when nothing is reasonably close.
+func ClosestRegion(provider, region string) string {
+	type candidate struct {
+		region   string
+		distance int
+	}
+
+	var candidates []candidate
+	for _, r := range Regions(provider) {
+		candidates = append(candidates, candidate{r, levenshtein(strings.ToLower(region), r)})
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].distance < candidates[b].distance
+	})
+	if candidates[0].distance > 3 {
+		return ""
+	}
+	return candidates[0].region
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,196 @@
+package cloud
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Registry kinds recognised by ParseRegistry.
+const (
+	RegistryECR        = "ecr"
+	RegistryGAR        = "gar"
+	RegistryGCR        = "gcr"
+	RegistryACR        = "acr"
+	RegistryDockerHub  = "dockerhub"
+	RegistryGHCR       = "ghcr"
+	RegistryQuay       = "quay"
+	RegistrySelfHosted = "self-hosted"
+)
+
+var (
+	ecrHostPattern    = regexp.MustCompile(`^(\d{12})\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com(\.cn)?$`)
+	garHostPattern    = regexp.MustCompile(`^([a-z0-9-]+)-docker\.pkg\.dev$`)
+	gcrHostPattern    = regexp.MustCompile(`^([a-z]+\.)?gcr\.io$`)
+	acrHostPattern    = regexp.MustCompile(`^([a-z0-9]{5,50})\.azurecr\.io$`)
+	hostPattern       = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*(:[0-9]{1,5})?$`)
+	pathPartPattern   = regexp.MustCompile(`^[a-z0-9]+([._-][a-z0-9]+)*$`)
+	acrInvalidPattern = regexp.MustCompile(`[^a-z0-9]`)
+	awsAccountPattern = regexp.MustCompile(`^\d{12}$`)
+	gcpProjectPattern = regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+	acrNamePattern    = regexp.MustCompile(`^[a-zA-Z0-9]{5,50}$`)
+
+	repositoryInvalidPattern = regexp.MustCompile(`[^a-z0-9-]+`)
+)
+
+// Registry is a parsed container registry reference such as
+// 123456789012.dkr.ecr.eu-west-1.amazonaws.com or ghcr.io/my-org.
+type Registry struct {
+	Kind string
+	Host string
+	// Path is the repository prefix under the host (namespace, project/repo, ...)
+	Path string
+	// Region is set for regional cloud registries (ECR, Artifact Registry)
+	Region string
+}
+
+// String returns the registry reference images are tagged under.
+func (r *Registry) String() string {
+	if r.Path == "" {
+		return r.Host
+	}
+	return r.Host + "/" + r.Path
+}
+
+// DefaultRegistry derives the default registry of a cloud provider.
+// account is the AWS account id, the GCP project id or the Azure registry name,
+// repository is the Artifact Registry repository (GCP only).
+func DefaultRegistry(provider, region, account, repository string) (string, error) {
+	if account == "" {
+		return "", fmt.Errorf("an account id is required to derive the %s registry", provider)
+	}
+
+	switch provider {
+	case "aws":
+		if !awsAccountPattern.MatchString(account) {
+			return "", fmt.Errorf("invalid AWS account id %q: expected 12 digits", account)
+		}
+		if region == "" {
+			return "", fmt.Errorf("a region is required to derive the ECR registry")
+		}
+		return fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com", account, region), nil
+	case "gcp":
+		if region == "" {
+			return "", fmt.Errorf("a region is required to derive the Artifact Registry")
+		}
+		repository = strings.Trim(repositoryInvalidPattern.ReplaceAllString(strings.ToLower(repository), "-"), "-")
+		if repository == "" {
+			repository = "daab"
+		}
+		return fmt.Sprintf("%s-docker.pkg.dev/%s/%s", region, account, repository), nil
+	case "azure":
+		name := acrInvalidPattern.ReplaceAllString(strings.ToLower(account), "")
+		if len(name) < 5 || len(name) > 50 {
+			return "", fmt.Errorf("invalid Azure registry name %q: expected 5-50 alphanumeric characters", account)
+		}
+		return name + ".azurecr.io", nil
+	default:
+		return "", fmt.Errorf("unknown cloud provider %q", provider)
+	}
+}
+
+// ParseRegistry validates a registry reference and identifies its kind.
+// A reference without a host (e.g. "my-org") is a Docker Hub namespace.
+func ParseRegistry(ref string) (*Registry, error) {
+	ref = strings.TrimSuffix(strings.TrimSpace(ref), "/")
+	if ref == "" {
+		return nil, fmt.Errorf("registry cannot be empty")
+	}
+	if strings.Contains(ref, "://") {
+		return nil, fmt.Errorf("invalid registry %q: remove the URL scheme", ref)
+	}
+
+	host, path, _ := strings.Cut(ref, "/")
+	if !isRegistryHost(host) {
+		// Docker Hub namespace, e.g. "my-org"
+		host, path = "docker.io", ref
+	}
+
+	if !hostPattern.MatchString(host) {
+		return nil, fmt.Errorf("invalid registry host %q", host)
+	}
+	if path != "" {
+		for _, part := range strings.Split(path, "/") {
+			if !pathPartPattern.MatchString(part) {
+				return nil, fmt.Errorf("invalid repository path %q in registry %q: use lowercase letters, digits and . _ -", path, ref)
+			}
+		}
+	}
+
+	registry := &Registry{Host: host, Path: path}
+	switch {
+	case ecrHostPattern.MatchString(host):
+		registry.Kind = RegistryECR
+		registry.Region = ecrHostPattern.FindStringSubmatch(host)[2]
+	case garHostPattern.MatchString(host):
+		registry.Kind = RegistryGAR
+		registry.Region = garHostPattern.FindStringSubmatch(host)[1]
+		if len(strings.Split(path, "/")) < 2 {
+			return nil, fmt.Errorf("invalid Artifact Registry %q: expected <region>-docker.pkg.dev/<project>/<repository>", ref)
+		}
+	case gcrHostPattern.MatchString(host):
+		registry.Kind = RegistryGCR
+		if path == "" {
+			return nil, fmt.Errorf("invalid Container Registry %q: expected gcr.io/<project>", ref)
+		}
+	case strings.HasSuffix(host, ".azurecr.io"):
+		if !acrHostPattern.MatchString(host) {
+			return nil, fmt.Errorf("invalid Azure registry %q: the name must be 5-50 lowercase alphanumeric characters", ref)
+		}
+		registry.Kind = RegistryACR
+	case host == "docker.io" || host == "index.docker.io" || host == "registry-1.docker.io":
+		registry.Kind = RegistryDockerHub
+		registry.Host = "docker.io"
+		if path == "" {
+			return nil, fmt.Errorf("invalid Docker Hub registry %q: expected docker.io/<namespace>", ref)
+		}
+	case host == "ghcr.io":
+		registry.Kind = RegistryGHCR
+		if path == "" {
+			return nil, fmt.Errorf("invalid GitHub registry %q: expected ghcr.io/<owner>", ref)
+		}
+	case host == "quay.io":
+		registry.Kind = RegistryQuay
+		if path == "" {
+			return nil, fmt.Errorf("invalid Quay registry %q: expected quay.io/<organization>", ref)
+		}
+	default:
+		registry.Kind = RegistrySelfHosted
+	}
+
+	return registry, nil
+}
+
+// ValidateRegistry returns an error if ref is not a valid registry reference.
+func ValidateRegistry(ref string) error {
+	_, err := ParseRegistry(ref)
+	return err
+}
+
+// isRegistryHost applies the Docker rule: the first component is a host when it
+// contains a dot or a port, or is localhost.
+func isRegistryHost(component string) bool {
+	return strings.ContainsAny(component, ".:") || component == "localhost"
+}
+
+// ValidateAccountID checks the account identifier used to derive the default registry:
+// a 12 digit AWS account id, a GCP project id or an Azure registry name.
+func ValidateAccountID(provider, account string) error {
+	switch provider {
+	case "aws":
+		if !awsAccountPattern.MatchString(account) {
+			return fmt.Errorf("invalid AWS account id %q: expected 12 digits", account)
+		}
+	case "gcp":
+		if !gcpProjectPattern.MatchString(account) {
+			return fmt.Errorf("invalid GCP project id %q: expected 6-30 lowercase letters, digits or '-', starting with a letter", account)
+		}
+	case "azure":
+		if !acrNamePattern.MatchString(account) {
+			return fmt.Errorf("invalid Azure registry name %q: expected 5-50 alphanumeric characters", account)
+		}
+	default:
+		return fmt.Errorf("unknown cloud provider %q", provider)
+	}
+	return nil
+}
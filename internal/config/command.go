@@ -0,0 +1,211 @@
+package configcmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/mouad4949/DAAB/internal/defaults"
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+	"github.com/mouad4949/DAAB/pkg/secrets"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+type ConfigFlags struct {
+	ProjectPath string
+	Env         string
+	Service     string
+	Raw         bool
+}
+
+func NewConfigCommand() *cobra.Command {
+	flags := &ConfigFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the DAAB configuration of your project",
+	}
+	cmd.PersistentFlags().StringVar(&flags.ProjectPath, "project-path", ".", "Path to the project directory")
+
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print the fully resolved configuration for an environment",
+		Long: `Load .init/daab.yaml (or .init/daab.root.yaml for microservices), apply the
+overlay selected with --env and print the resulting configuration.`,
+		Example: `  daab config show
+  daab config show --env staging
+  daab config show --env production --service users-api`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags.Env, _ = cmd.Flags().GetString("env")
+			return runShow(flags)
+		},
+	}
+	showCmd.Flags().StringVar(&flags.Service, "service", "", "Only print the configuration of this microservice")
+	showCmd.Flags().BoolVar(&flags.Raw, "raw", false, "Print microservice configs as stored, without the settings inherited from daab.root.yaml")
+
+	envsCmd := &cobra.Command{
+		Use:   "envs",
+		Short: "List the environments defined for the project",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runEnvs(flags)
+		},
+	}
+
+	secretsCmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Check that every secret:// reference in the configuration resolves",
+		Long: `List the secret:// references used by the configuration and try to resolve each
+one through its provider. Secret values are never printed.`,
+		Example: `  daab config secrets
+  daab config secrets --env production`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags.Env, _ = cmd.Flags().GetString("env")
+			return runSecrets(cmd.Context(), flags)
+		},
+	}
+
+	defaultsCmd := &cobra.Command{
+		Use:   "defaults",
+		Short: "Show the prompt defaults from your user and organisation config",
+		Long: `Show the defaults used by 'daab init', layered from the organisation file
+referenced by $DAAB_ORG_DEFAULTS and ~/.config/daab/config.yaml.
+Values locked by the organisation cannot be overridden.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDefaults()
+		},
+	}
+
+	cmd.AddCommand(showCmd, envsCmd, secretsCmd, defaultsCmd)
+	return cmd
+}
+
+func runShow(flags *ConfigFlags) error {
+	project, err := configProject.Load(flags.ProjectPath, flags.Env)
+	if err != nil {
+		return err
+	}
+
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+
+	if !project.IsMicroservice() {
+		return encoder.Encode(project.Monolith)
+	}
+
+	if flags.Service == "" {
+		if err := encoder.Encode(project.Root); err != nil {
+			return err
+		}
+	}
+	found := false
+	for _, svc := range project.Services {
+		if flags.Service != "" && !svc.Matches(flags.Service) {
+			continue
+		}
+		found = true
+		serviceConfig := svc.Effective
+		if flags.Raw {
+			serviceConfig = svc.Config
+		}
+		if err := encoder.Encode(serviceConfig); err != nil {
+			return err
+		}
+	}
+	if flags.Service != "" && !found {
+		return fmt.Errorf("service %q not found", flags.Service)
+	}
+	return nil
+}
+
+func runSecrets(ctx context.Context, flags *ConfigFlags) error {
+	project, err := configProject.Load(flags.ProjectPath, flags.Env)
+	if err != nil {
+		return err
+	}
+
+	configs := map[string]interface{}{}
+	if project.IsMicroservice() {
+		configs[configProject.RootConfigFile] = project.Root
+		for _, svc := range project.Services {
+			configs[svc.Name] = svc.Config
+		}
+	} else {
+		configs[configProject.ConfigFile] = project.Monolith
+	}
+
+	resolver := secrets.NewDefaultResolver(flags.ProjectPath)
+	failed := 0
+	total := 0
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		refs := secrets.FindReferences(configs[name])
+		for _, path := range secrets.SortedPaths(refs) {
+			total++
+			if _, err := resolver.Resolve(ctx, refs[path]); err != nil {
+				failed++
+				fmt.Printf("❌ %s: %s (%v)\n", name, path, err)
+				continue
+			}
+			fmt.Printf("✅ %s: %s -> %s\n", name, path, refs[path])
+		}
+	}
+
+	if total == 0 {
+		fmt.Println("No secret references found.")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d secret references could not be resolved", failed, total)
+	}
+	return nil
+}
+
+func runDefaults() error {
+	d, err := defaults.Load()
+	if err != nil {
+		return err
+	}
+
+	orgPath := d.OrgPath
+	if orgPath == "" {
+		orgPath = "(not set, use $" + defaults.OrgDefaultsEnv + ")"
+	}
+	fmt.Printf("Organisation defaults: %s\n", orgPath)
+	fmt.Printf("User defaults:         %s\n", d.UserPath)
+	fmt.Println()
+
+	if len(d.Keys()) == 0 {
+		fmt.Println("No defaults configured.")
+		return nil
+	}
+	for _, key := range d.Keys() {
+		suffix := ""
+		if d.IsLocked(key) {
+			suffix = ", locked"
+		}
+		fmt.Printf("  %-20s %s (%s%s)\n", key, d.Get(key, ""), d.Source(key), suffix)
+	}
+	return nil
+}
+
+func runEnvs(flags *ConfigFlags) error {
+	envs, err := configProject.Environments(flags.ProjectPath)
+	if err != nil {
+		return err
+	}
+	if len(envs) == 0 {
+		fmt.Println("No environments defined. Add an `environments:` section or a .init/daab.<env>.yaml overlay.")
+		return nil
+	}
+	for _, env := range envs {
+		fmt.Println(env)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package defaults
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Keys that can be set in the user and organisation defaults files.
+const (
+	KeyProjectType       = "project_type"
+	KeyCloudProvider     = "cloud_provider"
+	KeyRegion            = "region"
+	KeyAccountID         = "account_id"
+	KeyEnvironment       = "environment"
+	KeyContainerRegistry = "container_registry"
+	KeyNamespace         = "namespace"
+	KeyTarget            = "target"
+)
+
+var knownKeys = map[string]bool{
+	KeyProjectType:       true,
+	KeyCloudProvider:     true,
+	KeyRegion:            true,
+	KeyAccountID:         true,
+	KeyEnvironment:       true,
+	KeyContainerRegistry: true,
+	KeyNamespace:         true,
+	KeyTarget:            true,
+}
+
+const (
+	// OrgDefaultsEnv points to the organisation defaults file.
+	OrgDefaultsEnv = "DAAB_ORG_DEFAULTS"
+	// UserConfigEnv overrides the location of the personal config file.
+	UserConfigEnv = "DAAB_CONFIG"
+)
+
+// File is the format shared by the user and organisation defaults files:
+//
+//	defaults:
+//	  cloud_provider: aws
+//	  region: eu-west-1
+//	  namespace: platform
+//	locked:
+//	  - cloud_provider
+//
+// Only the organisation file may lock keys.
+type File struct {
+	Defaults map[string]string `yaml:"defaults"`
+	Locked   []string          `yaml:"locked,omitempty"`
+}
+
+// Source tells where an effective default comes from.
+type Source string
+
+const (
+	SourceOrganisation Source = "organisation"
+	SourceUser         Source = "user"
+)
+
+// Defaults is the layered result of the organisation and user defaults files.
+// User values override organisation values unless the organisation locked the key.
+type Defaults struct {
+	values  map[string]string
+	sources map[string]Source
+	locked  map[string]bool
+
+	OrgPath  string
+	UserPath string
+}
+
+// Empty returns Defaults with no values, used when defaults should be ignored.
+func Empty() *Defaults {
+	return &Defaults{
+		values:  map[string]string{},
+		sources: map[string]Source{},
+		locked:  map[string]bool{},
+	}
+}
+
+// Load reads the organisation file referenced by DAAB_ORG_DEFAULTS and the user file
+// (~/.config/daab/config.yaml, or DAAB_CONFIG). Missing files are skipped.
+func Load() (*Defaults, error) {
+	d := Empty()
+	d.OrgPath = os.Getenv(OrgDefaultsEnv)
+	d.UserPath = UserConfigPath()
+
+	if d.OrgPath != "" {
+		org, err := readFile(d.OrgPath)
+		if err != nil {
+			return nil, fmt.Errorf("organisation defaults: %w", err)
+		}
+		if org == nil {
+			return nil, fmt.Errorf("organisation defaults file %s (from %s) does not exist", d.OrgPath, OrgDefaultsEnv)
+		}
+		for key, value := range org.Defaults {
+			d.values[key] = value
+			d.sources[key] = SourceOrganisation
+		}
+		for _, key := range org.Locked {
+			if !knownKeys[key] {
+				return nil, fmt.Errorf("organisation defaults: unknown locked key %q", key)
+			}
+			if _, ok := org.Defaults[key]; !ok {
+				return nil, fmt.Errorf("organisation defaults: locked key %q has no value", key)
+			}
+			d.locked[key] = true
+		}
+	}
+
+	if d.UserPath != "" {
+		user, err := readFile(d.UserPath)
+		if err != nil {
+			return nil, fmt.Errorf("user defaults: %w", err)
+		}
+		if user != nil {
+			for key, value := range user.Defaults {
+				if d.locked[key] {
+					continue
+				}
+				d.values[key] = value
+				d.sources[key] = SourceUser
+			}
+		}
+	}
+
+	return d, nil
+}
+
+// UserConfigPath returns the personal config file location.
+func UserConfigPath() string {
+	if path := os.Getenv(UserConfigEnv); path != "" {
+		return path
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "daab", "config.yaml")
+}
+
+// Get returns the default for key, or fallback when none is configured.
+func (d *Defaults) Get(key, fallback string) string {
+	if value, ok := d.values[key]; ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// IsLocked reports whether the organisation mandates the value of key.
+func (d *Defaults) IsLocked(key string) bool {
+	return d.locked[key]
+}
+
+// Source returns where the default for key comes from, empty when it is not set.
+func (d *Defaults) Source(key string) Source {
+	return d.sources[key]
+}
+
+// Keys returns the configured keys in a stable order.
+func (d *Defaults) Keys() []string {
+	keys := make([]string, 0, len(d.values))
+	for key := range d.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CheckLocked returns an error if value differs from the locked organisation value for key.
+func (d *Defaults) CheckLocked(key, value string) error {
+	if !d.locked[key] {
+		return nil
+	}
+	if value != d.values[key] {
+		return fmt.Errorf("%s must be %q (locked by organisation defaults in %s), got %q", key, d.values[key], d.OrgPath, value)
+	}
+	return nil
+}
+
+func readFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	file := &File{}
+	if err := yaml.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for key := range file.Defaults {
+		if !knownKeys[key] {
+			return nil, fmt.Errorf("unknown key %q in %s", key, path)
+		}
+	}
+	return file, nil
+}
@@ -0,0 +1,188 @@
+package deploycmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mouad4949/DAAB/pkg/build"
+	config "github.com/mouad4949/DAAB/pkg/config"
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+	"github.com/mouad4949/DAAB/pkg/deploy"
+	"github.com/mouad4949/DAAB/pkg/generate"
+	"github.com/mouad4949/DAAB/pkg/secrets"
+	"github.com/spf13/cobra"
+)
+
+// HistoryDir is the default folder of the release history, in the .init folder of the project.
+const HistoryDir = "releases"
+
+type DeployFlags struct {
+	ProjectPath string
+	Env         string
+	Services    []string
+	Context     string
+	History     string
+}
+
+func NewDeployCommand() *cobra.Command {
+	flags := &DeployFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "deploy",
+		Short: "Deploy the built images to Kubernetes",
+		Long: `Apply the Kubernetes manifests of the project (monolith) or of every service
+(microservices) with kubectl, running the image of the last 'daab build', pinned to
+its digest when it was pushed with 'daab push'.
+
+Every application is rolled out with the strategy of its daab.yaml:
+
+  strategy:
+    type: canary            # rolling (default), blue-green or canary
+    timeout: 5m             # of every health gate
+    rolling:   {max_surge: "25%", max_unavailable: "0"}
+    blue_green: {keep_previous: false}
+    canary:
+      steps:
+        - {weight: 10, pause: 2m}
+        - {weight: 50, pause: 5m}
+
+At every step, the new pods must become ready, passing the readiness probe on the
+health_endpoint, before the timeout; otherwise the deployment is aborted and the
+previous version restored: rolling deployments are undone, the idle blue/green slot
+is removed without switching the Service, and the canary is removed.
+
+The secret:// references of the configuration are resolved before the manifests are
+rendered, see 'daab config secrets'.
+
+Once every application is deployed, the ingress of the project, if any, is applied.
+
+Applications with a serverless target (cloud-run, lambda, container-apps, ecs-fargate)
+are skipped; they are deployed with the tools of their cloud from the descriptors of
+'daab generate'.
+
+Every deploy is recorded as a release in .init/releases (or --history): time,
+environment, services, images, config hash, git commit, user and the exact manifests,
+so 'daab history' lists them and 'daab rollback' applies a previous one again.`,
+		Example: `  daab deploy
+  daab deploy --env production --context prod-cluster
+  daab deploy --service users-api`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags.Env, _ = cmd.Flags().GetString("env")
+			cmd.SilenceUsage = true
+			return runDeploy(cmd, flags)
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.ProjectPath, "project-path", ".", "Path to the project directory")
+	cmd.Flags().StringSliceVar(&flags.Services, "service", nil, "Only deploy these services (repeatable)")
+	addClusterFlags(cmd, &flags.Context, &flags.History)
+
+	return cmd
+}
+
+// addClusterFlags adds the flags shared by the commands that apply releases.
+func addClusterFlags(cmd *cobra.Command, context, history *string) {
+	cmd.Flags().StringVar(context, "context", "", "kubeconfig context to deploy to (default: the current context)")
+	cmd.Flags().StringVar(history, "history", "", "Folder of the release history (default: .init/releases)")
+}
+
+// historyStore returns the release store of the project.
+func historyStore(projectPath, dir string) deploy.Store {
+	if dir == "" {
+		dir = filepath.Join(projectPath, configProject.ConfigDir, HistoryDir)
+	}
+	return deploy.NewFileStore(dir)
+}
+
+func runDeploy(cmd *cobra.Command, flags *DeployFlags) error {
+	project, err := configProject.Load(flags.ProjectPath, flags.Env)
+	if err != nil {
+		return err
+	}
+
+	// The hash identifies the configuration as written, without the values of its secrets
+	configHash, err := deploy.ConfigHash(project)
+	if err != nil {
+		return err
+	}
+	if err := project.ResolveSecrets(cmd.Context(), secrets.NewDefaultResolver(flags.ProjectPath)); err != nil {
+		return err
+	}
+
+	apps, err := build.SelectApps(project, flags.Services)
+	if err != nil {
+		return err
+	}
+	apps = kubernetesApps(apps)
+	if len(apps) == 0 {
+		return fmt.Errorf("no service deploys to kubernetes, deploy the serverless targets with their descriptors")
+	}
+
+	state, err := build.LoadState(build.StatePath(flags.ProjectPath))
+	if err != nil {
+		return err
+	}
+	if err := setImages(apps, state); err != nil {
+		return err
+	}
+
+	release, err := deploy.Deploy(cmd.Context(), apps, deploy.Options{
+		Cluster:     &deploy.Kubectl{Context: flags.Context, Output: os.Stdout},
+		Store:       historyStore(flags.ProjectPath, flags.History),
+		Output:      os.Stdout,
+		Environment: flags.Env,
+		ConfigHash:  configHash,
+		GitCommit:   deploy.GitCommit(flags.ProjectPath),
+		User:        deploy.CurrentUser(),
+		EntryPoint:  generate.EntryPointFromProject(project),
+	})
+	if err != nil {
+		if release != nil && release.ID != 0 {
+			return fmt.Errorf("release %d failed: %w", release.ID, err)
+		}
+		return err
+	}
+
+	fmt.Printf("✅ Release %d deployed (%d services)\n", release.ID, len(apps))
+	return nil
+}
+
+// serverlessCommands are the commands deploying the descriptors of the serverless targets.
+var serverlessCommands = map[string]string{
+	config.TargetCloudRun:      "gcloud run services replace " + generate.CloudRunServicePath,
+	config.TargetLambda:        "sam build && sam deploy --guided",
+	config.TargetContainerApps: "az containerapp create --resource-group <group> --environment <environment> --yaml " + generate.ContainerAppPath,
+	config.TargetECSFargate:    "aws ecs register-task-definition --cli-input-yaml file://" + generate.TaskDefinitionPath,
+}
+
+// kubernetesApps returns the apps deployed to Kubernetes, and tells how to deploy the others.
+func kubernetesApps(apps []*generate.App) []*generate.App {
+	var selected []*generate.App
+	for _, app := range apps {
+		if config.IsKubernetes(app.Target) {
+			selected = append(selected, app)
+			continue
+		}
+		fmt.Printf("⏭️  Skipping %s: the %s target is deployed from %s with '%s'\n", app.Name, app.Target, app.Path, serverlessCommands[app.Target])
+	}
+	return selected
+}
+
+// setImages points every app to the image of its last build, pinned to its digest when
+// it was pushed.
+func setImages(apps []*generate.App, state *build.State) error {
+	for _, app := range apps {
+		record, ok := state.Get(app.Name)
+		if !ok {
+			return fmt.Errorf("no build of %s, run 'daab build' and 'daab push' first", app.Name)
+		}
+		if pushed := record.PushedReference(); pushed != "" {
+			app.Reference = pushed
+			continue
+		}
+		fmt.Printf("⚠️  %s was not pushed, deploying %s\n", app.Name, record.Reference())
+		app.Reference = record.Reference()
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package deploycmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mouad4949/DAAB/pkg/deploy"
+	"github.com/spf13/cobra"
+)
+
+type HistoryFlags struct {
+	ProjectPath string
+	Env         string
+	History     string
+	Output      string
+	Limit       int
+}
+
+func NewHistoryCommand() *cobra.Command {
+	flags := &HistoryFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List the releases deployed with daab deploy",
+		Long: `List the recorded releases, newest first: when, where, what and by whom.
+With --env, only the releases of that environment are listed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags.Env, _ = cmd.Flags().GetString("env")
+			return runHistory(flags)
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.ProjectPath, "project-path", ".", "Path to the project directory")
+	cmd.Flags().StringVar(&flags.History, "history", "", "Folder of the release history (default: .init/releases)")
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "table", "Output format: table or json")
+	cmd.Flags().IntVar(&flags.Limit, "limit", 20, "Maximum number of releases listed, 0 for all")
+
+	return cmd
+}
+
+func runHistory(flags *HistoryFlags) error {
+	if flags.Output != "table" && flags.Output != "json" {
+		return fmt.Errorf("unsupported output format %q, use table or json", flags.Output)
+	}
+
+	all, err := historyStore(flags.ProjectPath, flags.History).List()
+	if err != nil {
+		return err
+	}
+
+	// Newest first
+	releases := []*deploy.Release{}
+	for idx := len(all) - 1; idx >= 0; idx-- {
+		if flags.Env != "" && all[idx].Environment != flags.Env {
+			continue
+		}
+		releases = append(releases, all[idx])
+		if flags.Limit > 0 && len(releases) == flags.Limit {
+			break
+		}
+	}
+
+	if flags.Output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(releases)
+	}
+
+	if len(releases) == 0 {
+		fmt.Println("No releases yet, deploy with 'daab deploy'.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDATE\tENV\tSTATUS\tSERVICES\tCOMMIT\tUSER\tNOTE")
+	for _, release := range releases {
+		var services []string
+		for _, svc := range release.Services {
+			if svc.Image == "" {
+				services = append(services, svc.Name)
+				continue
+			}
+			services = append(services, svc.Name+"@"+shortImage(svc.Image))
+		}
+		// Errors of kubectl span several lines
+		note := strings.Join(strings.Fields(release.Error), " ")
+		if release.RollbackOf != 0 {
+			note = fmt.Sprintf("rollback to %d", release.RollbackOf)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			release.ID,
+			release.Time.Local().Format("2006-01-02 15:04:05"),
+			orDash(release.Environment),
+			release.Status,
+			strings.Join(services, ","),
+			orDash(shortCommit(release.GitCommit)),
+			release.User,
+			orDash(note),
+		)
+	}
+	return w.Flush()
+}
+
+// shortImage returns the tag or the short digest of an image reference.
+func shortImage(image string) string {
+	if _, digest, ok := strings.Cut(image, "@sha256:"); ok {
+		return digest[:min(12, len(digest))]
+	}
+	if idx := strings.LastIndex(image, ":"); idx > strings.LastIndex(image, "/") {
+		return image[idx+1:]
+	}
+	return "latest"
+}
+
+func shortCommit(commit string) string {
+	sha, dirty := strings.CutSuffix(commit, "-dirty")
+	if len(sha) > 12 {
+		sha = sha[:12]
+	}
+	if dirty {
+		sha += "-dirty"
+	}
+	return sha
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
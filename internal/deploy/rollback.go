@@ -0,0 +1,66 @@
+package deploycmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mouad4949/DAAB/pkg/deploy"
+	"github.com/spf13/cobra"
+)
+
+type RollbackFlags struct {
+	ProjectPath string
+	Env         string
+	To          int
+	Context     string
+	History     string
+}
+
+func NewRollbackCommand() *cobra.Command {
+	flags := &RollbackFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Apply the manifests of a previous release again",
+		Long: `Apply again the exact manifests recorded for a previous release, by default the last
+successful release of the environment before the current one. When the latest deploy
+failed, the services it rolled out before failing are on its version: the rollback then
+applies the last successful release again. Every release holds all the services of the
+environment, a deploy with --service records the others as they were, so a rollback
+restores every service. The rollback is recorded as a new release, see 'daab history'.`,
+		Example: `  daab rollback
+  daab rollback --env production
+  daab rollback --to 12`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags.Env, _ = cmd.Flags().GetString("env")
+			cmd.SilenceUsage = true
+			return runRollback(cmd, flags)
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.ProjectPath, "project-path", ".", "Path to the project directory")
+	cmd.Flags().IntVar(&flags.To, "to", 0, "ID of the release to apply again (default: the previous release)")
+	addClusterFlags(cmd, &flags.Context, &flags.History)
+
+	return cmd
+}
+
+func runRollback(cmd *cobra.Command, flags *RollbackFlags) error {
+	release, err := deploy.Rollback(cmd.Context(), flags.To, deploy.Options{
+		Cluster:     &deploy.Kubectl{Context: flags.Context, Output: os.Stdout},
+		Store:       historyStore(flags.ProjectPath, flags.History),
+		Output:      os.Stdout,
+		Environment: flags.Env,
+		User:        deploy.CurrentUser(),
+	})
+	if err != nil {
+		if release != nil && release.ID != 0 {
+			return fmt.Errorf("release %d failed: %w", release.ID, err)
+		}
+		return err
+	}
+
+	fmt.Printf("✅ Rolled back to release %d as release %d\n", release.RollbackOf, release.ID)
+	return nil
+}
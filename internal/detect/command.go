@@ -0,0 +1,160 @@
+package detectcmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mouad4949/DAAB/pkg/detect"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// ExitDetectionFailed is the exit code of daab detect when a project could not be detected.
+const ExitDetectionFailed = 2
+
+// ExitError is an error that sets the exit code of the process.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+type DetectFlags struct {
+	Output    string
+	Recursive bool
+	Workers   int
+}
+
+// Result is the detection of one folder as printed by daab detect.
+type Result struct {
+	Path                    string `json:"path" yaml:"path"`
+	*detect.DetectionResult `yaml:",inline"`
+	Error                   string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func NewDetectCommand() *cobra.Command {
+	flags := &DetectFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "detect [path...]",
+		Short: "Detect the language and framework of a project",
+		Long: `Run the project detection used by 'daab init' without asking any question.
+Each path (the current directory by default) is detected as a single project. With
+--recursive, every direct subfolder is detected as a microservice instead, and folders
+without a recognisable project are reported but ignored.
+
+The command exits with code 2 when a project cannot be detected (with --recursive:
+when no microservice is detected), so scripts can tell it apart from other errors.`,
+		Example: `  daab detect
+  daab detect ./api ./web --output json
+  daab detect --recursive --output yaml`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = []string{"."}
+			}
+			cmd.SilenceUsage = true
+			return runDetect(flags, args)
+		},
+	}
+
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "table", "Output format: table, json or yaml")
+	cmd.Flags().BoolVarP(&flags.Recursive, "recursive", "r", false, "Detect every subfolder as a microservice")
+	cmd.Flags().IntVar(&flags.Workers, "workers", runtime.NumCPU(), "Number of folders detected concurrently")
+
+	return cmd
+}
+
+func runDetect(flags *DetectFlags, paths []string) error {
+	switch flags.Output {
+	case "table", "json", "yaml":
+	default:
+		return fmt.Errorf("unknown output format %q: use table, json or yaml", flags.Output)
+	}
+
+	var failed []string
+	results := []Result{}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+
+		folders := []string{path}
+		if flags.Recursive {
+			var err error
+			if folders, err = detect.CandidateFolders(path); err != nil {
+				return err
+			}
+		}
+
+		detected := 0
+		for _, detection := range detect.DetectAll(folders, flags.Workers) {
+			result := Result{Path: detection.Path, DetectionResult: detection.Result}
+			if detection.Err != nil {
+				result.Error = detection.Err.Error()
+			} else {
+				detected++
+			}
+			results = append(results, result)
+		}
+
+		if detected == 0 {
+			failed = append(failed, path)
+		}
+	}
+
+	if err := printResults(flags.Output, results); err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return &ExitError{
+			Code: ExitDetectionFailed,
+			Err:  fmt.Errorf("detection failed for %s", strings.Join(failed, ", ")),
+		}
+	}
+	return nil
+}
+
+func printResults(output string, results []Result) error {
+	switch output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	case "yaml":
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		defer encoder.Close()
+		return encoder.Encode(results)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tLANGUAGE\tFRAMEWORK\tEVIDENCE")
+	for _, result := range results {
+		if result.DetectionResult == nil {
+			fmt.Fprintf(w, "%s\t-\t-\t%s\n", result.Path, result.Error)
+			continue
+		}
+		framework := result.Framework
+		if framework == "" {
+			framework = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Path, result.Language, framework, strings.Join(result.DetectedFiles, ", "))
+	}
+	return w.Flush()
+}
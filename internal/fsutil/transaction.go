@@ -0,0 +1,201 @@
+package fsutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// StagedFile is a file waiting to be written by a Transaction.
+type StagedFile struct {
+	Path string
+	Data []byte
+	Mode os.FileMode
+}
+
+// Transaction writes a set of files all together or not at all. Files are staged in
+// memory, written to temporary files next to their target, then renamed into place.
+// If any step fails, files already replaced are restored and new files are removed.
+type Transaction struct {
+	files []StagedFile
+}
+
+func NewTransaction() *Transaction {
+	return &Transaction{}
+}
+
+// Stage adds a file to the transaction, replacing an earlier staged file with the same path.
+func (t *Transaction) Stage(path string, data []byte, mode os.FileMode) {
+	for idx := range t.files {
+		if t.files[idx].Path == path {
+			t.files[idx] = StagedFile{Path: path, Data: data, Mode: mode}
+			return
+		}
+	}
+	t.files = append(t.files, StagedFile{Path: path, Data: data, Mode: mode})
+}
+
+// Files returns the staged files sorted by path.
+func (t *Transaction) Files() []StagedFile {
+	files := append([]StagedFile(nil), t.files...)
+	sort.Slice(files, func(a, b int) bool {
+		return files[a].Path < files[b].Path
+	})
+	return files
+}
+
+// DryRun prints the files the transaction would write and their content.
+func (t *Transaction) DryRun(w io.Writer) {
+	for _, file := range t.Files() {
+		action := "create"
+		if _, err := os.Stat(file.Path); err == nil {
+			action = "overwrite"
+		}
+		fmt.Fprintf(w, "--- %s (%s)\n", file.Path, action)
+		w.Write(file.Data)
+		if len(file.Data) > 0 && file.Data[len(file.Data)-1] != '\n' {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// original is what a target looked like before the commit, used for rollback.
+type original struct {
+	path    string
+	existed bool
+	data    []byte
+	mode    os.FileMode
+}
+
+// Commit writes every staged file, or none of them.
+func (t *Transaction) Commit() (err error) {
+	var createdDirs []string
+	temps := map[string]string{}
+	var replaced []original
+
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, tmp := range temps {
+			os.Remove(tmp)
+		}
+		// Restore in reverse order
+		for idx := len(replaced) - 1; idx >= 0; idx-- {
+			orig := replaced[idx]
+			if orig.existed {
+				WriteFileAtomic(orig.path, orig.data, orig.mode)
+			} else {
+				os.Remove(orig.path)
+			}
+		}
+		for idx := len(createdDirs) - 1; idx >= 0; idx-- {
+			os.Remove(createdDirs[idx])
+		}
+	}()
+
+	// Stage 1: write every file to a temporary file next to its target
+	for _, file := range t.files {
+		dirs, err := mkdirAll(filepath.Dir(file.Path))
+		createdDirs = append(createdDirs, dirs...)
+		if err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", file.Path, err)
+		}
+
+		tmp, err := writeTemp(file)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.Path, err)
+		}
+		temps[file.Path] = tmp
+	}
+
+	// Stage 2: remember the current content, then rename the temporary files into place
+	for _, file := range t.files {
+		orig := original{path: file.Path}
+		if info, statErr := os.Stat(file.Path); statErr == nil {
+			data, readErr := os.ReadFile(file.Path)
+			if readErr != nil {
+				return fmt.Errorf("failed to back up %s: %w", file.Path, readErr)
+			}
+			orig.existed = true
+			orig.data = data
+			orig.mode = info.Mode().Perm()
+		}
+
+		if err := os.Rename(temps[file.Path], file.Path); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", file.Path, err)
+		}
+		delete(temps, file.Path)
+		replaced = append(replaced, orig)
+	}
+
+	return nil
+}
+
+// WriteFileAtomic writes data to a temporary file and renames it over path, so readers
+// never see a partially written file.
+func WriteFileAtomic(path string, data []byte, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := writeTemp(StagedFile{Path: path, Data: data, Mode: mode})
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func writeTemp(file StagedFile) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(file.Path), "."+filepath.Base(file.Path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+
+	if _, err := f.Write(file.Data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Chmod(tmp, file.Mode); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return tmp, nil
+}
+
+// mkdirAll is os.MkdirAll that returns the directories it created, outermost first.
+func mkdirAll(dir string) ([]string, error) {
+	var missing []string
+	for current := dir; ; current = filepath.Dir(current) {
+		if _, err := os.Stat(current); err == nil {
+			break
+		}
+		missing = append([]string{current}, missing...)
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+
+	for idx, d := range missing {
+		if err := os.Mkdir(d, 0755); err != nil && !os.IsExist(err) {
+			return missing[:idx], err
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,156 @@
+package fsutil
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// tree returns the files and folders under dir, folders ending with a slash, with the
+// content of the files.
+func tree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		rel = filepath.ToSlash(rel)
+		if entry.IsDir() {
+			files[rel+"/"] = ""
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		files[rel] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func paths(files map[string]string) []string {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCommit(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := NewTransaction()
+	tx.Stage(filepath.Join(dir, "Dockerfile"), []byte("FROM new"), 0644)
+	tx.Stage(filepath.Join(dir, "k8s", "deployment.yaml"), []byte("kind: Deployment"), 0644)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit(): %v", err)
+	}
+
+	want := map[string]string{
+		"Dockerfile":          "FROM new",
+		"k8s/":                "",
+		"k8s/deployment.yaml": "kind: Deployment",
+	}
+	if got := tree(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("after Commit() the folder holds %v, want %v", paths(got), paths(want))
+	}
+	info, err := os.Stat(filepath.Join(dir, "Dockerfile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("Dockerfile mode = %v, want the staged 0644", info.Mode().Perm())
+	}
+}
+
+func TestCommitRollsBack(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup creates the target that makes the commit fail, and returns its path
+		setup func(t *testing.T, dir string) string
+	}{
+		{
+			// Renaming a file over a folder fails after the other files were replaced
+			name: "rename over a folder",
+			setup: func(t *testing.T, dir string) string {
+				if err := os.MkdirAll(filepath.Join(dir, "service.yaml", "nested"), 0755); err != nil {
+					t.Fatal(err)
+				}
+				return filepath.Join(dir, "service.yaml")
+			},
+		},
+		{
+			// The temporary file cannot be created in a folder that is a file
+			name: "parent is a file",
+			setup: func(t *testing.T, dir string) string {
+				if err := os.WriteFile(filepath.Join(dir, "notes"), []byte("notes"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return filepath.Join(dir, "notes", "service.yaml")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM old"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			failing := tt.setup(t, dir)
+			before := tree(t, dir)
+
+			tx := NewTransaction()
+			tx.Stage(filepath.Join(dir, "Dockerfile"), []byte("FROM new"), 0644)
+			tx.Stage(filepath.Join(dir, "k8s", "base", "deployment.yaml"), []byte("kind: Deployment"), 0644)
+			tx.Stage(failing, []byte("kind: Service"), 0644)
+			if err := tx.Commit(); err == nil {
+				t.Fatal("Commit() succeeded, want an error")
+			}
+
+			// The replaced file is restored, the new file and its folders are removed and
+			// no temporary file is left
+			if got := tree(t, dir); !reflect.DeepEqual(got, before) {
+				t.Errorf("after a failed Commit() the folder holds %v, want %v", paths(got), paths(before))
+			}
+			info, err := os.Stat(filepath.Join(dir, "Dockerfile"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("Dockerfile mode = %v, want its original 0600", info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "history", "1.yaml")
+	for _, content := range []string{"id: 1", "id: 1\nstatus: deployed"} {
+		if err := WriteFileAtomic(path, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFileAtomic(): %v", err)
+		}
+		want := map[string]string{"history/": "", "history/1.yaml": content}
+		if got := tree(t, dir); !reflect.DeepEqual(got, want) {
+			t.Errorf("the folder holds %v, want %v", got, want)
+		}
+	}
+
+	if err := WriteFileAtomic(filepath.Join(dir, "history"), []byte("id: 2"), 0644); err == nil {
+		t.Error("WriteFileAtomic() over a folder succeeded")
+	}
+	if got := tree(t, dir); len(got) != 2 {
+		t.Errorf("a failed write left %v", paths(got))
+	}
+}
@@ -0,0 +1,158 @@
+package generatecmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mouad4949/DAAB/internal/fsutil"
+	"github.com/mouad4949/DAAB/pkg/build"
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+	"github.com/mouad4949/DAAB/pkg/generate"
+	"github.com/mouad4949/DAAB/pkg/secrets"
+	"github.com/spf13/cobra"
+)
+
+type GenerateFlags struct {
+	ProjectPath string
+	Env         string
+	Service     string
+	DryRun      bool
+	Force       bool
+}
+
+func NewGenerateCommand() *cobra.Command {
+	flags := &GenerateFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate the Dockerfile and deployment descriptors of your project",
+		Long: `Render a Dockerfile, a .dockerignore and Kubernetes manifests (k8s/deployment.yaml,
+k8s/service.yaml) from the DAAB configuration, in the project folder for a monolith
+or in every service folder for microservices. All files are written or none.
+
+Applications with a serverless target get the descriptor of their platform instead
+of the Kubernetes manifests:
+
+  cloud-run       cloudrun/service.yaml            gcloud run services replace
+  lambda          template.yaml (AWS SAM)          sam deploy
+  container-apps  containerapp/containerapp.yaml   az containerapp create --yaml
+  ecs-fargate     ecs/task-definition.yaml         aws ecs register-task-definition
+
+With an ingress section, the entry point of the project is written to the project
+folder too: k8s/ingress.yaml, or k8s/httproute.yaml for the Gateway API, routing
+every service with a path_prefix.
+
+The generated files are meant to be committed, so they never hold secrets: a
+configuration value used in them that is a secret:// reference is an error. 'daab
+deploy' resolves the references when it applies the manifests, see 'daab config secrets'.
+
+Files that were not generated by daab are left untouched unless --force is given.`,
+		Example: `  daab generate
+  daab generate --env production
+  daab generate --service users-api --dry-run`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags.Env, _ = cmd.Flags().GetString("env")
+			return runGenerate(flags)
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.ProjectPath, "project-path", ".", "Path to the project directory")
+	cmd.Flags().StringVar(&flags.Service, "service", "", "Only generate the files of this microservice")
+	cmd.Flags().BoolVar(&flags.DryRun, "dry-run", false, "Print the files that would be written without writing them")
+	cmd.Flags().BoolVar(&flags.Force, "force", false, "Overwrite files that were not generated by daab")
+
+	return cmd
+}
+
+func runGenerate(flags *GenerateFlags) error {
+	project, err := configProject.Load(flags.ProjectPath, flags.Env)
+	if err != nil {
+		return err
+	}
+	var services []string
+	if flags.Service != "" {
+		services = []string{flags.Service}
+	}
+	apps, err := build.SelectApps(project, services)
+	if err != nil {
+		return err
+	}
+
+	tx := fsutil.NewTransaction()
+	skipped := 0
+	for _, app := range apps {
+		files, err := generate.Generate(app)
+		if err != nil {
+			return fmt.Errorf("%s: %w", app.Name, err)
+		}
+		for _, file := range files {
+			path := filepath.Join(app.Path, filepath.FromSlash(file.Path))
+			if err := checkNoSecrets(path, file.Content); err != nil {
+				return err
+			}
+			if !flags.Force && isHandWritten(path) {
+				fmt.Printf("⏭️  Skipping %s: not generated by daab (use --force to overwrite)\n", path)
+				skipped++
+				continue
+			}
+			tx.Stage(path, file.Content, 0644)
+		}
+	}
+
+	// The entry point routes to every service, it is left as is for a single service
+	if entry := generate.EntryPointFromProject(project); entry != nil && flags.Service == "" {
+		files, err := entry.Files()
+		if err != nil {
+			return fmt.Errorf("ingress: %w", err)
+		}
+		for _, file := range files {
+			path := filepath.Join(entry.Path, filepath.FromSlash(file.Path))
+			if err := checkNoSecrets(path, file.Content); err != nil {
+				return err
+			}
+			if !flags.Force && isHandWritten(path) {
+				fmt.Printf("⏭️  Skipping %s: not generated by daab (use --force to overwrite)\n", path)
+				skipped++
+				continue
+			}
+			tx.Stage(path, file.Content, 0644)
+		}
+	}
+
+	if flags.DryRun {
+		tx.DryRun(os.Stdout)
+		return nil
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to write the generated files, no file was changed: %w", err)
+	}
+
+	for _, file := range tx.Files() {
+		fmt.Printf("✅ %s\n", file.Path)
+	}
+	if skipped > 0 {
+		fmt.Printf("⚠️  %d files skipped\n", skipped)
+	}
+	return nil
+}
+
+// checkNoSecrets fails when the generated content of path holds a secret:// reference.
+// The references are only resolved at deploy time, their values are never written to
+// the files of the repository.
+func checkNoSecrets(path string, content []byte) error {
+	if bytes.Contains(content, []byte(secrets.Scheme)) {
+		return fmt.Errorf("%s would contain a %s reference: generated files are committed, so secrets can only be used with 'daab deploy', which resolves them when it applies the manifests", path, secrets.Scheme)
+	}
+	return nil
+}
+
+// isHandWritten reports whether path exists and was not written by daab generate.
+func isHandWritten(path string) bool {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return !generate.IsGenerated(content)
+}
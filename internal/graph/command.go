@@ -0,0 +1,134 @@
+package graphcmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mouad4949/DAAB/internal/fsutil"
+	configMicroservice "github.com/mouad4949/DAAB/pkg/config/microservice"
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+	"github.com/mouad4949/DAAB/pkg/detect"
+	"github.com/mouad4949/DAAB/pkg/graph"
+	"github.com/spf13/cobra"
+)
+
+type GraphFlags struct {
+	ProjectPath string
+	Env         string
+	Format      string
+	Infer       bool
+	Write       bool
+}
+
+func NewGraphCommand() *cobra.Command {
+	flags := &GraphFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Show which services of a microservice project call which",
+		Long: `Render the dependencies between the services, the depends_on lists of
+.init/daab.root.yaml, as a Graphviz (dot) or Mermaid graph. Services are deployed
+after the services they depend on, and cycles are rejected.
+
+With --infer, dependencies are also guessed from the code and the configuration:
+environment variables named after a service (USERS_API_URL, BILLING_HOST), URLs such
+as http://users-api:8080, and depends_on of the Docker Compose file. Inferred
+dependencies are drawn dashed; --write saves them to daab.root.yaml. Inferred
+dependencies that would close a cycle are skipped.`,
+		Example: `  daab graph
+  daab graph --format mermaid > docs/services.mmd
+  daab graph --infer | dot -Tsvg > graph.svg
+  daab graph --infer --write`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags.Env, _ = cmd.Flags().GetString("env")
+			cmd.SilenceUsage = true
+			return runGraph(flags)
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.ProjectPath, "project-path", ".", "Path to the project directory")
+	cmd.Flags().StringVar(&flags.Format, "format", "dot", "Output format: dot or mermaid")
+	cmd.Flags().BoolVar(&flags.Infer, "infer", false, "Add the dependencies inferred from the code and docker compose")
+	cmd.Flags().BoolVar(&flags.Write, "write", false, "Save the inferred dependencies to daab.root.yaml instead of printing the graph")
+
+	return cmd
+}
+
+func runGraph(flags *GraphFlags) error {
+	if flags.Format != "dot" && flags.Format != "mermaid" {
+		return fmt.Errorf("unsupported format %q, use dot or mermaid", flags.Format)
+	}
+
+	project, err := configProject.Load(flags.ProjectPath, flags.Env)
+	if err != nil {
+		return err
+	}
+	if !project.IsMicroservice() {
+		return fmt.Errorf("%s is a monolith project, only microservice projects have a dependency graph", flags.ProjectPath)
+	}
+
+	refs := project.Root.ServiceRefs()
+	dependencies, err := configMicroservice.DependencyGraph(refs)
+	if err != nil {
+		return err
+	}
+
+	if flags.Infer || flags.Write {
+		inferred, err := detect.InferEdges(flags.ProjectPath, refs)
+		if err != nil {
+			return err
+		}
+		for _, edge := range dependencies.Merge(inferred) {
+			fmt.Fprintf(os.Stderr, "⚠️  Skipping %s -> %s (%s): it would close a dependency cycle\n", edge.From, edge.To, edge.Source)
+		}
+	}
+
+	if flags.Write {
+		return write(flags.ProjectPath, refs, dependencies)
+	}
+
+	if flags.Format == "mermaid" {
+		fmt.Print(dependencies.Mermaid())
+		return nil
+	}
+	fmt.Print(dependencies.DOT(project.Root.ProjectName))
+	return nil
+}
+
+// write saves the dependencies of g as the depends_on of the services of daab.root.yaml.
+func write(projectPath string, refs []configMicroservice.ServiceRef, g *graph.Graph) error {
+	added := 0
+	updated := make([]configMicroservice.ServiceRef, 0, len(refs))
+	for _, ref := range refs {
+		dependsOn := g.DependsOn(ref.Name)
+		for _, edge := range g.Edges() {
+			if edge.From == ref.Name && edge.Inferred() {
+				fmt.Printf("🔗 %s depends on %s (%s)\n", edge.From, edge.To, edge.Source)
+				added++
+			}
+		}
+		ref.DependsOn = dependsOn
+		updated = append(updated, ref)
+	}
+	if added == 0 {
+		fmt.Println("✅ No new dependency found")
+		return nil
+	}
+
+	rootPath := filepath.Join(projectPath, configProject.ConfigDir, configProject.RootConfigFile)
+	content, err := os.ReadFile(rootPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", rootPath, err)
+	}
+	content, err = configProject.SetRootServices(content, updated)
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFileAtomic(rootPath, content, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Saved %d dependencies to %s\n", added, rootPath)
+	return nil
+}
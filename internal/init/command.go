@@ -2,6 +2,10 @@ package initcmd
 
 import (
 	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/mouad4949/DAAB/internal/prompt"
 
 	"github.com/spf13/cobra"
 )
@@ -9,6 +13,9 @@ import (
 type InitFlags struct {
 	NonInteractive bool
 	ProjectPath    string
+	Env            string
+	Workers        int
+	DryRun         bool
 }
 
 func NewInitCommand() *cobra.Command {
@@ -24,13 +31,19 @@ This command will:
   - Create a .init/daab.yaml configuration file`,
 		Example: `  daab init
   daab init --non-interactive
-  daab init --project-path /path/to/project`,
+  daab init --project-path /path/to/project
+  daab init --env staging
+  daab init --dry-run`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			flags.Env, _ = cmd.Flags().GetString("env")
 			return runInit(flags)
 		},
 	}
 
 	cmd.Flags().StringVar(&flags.ProjectPath, "project-path", ".", "Path to the project directory")
+	cmd.Flags().IntVar(&flags.Workers, "workers", runtime.NumCPU(), "Number of microservices detected concurrently")
+	cmd.Flags().BoolVar(&flags.NonInteractive, "non-interactive", false, "Do not ask questions, use detected values and defaults")
+	cmd.Flags().BoolVar(&flags.DryRun, "dry-run", false, "Print the files that would be written without writing them")
 
 	return cmd
 }
@@ -40,12 +53,21 @@ func runInit(flags *InitFlags) error {
 	fmt.Println()
 
 	// Create the initializer
-	initializer := NewInitializer(flags.ProjectPath)
+	var prompter prompt.Prompter = prompt.NewStdTerminal()
+	if flags.NonInteractive {
+		prompter = prompt.NewDefaults()
+	}
+	initializer := NewInitializer(flags, prompter, os.Stdout)
 
 	// Run the initialization process
 	if err := initializer.Run(); err != nil {
 		return fmt.Errorf("initialization failed: %w", err)
 	}
+	if flags.DryRun {
+		fmt.Println()
+		fmt.Println("ℹ️  Dry run: no file was written. Run without --dry-run to save the configuration.")
+		return nil
+	}
 
 	fmt.Println()
 	fmt.Println("✅ DAAB initialization complete!")
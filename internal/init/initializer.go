@@ -1,19 +1,31 @@
 package initcmd
 
 import (
+	"errors"
 	"fmt"
-	config "github.com/mouad4949/DAAB/internal/init/config"
-	configMicroservice "github.com/mouad4949/DAAB/internal/init/config/microservice"
-	configMonolith "github.com/mouad4949/DAAB/internal/init/config/monolith"
+	"github.com/mouad4949/DAAB/internal/cloud"
+	"github.com/mouad4949/DAAB/internal/defaults"
+	"github.com/mouad4949/DAAB/internal/fsutil"
+	"github.com/mouad4949/DAAB/internal/prompt"
+	config "github.com/mouad4949/DAAB/pkg/config"
+	configMicroservice "github.com/mouad4949/DAAB/pkg/config/microservice"
+	configMonolith "github.com/mouad4949/DAAB/pkg/config/monolith"
+	"github.com/mouad4949/DAAB/pkg/detect"
 	"gopkg.in/yaml.v3"
-	"os"
+	"io"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 )
 
 type Initializer struct {
 	projectPath string
 
+	// Environment selected with --env, used as the default environment
+	environment string
+
 	services []string
 
 	//Used just to detect port in getDefaultPort() by it's language
@@ -26,14 +38,46 @@ type Initializer struct {
 	ConfigMicroRoot *configMicroservice.ConfigMicroRoot
 
 	//Used to create a daab.yaml file for microservices projects on each microservice
-	ConfigMicro *configMicroservice.ConfigMicroservice
-	detector    *Detector
+	microservices []*microservice
+	detector      *detect.Detector
+
+	// Number of microservice folders detected concurrently
+	workers int
+
+	// Prompt defaults from the user and organisation defaults files
+	defaults *defaults.Defaults
+
+	// Asks the questions: a terminal, a script in tests, or defaults only with --non-interactive
+	prompter prompt.Prompter
+	// Receives the progress, the detection results and the summary
+	out io.Writer
+
+	// Answers to the project questions, kept as defaults when the questions are asked again
+	answers projectAnswers
+
+	// Entry point of the project, nil when it is not exposed outside the cluster
+	ingress *config.Ingress
+
+	// Whether the monolith was detected in the current pass of the questions
+	monolithDetected bool
+
+	// Print the files instead of writing them
+	dryRun bool
 }
 
-func NewInitializer(projectPath string) *Initializer {
+func NewInitializer(flags *InitFlags, prompter prompt.Prompter, out io.Writer) *Initializer {
+	workers := flags.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
 	return &Initializer{
-		projectPath: projectPath,
-		detector:    NewDetector(projectPath),
+		projectPath: flags.ProjectPath,
+		environment: flags.Env,
+		workers:     workers,
+		dryRun:      flags.DryRun,
+		prompter:    prompter,
+		out:         out,
+		detector:    detect.NewDetector(flags.ProjectPath),
 	}
 }
 
@@ -45,36 +89,67 @@ func (i *Initializer) Run() error {
 	// Initialize config with defaults
 	i.configmonolith = configMonolith.NewConfigMonolith()
 	i.ConfigMicroRoot = configMicroservice.NewConfigMicroRoot()
-	i.ConfigMicro = configMicroservice.NewConfigMicroservice()
 	i.baseconfigapp = config.NewBaseConfigApp()
 
-	// Step 1: Ask interactive questions (or use defaults)
-	if err := i.gatherUserInput(); err != nil {
+	userDefaults, err := defaults.Load()
+	if err != nil {
 		return err
 	}
+	i.defaults = userDefaults
 
-	//Step 2:gather information based on the project type
-	if i.configmonolith.ProjectType == "monolith" {
-		i.detectProjectMonolith()
-	} else {
-		i.DetectProjectMicroservice()
-	}
-	// Step 4: Validate configuration
-	if err := i.validateConfig(); err != nil {
-		return err
+	for {
+		// Step 1: Ask interactive questions (or use defaults), a monolith is detected
+		// and reviewed along the way
+		if err := i.gatherUserInput(); err != nil {
+			return err
+		}
+
+		//Step 2: detect and review every service of a microservice project
+		if i.ConfigMicroRoot.ProjectType == "microservice" {
+			if err := i.DetectProjectMicroservice(); err != nil {
+				return err
+			}
+		}
+		// Step 4: Validate configuration
+		if err := i.validateConfig(); err != nil {
+			return err
+		}
+
+		// Step 5: Let the user review everything before saving
+		confirmed, err := i.confirmSummary()
+		if err != nil {
+			return err
+		}
+		if confirmed {
+			break
+		}
+		fmt.Fprintln(i.out, "↩️  Let's go through the questions again, your previous answers are the new defaults.")
+		fmt.Fprintln(i.out)
 	}
 
-	// Step 5: Save configuration
+	// Step 6: Stage every file, then write them all or none
+	tx := fsutil.NewTransaction()
 	if i.ConfigMicroRoot.ProjectType == "microservice" {
-		if err := i.saveConfigMicroservice(); err != nil {
+		if err := i.saveConfigMicroservice(tx); err != nil {
 			return err
 		}
 	} else {
-		if err := i.saveConfig(); err != nil {
+		if err := i.saveConfig(tx); err != nil {
 			return err
 		}
 	}
 
+	if i.dryRun {
+		fmt.Fprintln(i.out)
+		fmt.Fprintln(i.out, "📝 Dry run, these files would be written:")
+		fmt.Fprintln(i.out)
+		tx.DryRun(i.out)
+		return nil
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save configuration, no file was changed: %w", err)
+	}
+
 	return nil
 }
 
@@ -82,123 +157,382 @@ func (i *Initializer) Run() error {
 /************AbstractUserInput************************/
 /****************************************************/
 
-func (i *Initializer) gatherUserInput() error {
+// projectAnswers holds the answers to the project questions until they are applied
+// to the monolith or microservice root config.
+type projectAnswers struct {
+	ProjectType       string
+	ProjectName       string
+	CloudProvider     string
+	Environment       string
+	Region            string
+	AccountID         string
+	ContainerRegistry string
+	Target            string
+	Namespace         string
+}
 
-	fmt.Println("📝 Please answer a few questions about your project:")
-	fmt.Println()
+// gatherUserInput asks the project questions, the ingress and, for a monolith, the
+// questions about the detected application, as one sequence so "<" goes back through
+// all of them.
+func (i *Initializer) gatherUserInput() error {
+	i.monolithDetected = false
+
+	fmt.Fprintln(i.out, "📝 Please answer a few questions about your project:")
+	fmt.Fprintln(i.out, "   (type ? for help, < to go back to the previous question)")
+	fmt.Fprintln(i.out)
+
+	a := &i.answers
+
+	// Values locked by the organisation are not asked
+	lockable := map[string]*string{
+		defaults.KeyProjectType:       &a.ProjectType,
+		defaults.KeyCloudProvider:     &a.CloudProvider,
+		defaults.KeyEnvironment:       &a.Environment,
+		defaults.KeyRegion:            &a.Region,
+		defaults.KeyAccountID:         &a.AccountID,
+		defaults.KeyContainerRegistry: &a.ContainerRegistry,
+		defaults.KeyNamespace:         &a.Namespace,
+		defaults.KeyTarget:            &a.Target,
+	}
+	for _, key := range i.defaults.Keys() {
+		if target, ok := lockable[key]; ok && i.defaults.IsLocked(key) {
+			*target = i.defaults.Get(key, "")
+			fmt.Fprintf(i.out, "  🔒 %s: %s (locked by organisation defaults)\n", key, *target)
+		}
+	}
 
-	// Project type
-	projectType, err := promptSelect(
-		"Project type",
-		[]string{"monolith", "microservice"},
-		"monolith",
-	)
-	if err != nil {
-		return err
+	steps := []prompt.Step{
+		{
+			Skip: i.isLocked(defaults.KeyProjectType),
+			Ask: func() error {
+				projectType, err := i.prompter.Select(
+					"Project type",
+					[]string{"monolith", "microservice"},
+					i.defaultFor(a.ProjectType, defaults.KeyProjectType, "monolith"),
+					prompt.Help("monolith: a single application in this folder. microservice: every subfolder is a service with its own daab.yaml."),
+				)
+				if err != nil {
+					return err
+				}
+				a.ProjectType = projectType
+				return nil
+			},
+		},
+		{
+			Ask: func() error {
+				question := "your monolith Project name"
+				if a.ProjectType == "microservice" {
+					question = "your microservices Project name"
+				}
+				projectName, err := i.prompter.String(
+					question,
+					i.defaultFor(a.ProjectName, "", i.getDefaultProjectName()),
+					prompt.Help("Used to name the Kubernetes resources and images, so it must be DNS-safe: lowercase letters, digits and '-'."),
+					prompt.Validate(prompt.DNSName),
+				)
+				if err != nil {
+					return err
+				}
+				a.ProjectName = projectName
+				return nil
+			},
+		},
+		{
+			Skip: i.isLocked(defaults.KeyCloudProvider),
+			Ask: func() error {
+				cloudProvider, err := i.prompter.Select(
+					"Cloud provider",
+					cloud.Providers(),
+					i.defaultFor(a.CloudProvider, defaults.KeyCloudProvider, "aws"),
+					prompt.Help("The cloud your application is deployed to. It decides the regions and the default container registry."),
+				)
+				if err != nil {
+					return err
+				}
+				a.CloudProvider = cloudProvider
+				return nil
+			},
+		},
+		{
+			Skip: i.isLocked(defaults.KeyTarget),
+			Ask: func() error {
+				targets := config.Targets(a.CloudProvider)
+				fallback := i.defaultFor(a.Target, defaults.KeyTarget, config.TargetKubernetes)
+				if config.ValidateTarget(a.CloudProvider, fallback) != nil {
+					fallback = config.TargetKubernetes
+				}
+				target, err := i.prompter.Select(
+					"Deployment target",
+					targets,
+					fallback,
+					prompt.Help("kubernetes: Deployments and Services in a cluster. The others run the image without a cluster: cloud-run (gcp), lambda and ecs-fargate (aws), container-apps (azure)."),
+				)
+				if err != nil {
+					return err
+				}
+				a.Target = target
+				return nil
+			},
+		},
+		{
+			Skip: i.isLocked(defaults.KeyEnvironment),
+			Ask: func() error {
+				// getDefaultEnvironment reads the defaults itself, after the --env flag
+				environment, err := i.prompter.String(
+					"Environment",
+					i.defaultFor(a.Environment, "", i.getDefaultEnvironment()),
+					prompt.Help("Name of the environment this config describes (development, staging, production). Others can be added as overlays later."),
+					prompt.Validate(config.ValidateEnvironmentName),
+				)
+				if err != nil {
+					return err
+				}
+				a.Environment = environment
+				return nil
+			},
+		},
+		{
+			Skip: i.isLocked(defaults.KeyRegion),
+			Ask: func() error {
+				region, err := i.prompter.Search(
+					"Region",
+					cloud.Regions(a.CloudProvider),
+					i.getDefaultRegion(a.CloudProvider),
+					prompt.Help(fmt.Sprintf("The %s region to deploy to. Type part of a name (e.g. \"west\") to filter the list.", a.CloudProvider)),
+					prompt.Validate(func(region string) error {
+						return cloud.ValidateRegion(a.CloudProvider, region)
+					}),
+				)
+				if err != nil {
+					return err
+				}
+				a.Region = region
+				return nil
+			},
+		},
+		{
+			Skip: i.isLocked(defaults.KeyAccountID),
+			Ask: func() error {
+				accountID, err := i.prompter.String(
+					accountQuestion(a.CloudProvider),
+					i.defaultFor(a.AccountID, defaults.KeyAccountID, ""),
+					prompt.Help("Used to derive the default container registry. Leave empty to enter the registry yourself."),
+					prompt.Validate(prompt.Optional(func(account string) error {
+						return cloud.ValidateAccountID(a.CloudProvider, account)
+					})),
+				)
+				if err != nil {
+					return err
+				}
+				a.AccountID = accountID
+				return nil
+			},
+		},
+		{
+			// For microservices this is the registry inherited by every service
+			Skip: i.isLocked(defaults.KeyContainerRegistry),
+			Ask: func() error {
+				registry, err := i.askRegistry(a.CloudProvider, a.Region, a.AccountID, a.ProjectName, a.ContainerRegistry)
+				if err != nil {
+					return err
+				}
+				a.ContainerRegistry = registry
+				return nil
+			},
+		},
+		{
+			// Only clusters have namespaces
+			Skip: func() bool { return i.defaults.IsLocked(defaults.KeyNamespace) || !config.IsKubernetes(a.Target) },
+			Ask: func() error {
+				namespace, err := i.prompter.String(
+					"Kubernetes namespace",
+					i.defaultFor(a.Namespace, defaults.KeyNamespace, "default"),
+					prompt.Help("The namespace the workloads are deployed to."),
+					prompt.Validate(prompt.DNSName),
+				)
+				if err != nil {
+					return err
+				}
+				a.Namespace = namespace
+				return nil
+			},
+		},
 	}
 
-	if projectType == "microservice" {
-		//Project Type
-		i.ConfigMicroRoot.ProjectType = projectType
-		// Project name microservice
-		projectName, err := promptString("your microservices Project name", i.getDefaultProjectName())
-		if err != nil {
-			return err
-		}
-		i.ConfigMicroRoot.ProjectName = projectName
+	ingressSteps, applyIngress := i.ingressSteps()
+	steps = append(steps, ingressSteps...)
+	steps = append(steps, i.monolithSteps()...)
 
-		// Cloud provider
-		cloudProvider, err := promptSelect(
-			"Cloud provider",
-			[]string{"aws", "gcp", "azure"},
-			"aws",
-		)
-		if err != nil {
-			return err
-		}
-		i.ConfigMicroRoot.CloudProvider = cloudProvider
+	if err := prompt.RunSteps(steps); err != nil {
+		return err
+	}
+	if !config.IsKubernetes(a.Target) && !i.defaults.IsLocked(defaults.KeyNamespace) {
+		a.Namespace = ""
+	}
+	i.applyAnswers()
+	applyIngress()
 
-		// Environment
-		environment, err := promptString("Environment", "production")
-		if err != nil {
-			return err
-		}
-		i.ConfigMicroRoot.Environment = environment
+	fmt.Fprintln(i.out)
+	return nil
+}
 
-		//region
-		region, err := promptString("Region", "")
-		if err != nil {
-			return err
-		}
-		i.ConfigMicroRoot.Region = region
+// applyAnswers copies the project answers to the config of the chosen project type.
+func (i *Initializer) applyAnswers() {
+	a := i.answers
+	if a.ProjectType == "microservice" {
+		i.configmonolith.ProjectType = ""
+		i.ConfigMicroRoot.ProjectType = a.ProjectType
+		i.ConfigMicroRoot.ProjectName = a.ProjectName
+		i.ConfigMicroRoot.CloudProvider = a.CloudProvider
+		i.ConfigMicroRoot.Environment = a.Environment
+		i.ConfigMicroRoot.Region = a.Region
+		i.ConfigMicroRoot.AccountID = a.AccountID
+		i.ConfigMicroRoot.ContainerRegistry = a.ContainerRegistry
+		i.ConfigMicroRoot.Target = a.Target
+		i.ConfigMicroRoot.Namespace = a.Namespace
+		return
+	}
 
-		//namespace
-		namespace, err := promptString("Kubernetes namespace", "default")
-		if err != nil {
-			return err
-		}
-		i.ConfigMicroRoot.Namespace = namespace
+	i.ConfigMicroRoot.ProjectType = ""
+	i.configmonolith.ProjectType = a.ProjectType
+	i.configmonolith.ProjectName = a.ProjectName
+	i.configmonolith.CloudProvider = a.CloudProvider
+	i.configmonolith.Environment = a.Environment
+	i.configmonolith.Region = a.Region
+	i.configmonolith.AccountID = a.AccountID
+	i.configmonolith.ContainerRegistry = a.ContainerRegistry
+	i.configmonolith.Target = a.Target
+	i.configmonolith.Namespace = a.Namespace
+}
 
+// confirmSummary prints everything that is about to be saved and asks for confirmation.
+func (i *Initializer) confirmSummary() (bool, error) {
+	a := i.answers
+	fmt.Fprintln(i.out)
+	fmt.Fprintln(i.out, "📋 Summary:")
+	fmt.Fprintf(i.out, "   Project type:       %s\n", a.ProjectType)
+	fmt.Fprintf(i.out, "   Project name:       %s\n", a.ProjectName)
+	fmt.Fprintf(i.out, "   Cloud provider:     %s\n", a.CloudProvider)
+	fmt.Fprintf(i.out, "   Environment:        %s\n", a.Environment)
+	fmt.Fprintf(i.out, "   Region:             %s\n", a.Region)
+	fmt.Fprintf(i.out, "   Account id:         %s\n", orNone(a.AccountID))
+	fmt.Fprintf(i.out, "   Target:             %s\n", a.Target)
+	if config.IsKubernetes(a.Target) {
+		fmt.Fprintf(i.out, "   Namespace:          %s\n", a.Namespace)
+	}
+	fmt.Fprintf(i.out, "   Container registry: %s\n", orNone(a.ContainerRegistry))
+	if config.IsKubernetes(a.Target) {
+		fmt.Fprintf(i.out, "   Ingress:            %s\n", describeIngress(i.ingress))
+	}
+	if a.ProjectType == "microservice" {
+		fmt.Fprintln(i.out)
+		i.printMicroservices()
 	} else {
-		//Project Type
-		i.configmonolith.ProjectType = projectType
-		// Project name monolith
-		projectName, err := promptString("your monolith Project name", i.getDefaultProjectName())
-		if err != nil {
-			return err
-		}
-		i.configmonolith.ProjectName = projectName
-		// Cloud provider
-		cloudProvider, err := promptSelect(
-			"Cloud provider",
-			[]string{"aws", "gcp", "azure"},
-			"aws",
-		)
-		if err != nil {
-			return err
+		framework := ""
+		if i.configmonolith.Framework != "" {
+			framework = " (" + i.configmonolith.Framework + ")"
 		}
-		i.configmonolith.CloudProvider = cloudProvider
+		fmt.Fprintf(i.out, "   Language:           %s%s\n", i.configmonolith.Language, framework)
+		fmt.Fprintf(i.out, "   Port:               %d\n", i.configmonolith.Port)
+		fmt.Fprintf(i.out, "   Resources:          %s\n", describeResources(config.MergeResources(config.DefaultResources(i.configmonolith.Language), i.configmonolith.Resources)))
+		fmt.Fprintf(i.out, "   Scaling:            %s\n", describeScaling(&i.configmonolith.BaseConfigApp))
+	}
+	fmt.Fprintln(i.out)
 
-		// Environment
-		environment, err := promptString("Environment", "production")
-		if err != nil {
-			return err
-		}
-		i.configmonolith.Environment = environment
+	confirmed, err := i.prompter.Confirm("Save this configuration?", true,
+		prompt.Help("Answer no to go through the questions again with these answers as defaults."))
+	if errors.Is(err, prompt.ErrBack) {
+		return false, nil
+	}
+	return confirmed, err
+}
 
-		//region
-		region, err := promptString("Region", "")
-		if err != nil {
-			return err
-		}
-		i.configmonolith.Region = region
+func orNone(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
 
-		// Container registry
-		registry, err := promptString("Container registry (leave empty for default)", "")
-		if err != nil {
-			return err
-		}
-		i.configmonolith.ContainerRegistry = registry
+func (i *Initializer) isLocked(key string) func() bool {
+	return func() bool {
+		return i.defaults.IsLocked(key)
+	}
+}
 
-		// Kubernetes namespace
-		namespace, err := promptString("Kubernetes namespace", "default")
+// defaultFor returns the previous answer, else the user/organisation default for key,
+// else fallback.
+func (i *Initializer) defaultFor(previous, key, fallback string) string {
+	if previous != "" {
+		return previous
+	}
+	if key == "" {
+		return fallback
+	}
+	return i.defaults.Get(key, fallback)
+}
+
+// askRegistry proposes the registry derived from the cloud account and validates the answer.
+// An empty answer keeps the registry unset.
+func (i *Initializer) askRegistry(provider, region, accountID, projectName, previous string) (string, error) {
+	fallback := previous
+	if fallback == "" {
+		fallback = i.defaults.Get(defaults.KeyContainerRegistry, "")
+	}
+	if fallback == "" && accountID != "" {
+		derived, err := cloud.DefaultRegistry(provider, region, accountID, projectName)
 		if err != nil {
-			return err
+			fmt.Fprintf(i.out, "  ⚠️  Could not derive the default registry: %v\n", err)
+		} else {
+			fallback = derived
 		}
-		i.configmonolith.Namespace = namespace
+	}
 
+	registry, err := i.prompter.String(
+		"Container registry (leave empty for default)",
+		fallback,
+		prompt.Help("Where images are pushed: ECR, Artifact Registry, ACR, Docker Hub (docker.io/<namespace>), ghcr.io/<owner>, quay.io/<org> or a self-hosted host[:port]/path."),
+		prompt.Validate(prompt.Optional(cloud.ValidateRegistry)),
+	)
+	if err != nil || registry == "" {
+		return "", err
 	}
 
-	fmt.Println()
-	return nil
+	parsed, err := cloud.ParseRegistry(registry)
+	if err != nil {
+		return "", err
+	}
+	return parsed.String(), nil
 }
 
 /******************************************************/
 /************detectProjectMonolith********************/
 /****************************************************/
 
+// monolithSteps asks the port and the workload of a monolith. The folder is detected
+// before the first of them, once per pass.
+func (i *Initializer) monolithSteps() []prompt.Step {
+	port := i.portStep(&i.configmonolith.Port)
+	askPort := port.Ask
+	port.Ask = func() error {
+		if err := i.detectProjectMonolith(); err != nil {
+			return err
+		}
+		return askPort()
+	}
+
+	steps := append([]prompt.Step{port}, i.workloadSteps(&i.configmonolith.BaseConfigApp)...)
+	return when(func() bool { return i.answers.ProjectType == "monolith" }, steps)
+}
+
+// detectProjectMonolith detects the language of the project folder, unless it was
+// already detected in this pass.
 func (i *Initializer) detectProjectMonolith() error {
-	fmt.Println("🔍 Detecting project type...")
+	if i.monolithDetected {
+		return nil
+	}
+	fmt.Fprintln(i.out)
+	fmt.Fprintln(i.out, "🔍 Detecting project type...")
 	result, err := i.detector.Detect()
 	if err != nil {
 		return fmt.Errorf("failed to detect project: %w", err)
@@ -208,105 +542,632 @@ func (i *Initializer) detectProjectMonolith() error {
 	i.configmonolith.Framework = result.Framework
 	i.configmonolith.DetectedFiles = result.DetectedFiles
 	i.baseconfigapp.Language = i.configmonolith.Language
-	port, err := promptInt("Application port", i.getDefaultPort())
-	if err != nil {
-		return err
+	if i.configmonolith.Port == 0 {
+		i.configmonolith.Port = i.getDefaultPort()
 	}
-	i.configmonolith.Port = port
-	fmt.Printf("   Language: %s\n", result.Language)
+	fmt.Fprintf(i.out, "   Language: %s\n", result.Language)
 	if result.Framework != "" {
-		fmt.Printf("   Framework: %s\n", result.Framework)
+		fmt.Fprintf(i.out, "   Framework: %s\n", result.Framework)
 	}
-
+	i.monolithDetected = true
 	return nil
 }
 
+// when makes steps apply only while cond holds.
+func when(cond func() bool, steps []prompt.Step) []prompt.Step {
+	for idx := range steps {
+		skip := steps[idx].Skip
+		steps[idx].Skip = func() bool {
+			return !cond() || (skip != nil && skip())
+		}
+	}
+	return steps
+}
+
 /******************************************************/
 /************DetectProjectMicroservice****************/
 /****************************************************/
 
-func (i *Initializer) DetectSubfolders(root string) ([]string, error) {
-
-	var subfolders []string
+func (i *Initializer) DetectProjectMicroservice() error {
+	fmt.Fprintln(i.out, "🔍 Detecting Microservices technologies stack...")
+	i.services = nil
 
-	entries, err := os.ReadDir(root)
+	candidates, err := detect.CandidateFolders(i.projectPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read directory: %w", err)
+		return fmt.Errorf("error in detecting subfolders: %w", err)
 	}
+	fmt.Fprintf(i.out, "📁 Found %d subfolders, detecting with %d workers...\n", len(candidates), i.workers)
+
+	previous := map[string]*microservice{}
+	for _, svc := range i.microservices {
+		previous[svc.path] = svc
+	}
+	i.microservices = nil
+
+	root := i.ConfigMicroRoot
+	for _, detection := range detect.DetectAll(candidates, i.workers) {
+		if detection.Err != nil {
+			fmt.Fprintf(i.out, "❌ Detection failed for %s: %v\n", detection.Path, detection.Err)
+			continue
+		}
 
-	for _, entry := range entries {
-		if entry.IsDir() {
-			subfolders = append(subfolders, filepath.Join(root, entry.Name()))
+		ref, err := configMicroservice.NewServiceRef(i.projectPath, detection.Path)
+		if err != nil {
+			return err
+		}
+
+		svc := configMicroservice.NewConfigMicroservice()
+		svc.ProjectName = ref.Name
+		svc.ProjectType = root.ProjectType
+		svc.Language = detection.Result.Language
+		svc.Framework = detection.Result.Framework
+		svc.DetectedFiles = detection.Result.DetectedFiles
+		svc.Port = detect.DefaultPort(svc.Language)
+
+		//cloud, the other cloud settings are inherited from daab.root.yaml
+		svc.CloudProvider = root.CloudProvider
+
+		// Keep the values reviewed in a previous pass
+		if old, ok := previous[detection.Path]; ok {
+			svc.Port = old.config.Port
+			svc.ContainerRegistry = old.config.ContainerRegistry
+			svc.Resources = old.config.Resources
+			svc.Replicas = old.config.Replicas
+			svc.Autoscaling = old.config.Autoscaling
+			svc.PathPrefix = old.config.PathPrefix
+			ref.DependsOn = old.ref.DependsOn
+		} else if i.ingress != nil {
+			svc.PathPrefix = "/" + ref.Name
+		}
+		if i.ingress == nil {
+			svc.PathPrefix = ""
 		}
+
+		i.microservices = append(i.microservices, &microservice{path: detection.Path, ref: ref, config: svc})
+		i.services = append(i.services, detection.Path)
 	}
 
-	return subfolders, nil
-}
+	if err := i.inferDependencies(); err != nil {
+		return err
+	}
 
-func (i *Initializer) DetectProjectMicroservice() error {
-	fmt.Println("🔍 Detecting Microservices technologies stack...")
+	fmt.Fprintln(i.out)
+	if err := i.reviewMicroservices(); err != nil {
+		return err
+	}
+
+	i.ConfigMicroRoot.Services = nil
+	for _, svc := range i.microservices {
+		i.ConfigMicroRoot.Services = append(i.ConfigMicroRoot.Services, svc.ref)
+	}
 
-	folders, err := i.DetectSubfolders(i.projectPath)
+	fmt.Fprintln(i.out)
 
+	return nil
+}
+
+// inferDependencies adds the dependencies between services found in their code and the
+// Docker Compose file to the depends_on of the services.
+func (i *Initializer) inferDependencies() error {
+	refs := make([]configMicroservice.ServiceRef, 0, len(i.microservices))
+	for _, svc := range i.microservices {
+		refs = append(refs, svc.ref)
+	}
+	dependencies, err := configMicroservice.DependencyGraph(refs)
 	if err != nil {
-		return fmt.Errorf("Error in detecting subfolders: ", err)
+		// Dependencies of a previous pass may name services that are gone
+		for _, svc := range i.microservices {
+			svc.ref.DependsOn = nil
+		}
+		refs = nil
+		for _, svc := range i.microservices {
+			refs = append(refs, svc.ref)
+		}
+		if dependencies, err = configMicroservice.DependencyGraph(refs); err != nil {
+			return err
+		}
 	}
 
-	fmt.Printf("📁 Found %d subfolders:\n", len(folders))
-	for _, folder := range folders {
-		fmt.Println(" -", folder)
+	inferred, err := detect.InferEdges(i.projectPath, refs)
+	if err != nil {
+		fmt.Fprintf(i.out, "⚠️  Could not infer the dependencies between services: %v\n", err)
+		return nil
+	}
+	for _, edge := range dependencies.Merge(inferred) {
+		fmt.Fprintf(i.out, "⚠️  Skipping %s -> %s (%s): it would close a dependency cycle\n", edge.From, edge.To, edge.Source)
+	}
+	for _, edge := range dependencies.Edges() {
+		if edge.Inferred() {
+			fmt.Fprintf(i.out, "🔗 %s depends on %s (%s)\n", edge.From, edge.To, edge.Source)
+		}
 	}
+	for _, svc := range i.microservices {
+		svc.ref.DependsOn = dependencies.DependsOn(svc.ref.Name)
+	}
+	return nil
+}
 
-	for _, folder := range folders {
-		if strings.HasPrefix(folder, ".") {
-			continue
+// microservice is a detected service folder and the config that will be written to it.
+type microservice struct {
+	path   string
+	ref    configMicroservice.ServiceRef
+	config *configMicroservice.ConfigMicroservice
+}
+
+// printMicroservices shows the detected services as a table. Registries set on the
+// service itself, rather than inherited from the root, are marked as overrides.
+func (i *Initializer) printMicroservices() {
+	w := tabwriter.NewWriter(i.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "   #\tSERVICE\tLANGUAGE\tFRAMEWORK\tPORT\tSCALING\tPATH\tREGISTRY")
+	for idx, svc := range i.microservices {
+		registry := orNone(i.ConfigMicroRoot.ContainerRegistry)
+		if svc.config.ContainerRegistry != "" {
+			registry = svc.config.ContainerRegistry + " (override)"
 		}
-		i.services = append(i.services, folder)
-		reserve := i.projectPath
-		i.projectPath = folder
-		i.detector.projectPath = folder
+		fmt.Fprintf(w, "   %d\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", idx+1, svc.ref.Name,
+			svc.config.Language, orNone(svc.config.Framework), svc.config.Port, describeScaling(&svc.config.BaseConfigApp),
+			orNone(svc.config.PathPrefix), registry)
+	}
+	w.Flush()
+}
 
-		result, err := i.detector.Detect()
-		if err != nil {
-			fmt.Printf("❌ Detection failed for %s: %v\n", folder, err)
+// reviewMicroservices shows every detected service at once and lets the user edit
+// single services, or the registry of all of them, before anything is written.
+func (i *Initializer) reviewMicroservices() error {
+	if len(i.microservices) == 0 {
+		return nil
+	}
+
+	for {
+		i.printMicroservices()
+		fmt.Fprintln(i.out)
+
+		answer, err := i.prompter.String(
+			"Service to edit (number or name, * to change the shared registry, empty to continue)",
+			"",
+			prompt.Help("Pick a service to change its port, resources, scaling and path prefix, or override the registry it inherits from daab.root.yaml. Press enter when the table is correct."),
+			prompt.Validate(func(answer string) error {
+				if answer == "" || answer == "*" || i.findMicroservice(answer) != nil {
+					return nil
+				}
+				return fmt.Errorf("no service %q", answer)
+			}),
+		)
+		if errors.Is(err, prompt.ErrBack) {
 			continue
 		}
-
-		fmt.Printf("✅ %s detected as %s (%s)\n", folder, result.Language, result.Framework)
-		i.ConfigMicro.ProjectName = folder
-		i.ConfigMicro.ProjectType = i.ConfigMicroRoot.ProjectType
-		i.ConfigMicro.Language = result.Language
-		i.ConfigMicro.Framework = result.Framework
-		i.ConfigMicro.DetectedFiles = result.DetectedFiles
-		i.baseconfigapp.Language = i.ConfigMicro.Language
-		port, err := promptInt("Application port", i.getDefaultPort())
 		if err != nil {
 			return err
 		}
 
-		i.ConfigMicro.Port = port
+		switch answer {
+		case "":
+			return nil
+		case "*":
+			if err := i.editAllRegistries(); err != nil && !errors.Is(err, prompt.ErrBack) {
+				return err
+			}
+		default:
+			if err := i.editMicroservice(i.findMicroservice(answer)); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintln(i.out)
+	}
+}
+
+func (i *Initializer) findMicroservice(answer string) *microservice {
+	if idx, err := strconv.Atoi(answer); err == nil {
+		if idx >= 1 && idx <= len(i.microservices) {
+			return i.microservices[idx-1]
+		}
+		return nil
+	}
+	for _, svc := range i.microservices {
+		if svc.path == answer || svc.ref.Matches(answer) {
+			return svc
+		}
+	}
+	return nil
+}
+
+func (i *Initializer) editMicroservice(svc *microservice) error {
+	root := i.ConfigMicroRoot
+	fmt.Fprintf(i.out, "✏️  Editing %s\n", svc.ref.Name)
+	steps := []prompt.Step{
+		i.portStep(&svc.config.Port),
+		{
+			Ask: func() error {
+				current := svc.config.ContainerRegistry
+				if current == "" {
+					current = root.ContainerRegistry
+				}
+				registry, err := i.askRegistry(root.CloudProvider, root.Region, root.AccountID, root.ProjectName, current)
+				if err != nil {
+					return err
+				}
+				// Only keep an override when it differs from the shared registry
+				svc.config.ContainerRegistry = ""
+				if registry != root.ContainerRegistry {
+					svc.config.ContainerRegistry = registry
+				}
+				return nil
+			},
+		},
+	}
+	steps = append(steps, i.workloadSteps(&svc.config.BaseConfigApp)...)
+	steps = append(steps, prompt.Step{
+		Skip: func() bool { return i.ingress == nil },
+		Ask: func() error {
+			prefix, err := i.prompter.String("Path prefix (empty to keep the service internal)", svc.config.PathPrefix,
+				prompt.Help("Requests to the ingress starting with this path are sent to the service, e.g. /users."),
+				prompt.Validate(prompt.Optional(config.ValidatePathPrefix)))
+			if err != nil {
+				return err
+			}
+			svc.config.PathPrefix = prefix
+			return nil
+		},
+	})
+	return prompt.RunSteps(steps)
+}
 
-		//cloud
-		i.ConfigMicro.CloudProvider = i.ConfigMicroRoot.CloudProvider
+// editAllRegistries changes the registry shared by all services and drops their overrides.
+func (i *Initializer) editAllRegistries() error {
+	root := i.ConfigMicroRoot
+	registry, err := i.askRegistry(root.CloudProvider, root.Region, root.AccountID, root.ProjectName, root.ContainerRegistry)
+	if err != nil {
+		return err
+	}
+	root.ContainerRegistry = registry
+	i.answers.ContainerRegistry = registry
+	for _, svc := range i.microservices {
+		svc.config.ContainerRegistry = ""
+	}
+	return nil
+}
 
-		// Container registry
-		registry, err := promptString("Container registry (leave empty for default)", "")
-		if err != nil {
-			return err
+/******************************************************/
+/************Ingress***********************************/
+/****************************************************/
+
+// TLS choices of the ingress questions.
+const (
+	tlsNone        = "none"
+	tlsCertManager = "cert-manager"
+	tlsSecret      = "secret"
+)
+
+// ingressSteps optionally ask how the traffic reaches the project: an Ingress or Gateway
+// API routes, the hosts and TLS. Microservices get a path prefix each, see editMicroservice.
+// The returned function applies the answers once the steps are done.
+func (i *Initializer) ingressSteps() ([]prompt.Step, func()) {
+	previous := i.ingress
+	expose := previous != nil
+	ingress := &config.Ingress{Type: config.IngressTypeIngress, Class: "nginx"}
+	if previous != nil {
+		copied := *previous
+		ingress = &copied
+		ingress.Type = previous.Kind()
+	}
+	gateway := ""
+	if ingress.Gateway != nil {
+		gateway = ingress.Gateway.Name
+		if ingress.Gateway.Namespace != "" {
+			gateway = ingress.Gateway.Namespace + "/" + gateway
 		}
-		i.ConfigMicro.ContainerRegistry = registry
+	}
+	tlsMode, issuer, secret := tlsNone, "letsencrypt", ""
+	if tls := ingress.TLS; tls != nil {
+		tlsMode, secret = tlsSecret, tls.Secret
+		if tls.Issuer != "" {
+			tlsMode, issuer = tlsCertManager, tls.Issuer
+		}
+	}
 
-		if err := i.saveConfig(); err != nil {
-			fmt.Println("error in creating daab.yaml in this project:", folder)
-			return err
+	// Serverless targets are exposed by their platform
+	kubernetes := func() bool { return config.IsKubernetes(i.answers.Target) }
+	exposed := func() bool { return expose && kubernetes() }
+	skip := func() bool { return !exposed() }
+	isGateway := func() bool { return ingress.Type == config.IngressTypeGateway }
+	steps := []prompt.Step{
+		{
+			Skip: func() bool { return !kubernetes() },
+			Ask: func() error {
+				answer, err := i.prompter.Confirm("Expose the project outside the cluster with an ingress?", expose,
+					prompt.Help("Creates a single entry point for the project: an Ingress, or Gateway API routes, with your domain names and TLS."))
+				expose = answer
+				return err
+			},
+		},
+		{
+			Skip: skip,
+			Ask: func() error {
+				answer, err := i.prompter.Select("Routing API", []string{config.IngressTypeIngress, config.IngressTypeGateway}, ingress.Type,
+					prompt.Help("ingress: a networking.k8s.io Ingress served by an ingress controller. gateway: HTTPRoutes of the Gateway API."))
+				ingress.Type = answer
+				return err
+			},
+		},
+		{
+			Skip: func() bool { return !exposed() || isGateway() },
+			Ask: func() error {
+				answer, err := i.prompter.String("Ingress class", ingress.Class,
+					prompt.Help("The IngressClass of your ingress controller, e.g. nginx or traefik. Empty for the default class of the cluster."))
+				ingress.Class = answer
+				return err
+			},
+		},
+		{
+			Skip: func() bool { return !exposed() || !isGateway() },
+			Ask: func() error {
+				answer, err := i.prompter.String("Existing Gateway (namespace/name, empty to create one)", gateway,
+					prompt.Help("Attach the routes to a Gateway managed by your platform team, or leave empty and daab creates one for the project."))
+				gateway = answer
+				return err
+			},
+		},
+		{
+			Skip: func() bool { return !exposed() || !isGateway() || gateway != "" },
+			Ask: func() error {
+				answer, err := i.prompter.String("GatewayClass of the new Gateway", ingress.Class,
+					prompt.Help("The GatewayClass of your Gateway API implementation, e.g. istio, cilium or eg (Envoy Gateway)."),
+					prompt.Validate(prompt.NotEmpty))
+				ingress.Class = answer
+				return err
+			},
+		},
+		{
+			Skip: skip,
+			Ask: func() error {
+				answer, err := i.prompter.String("Hosts (comma separated, empty for every host)", strings.Join(ingress.Hosts, ","),
+					prompt.Help("The domain names of the project, e.g. api.example.com. Their DNS records must point to the ingress controller or the Gateway."),
+					prompt.Validate(func(hosts string) error {
+						return (&config.Ingress{Hosts: splitList(hosts)}).Validate()
+					}))
+				ingress.Hosts = splitList(answer)
+				return err
+			},
+		},
+		{
+			Skip: func() bool { return !exposed() || len(ingress.Hosts) == 0 || (isGateway() && gateway != "") },
+			Ask: func() error {
+				answer, err := i.prompter.Select("TLS", []string{tlsNone, tlsCertManager, tlsSecret}, tlsMode,
+					prompt.Help("cert-manager: a certificate issued and renewed by a cert-manager ClusterIssuer. secret: a certificate you store in a Kubernetes secret."))
+				tlsMode = answer
+				return err
+			},
+		},
+		{
+			Skip: func() bool { return !exposed() || tlsMode != tlsCertManager },
+			Ask: func() error {
+				answer, err := i.prompter.String("cert-manager ClusterIssuer", issuer,
+					prompt.Help("The ClusterIssuer issuing the certificate, e.g. letsencrypt."),
+					prompt.Validate(prompt.NotEmpty))
+				issuer = answer
+				return err
+			},
+		},
+		{
+			Skip: func() bool { return !exposed() || tlsMode != tlsSecret },
+			Ask: func() error {
+				answer, err := i.prompter.String("TLS secret", secret,
+					prompt.Help("The kubernetes.io/tls secret holding the certificate, in the namespace of the routes."),
+					prompt.Validate(prompt.DNSName))
+				secret = answer
+				return err
+			},
+		},
+	}
+
+	apply := func() {
+		i.ingress = nil
+		if exposed() {
+			ingress.Gateway, ingress.TLS = nil, nil
+			if isGateway() && gateway != "" {
+				ref := &config.GatewayRef{Name: gateway}
+				if namespace, name, ok := strings.Cut(gateway, "/"); ok {
+					ref.Namespace, ref.Name = namespace, name
+				}
+				ingress.Gateway, ingress.Class = ref, ""
+			} else if len(ingress.Hosts) > 0 {
+				switch tlsMode {
+				case tlsCertManager:
+					ingress.TLS = &config.TLS{Issuer: issuer}
+				case tlsSecret:
+					ingress.TLS = &config.TLS{Secret: secret}
+				}
+			}
+			if ingress.Type == config.IngressTypeIngress {
+				ingress.Type = ""
+			}
+			i.ingress = ingress
+		}
+
+		i.configmonolith.Ingress, i.ConfigMicroRoot.Ingress = nil, nil
+		if i.answers.ProjectType == "microservice" {
+			i.ConfigMicroRoot.Ingress = i.ingress
+		} else {
+			i.configmonolith.Ingress = i.ingress
+		}
+	}
+	return steps, apply
+}
+
+// describeIngress summarises the entry point, e.g. "nginx ingress, api.example.com (TLS)".
+func describeIngress(ingress *config.Ingress) string {
+	if ingress == nil {
+		return "-"
+	}
+	description := ingress.Kind()
+	if ingress.Class != "" {
+		description = ingress.Class + " " + description
+	}
+	if ingress.Gateway != nil {
+		description += " " + ingress.Gateway.Name
+	}
+	if len(ingress.Hosts) > 0 {
+		description += ", " + strings.Join(ingress.Hosts, ", ")
+	}
+	if ingress.TLS != nil {
+		description += " (TLS)"
+	}
+	return description
+}
+
+// splitList splits a comma separated answer, dropping empty items.
+func splitList(answer string) []string {
+	var items []string
+	for _, item := range strings.Split(answer, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+/******************************************************/
+/************Workload**********************************/
+/****************************************************/
+
+// workloadSteps optionally ask for the resources and the scaling of an application,
+// proposing the defaults of its language. Unanswered, the defaults apply at generation.
+// Every answer is applied to app at once, so the steps can be part of a longer sequence.
+func (i *Initializer) workloadSteps(app *config.BaseConfigApp) []prompt.Step {
+	var (
+		initialized       bool
+		resources         *config.Resources
+		replicas          int
+		autoscaling       *config.Autoscaling
+		customize         bool
+		enableAutoscaling bool
+	)
+	// The language of a monolith is only known once the first step is asked
+	initialize := func() {
+		if initialized {
+			return
+		}
+		initialized = true
+		resources = config.MergeResources(config.DefaultResources(app.Language), app.Resources)
+		replicas = max(app.Replicas, 1)
+		autoscaling = app.Autoscaling
+		if autoscaling == nil {
+			autoscaling = &config.Autoscaling{MinReplicas: 2, MaxReplicas: 5, TargetCPU: 70}
+		}
+		customize = app.Resources != nil || app.Replicas != 0 || app.Autoscaling != nil
+		enableAutoscaling = app.Autoscaling != nil
+	}
+	apply := func() {
+		if !customize {
+			app.Resources, app.Replicas, app.Autoscaling = nil, 0, nil
+			return
+		}
+		app.Resources = resources
+		app.Replicas, app.Autoscaling = replicas, nil
+		if enableAutoscaling {
+			app.Replicas, app.Autoscaling = 0, autoscaling
 		}
-		i.projectPath = reserve
 	}
 
-	i.ConfigMicroRoot.DetectedMicroservices = i.services
+	askString := func(question string, target func() *string, help string) prompt.Step {
+		return prompt.Step{
+			Skip: func() bool { return !customize },
+			Ask: func() error {
+				answer, err := i.prompter.String(question, *target(), prompt.Help(help), prompt.Validate(validateQuantity))
+				if err != nil {
+					return err
+				}
+				*target() = answer
+				apply()
+				return nil
+			},
+		}
+	}
+	askInt := func(question string, target func() *int, skip func() bool, help string) prompt.Step {
+		return prompt.Step{
+			Skip: skip,
+			Ask: func() error {
+				answer, err := i.prompter.Int(question, *target(), prompt.Help(help), prompt.Validate(positive))
+				if err != nil {
+					return err
+				}
+				*target() = answer
+				apply()
+				return nil
+			},
+		}
+	}
+	withoutAutoscaling := func() bool { return !customize || enableAutoscaling }
+	withAutoscaling := func() bool { return !customize || !enableAutoscaling }
+
+	return []prompt.Step{
+		{
+			Ask: func() error {
+				initialize()
+				answer, err := i.prompter.Confirm("Set CPU, memory and replicas now?", customize,
+					prompt.Help(fmt.Sprintf("Otherwise the defaults for %s apply: %s, 1 replica. They can be changed in daab.yaml later.", orNone(app.Language), describeResources(resources))))
+				if err != nil {
+					return err
+				}
+				customize = answer
+				apply()
+				return nil
+			},
+		},
+		askString("CPU request", func() *string { return &resources.Requests.CPU }, "CPU reserved for each pod, in cores (\"1\") or millicores (\"250m\")."),
+		askString("Memory request", func() *string { return &resources.Requests.Memory }, "Memory reserved for each pod, e.g. \"256Mi\" or \"1Gi\"."),
+		askString("CPU limit", func() *string { return &resources.Limits.CPU }, "Maximum CPU of each pod, it is throttled above."),
+		askString("Memory limit", func() *string { return &resources.Limits.Memory }, "Maximum memory of each pod, it is restarted above. JVM and .NET applications need headroom over their heap."),
+		{
+			Skip: func() bool { return !customize },
+			Ask: func() error {
+				answer, err := i.prompter.Confirm("Enable autoscaling?", enableAutoscaling,
+					prompt.Help("Scale the number of pods with the CPU usage, between a minimum and a maximum."))
+				if err != nil {
+					return err
+				}
+				enableAutoscaling = answer
+				apply()
+				return nil
+			},
+		},
+		askInt("Replicas", func() *int { return &replicas }, withoutAutoscaling, "Number of pods running the application."),
+		askInt("Minimum replicas", func() *int { return &autoscaling.MinReplicas }, withAutoscaling, "Pods kept running when the load is low."),
+		askInt("Maximum replicas", func() *int { return &autoscaling.MaxReplicas }, withAutoscaling, "Pods running at most when the load is high."),
+		askInt("Target CPU usage (% of the request)", func() *int { return &autoscaling.TargetCPU }, withAutoscaling, "Pods are added above this average CPU usage and removed below."),
+	}
+}
+
+// describeResources summarises requests and limits, e.g. "100m/128Mi up to 500m/512Mi".
+func describeResources(resources *config.Resources) string {
+	if resources == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s/%s up to %s/%s", resources.Requests.CPU, resources.Requests.Memory, resources.Limits.CPU, resources.Limits.Memory)
+}
 
-	fmt.Println()
+// describeScaling summarises the replicas or the autoscaling of an application.
+func describeScaling(app *config.BaseConfigApp) string {
+	if app.Autoscaling != nil {
+		return fmt.Sprintf("%d to %d pods", app.Autoscaling.MinReplicas, app.Autoscaling.MaxReplicas)
+	}
+	if app.Replicas > 1 {
+		return fmt.Sprintf("%d pods", app.Replicas)
+	}
+	return "1 pod"
+}
 
+func validateQuantity(value string) error {
+	if quantity, err := config.ParseQuantity(value); err != nil || quantity <= 0 {
+		return fmt.Errorf("invalid quantity %q, use e.g. \"250m\", \"1\" or \"512Mi\"", value)
+	}
+	return nil
+}
+
+func positive(value string) error {
+	if n, err := strconv.Atoi(value); err != nil || n < 1 {
+		return fmt.Errorf("a number of at least 1 is required")
+	}
 	return nil
 }
 
@@ -319,25 +1180,59 @@ func (i *Initializer) getDefaultProjectName() string {
 	if err != nil {
 		return "my-app"
 	}
-	return filepath.Base(absPath)
+	if name := config.DNSName(filepath.Base(absPath)); name != "" {
+		return name
+	}
+	return "my-app"
 }
-func (i *Initializer) getDefaultPort() int {
-	// Default ports based on language/framework
-	portMap := map[string]int{
-		"nodejs": 3000,
-		"go":     8080,
-		"python": 8000,
-		"java":   8080,
-		"ruby":   3000,
-		"php":    8080,
-		"dotnet": 5000,
-		"rust":   8080,
+
+// portStep asks for the port of an application until it is valid.
+func (i *Initializer) portStep(port *int) prompt.Step {
+	return prompt.Step{
+		Ask: func() error {
+			answer, err := i.prompter.Int("Application port", *port,
+				prompt.Help("The port your application listens on inside the container."),
+				prompt.Validate(prompt.Port))
+			if err != nil {
+				return err
+			}
+			*port = answer
+			return nil
+		},
+	}
+}
+
+func (i *Initializer) getDefaultRegion(provider string) string {
+	if cloud.ValidateRegion(provider, i.answers.Region) == nil {
+		return i.answers.Region
+	}
+	if configured := i.defaults.Get(defaults.KeyRegion, ""); cloud.ValidateRegion(provider, configured) == nil {
+		return configured
+	}
+	return cloud.DefaultRegion(provider)
+}
+
+func accountQuestion(provider string) string {
+	switch provider {
+	case "aws":
+		return "AWS account id (leave empty to skip)"
+	case "gcp":
+		return "GCP project id (leave empty to skip)"
+	case "azure":
+		return "Azure container registry name (leave empty to skip)"
 	}
+	return "Cloud account id (leave empty to skip)"
+}
 
-	if port, ok := portMap[i.baseconfigapp.Language]; ok {
-		return port
+func (i *Initializer) getDefaultEnvironment() string {
+	if i.environment != "" {
+		return i.environment
 	}
-	return 8080
+	return i.defaults.Get(defaults.KeyEnvironment, "production")
+}
+
+func (i *Initializer) getDefaultPort() int {
+	return detect.DefaultPort(i.baseconfigapp.Language)
 }
 
 /******************************************************/
@@ -355,62 +1250,114 @@ func (i *Initializer) validateConfig() error {
 		if i.configmonolith.Port <= 0 || i.configmonolith.Port > 65535 {
 			return fmt.Errorf("invalid port number: %d", i.configmonolith.Port)
 		}
+		if err := cloud.ValidateRegion(i.configmonolith.CloudProvider, i.configmonolith.Region); err != nil {
+			return err
+		}
+		if i.configmonolith.ContainerRegistry != "" {
+			if err := cloud.ValidateRegistry(i.configmonolith.ContainerRegistry); err != nil {
+				return err
+			}
+		}
+		if err := i.configmonolith.Validate(); err != nil {
+			return err
+		}
+		if err := i.configmonolith.Ingress.Validate(); err != nil {
+			return err
+		}
 	} else {
 		if i.services == nil {
 			return fmt.Errorf("no microservices detected inside of the folder")
 		}
+		if err := cloud.ValidateRegion(i.ConfigMicroRoot.CloudProvider, i.ConfigMicroRoot.Region); err != nil {
+			return err
+		}
+		if err := configMicroservice.ValidateServiceRefs(i.ConfigMicroRoot.Services); err != nil {
+			return err
+		}
+		if err := i.ConfigMicroRoot.Ingress.Validate(); err != nil {
+			return err
+		}
+		prefixes := map[string]string{}
+		for _, svc := range i.microservices {
+			effective := configMicroservice.Resolve(i.ConfigMicroRoot, svc.config)
+			if err := cloud.ValidateRegion(effective.CloudProvider, effective.Region); err != nil {
+				return fmt.Errorf("service %s: %w", svc.ref.Name, err)
+			}
+			if effective.ContainerRegistry != "" {
+				if err := cloud.ValidateRegistry(effective.ContainerRegistry); err != nil {
+					return fmt.Errorf("service %s: %w", svc.ref.Name, err)
+				}
+			}
+			if err := effective.Validate(); err != nil {
+				return fmt.Errorf("service %s: %w", svc.ref.Name, err)
+			}
+			if prefix := svc.config.PathPrefix; prefix != "" {
+				if other, ok := prefixes[prefix]; ok {
+					return fmt.Errorf("services %s and %s both use the path prefix %s", other, svc.ref.Name, prefix)
+				}
+				prefixes[prefix] = svc.ref.Name
+			}
+		}
 	}
-	return nil
-}
 
-/******************************************************/
-/************SAVE*******************************/
-/****************************************************/
+	return i.validateLockedDefaults()
+}
 
-func (i *Initializer) saveConfig() error {
-	// Create .init directory
-	initDir := filepath.Join(i.projectPath, ".init")
-	if err := os.MkdirAll(initDir, 0755); err != nil {
-		return fmt.Errorf("failed to create .init directory: %w", err)
+// validateLockedDefaults rejects values that differ from organisation-locked defaults.
+func (i *Initializer) validateLockedDefaults() error {
+	values := map[string]string{
+		defaults.KeyCloudProvider:     i.configmonolith.CloudProvider,
+		defaults.KeyRegion:            i.configmonolith.Region,
+		defaults.KeyEnvironment:       i.configmonolith.Environment,
+		defaults.KeyContainerRegistry: i.configmonolith.ContainerRegistry,
+		defaults.KeyNamespace:         i.configmonolith.Namespace,
+		defaults.KeyTarget:            i.configmonolith.Target,
 	}
-
-	// Generate YAML content
-	data, err := yaml.Marshal(i)
-	if i.ConfigMicro.ProjectType == "microservice" {
-		data, err = yaml.Marshal(i.ConfigMicro)
-	} else {
-		data, err = yaml.Marshal(i.configmonolith)
-	}
-
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+	if i.ConfigMicroRoot.ProjectType == "microservice" {
+		values = map[string]string{
+			defaults.KeyCloudProvider:     i.ConfigMicroRoot.CloudProvider,
+			defaults.KeyRegion:            i.ConfigMicroRoot.Region,
+			defaults.KeyEnvironment:       i.ConfigMicroRoot.Environment,
+			defaults.KeyContainerRegistry: i.ConfigMicroRoot.ContainerRegistry,
+			defaults.KeyNamespace:         i.ConfigMicroRoot.Namespace,
+			defaults.KeyTarget:            i.ConfigMicroRoot.Target,
+		}
 	}
 
-	// Write to file
-	configPath := filepath.Join(initDir, "daab.yaml")
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
+	for key, value := range values {
+		if err := i.defaults.CheckLocked(key, value); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (i *Initializer) saveConfigMicroservice() error {
-	// Create .init directory
-	initDir := filepath.Join(i.projectPath, ".init")
-	if err := os.MkdirAll(initDir, 0755); err != nil {
-		return fmt.Errorf("failed to create .init directory: %w", err)
+/******************************************************/
+/************SAVE*******************************/
+/****************************************************/
+
+func (i *Initializer) saveConfig(tx *fsutil.Transaction) error {
+	return stageConfig(tx, i.projectPath, "daab.yaml", i.configmonolith)
+}
+
+// saveConfigMicroservice stages every service config, then daab.root.yaml.
+func (i *Initializer) saveConfigMicroservice(tx *fsutil.Transaction) error {
+	for _, svc := range i.microservices {
+		if err := stageConfig(tx, svc.path, "daab.yaml", svc.config); err != nil {
+			return fmt.Errorf("service %s: %w", svc.path, err)
+		}
 	}
+	return stageConfig(tx, i.projectPath, "daab.root.yaml", i.ConfigMicroRoot)
+}
 
+// stageConfig marshals value and stages it as <dir>/.init/<fileName>.
+func stageConfig(tx *fsutil.Transaction, dir, fileName string, value interface{}) error {
 	// Generate YAML content
-	data, err := yaml.Marshal(i.ConfigMicroRoot)
+	data, err := yaml.Marshal(value)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	// Write to file
-	configPath := filepath.Join(initDir, "daab.root.yaml")
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
-	}
+	tx.Stage(filepath.Join(dir, ".init", fileName), data, 0644)
 	return nil
 }
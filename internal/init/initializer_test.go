@@ -0,0 +1,318 @@
+package initcmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mouad4949/DAAB/internal/defaults"
+	"github.com/mouad4949/DAAB/internal/prompt"
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+)
+
+// writeFiles creates files, relative to dir, with their content.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// runScripted runs init in dir with answers and checks they were all used.
+func runScripted(t *testing.T, dir string, answers ...string) (*prompt.Scripted, string) {
+	t.Helper()
+	// Only the defaults of the test apply
+	t.Setenv(defaults.OrgDefaultsEnv, "")
+	t.Setenv(defaults.UserConfigEnv, filepath.Join(t.TempDir(), "config.yaml"))
+
+	scripted := prompt.NewScripted(answers...)
+	var out bytes.Buffer
+	initializer := NewInitializer(&InitFlags{ProjectPath: dir, Workers: 2}, scripted, &out)
+	if err := initializer.Run(); err != nil {
+		t.Fatalf("Run(): %v\nasked: %q\noutput:\n%s", err, scripted.Asked, out.String())
+	}
+	if scripted.Remaining() != 0 {
+		t.Fatalf("%d answers left, asked: %q", scripted.Remaining(), scripted.Asked)
+	}
+	return scripted, out.String()
+}
+
+func goModule(name string) map[string]string {
+	return map[string]string{
+		"go.mod":  "module example.com/" + name + "\n\ngo 1.22\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+	}
+}
+
+func TestInitMonolith(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, goModule("shop"))
+
+	_, out := runScripted(t, dir,
+		"monolith",     // Project type
+		"shop",         // Project name
+		"gcp",          // Cloud provider
+		"",             // Deployment target: kubernetes
+		"staging",      // Environment
+		"europe-west1", // Region
+		"",             // GCP project id
+		"",             // Container registry
+		"shop",         // Kubernetes namespace
+		"n",            // Expose with an ingress
+		"70000",        // Application port, out of range
+		"9000",         // Application port
+		"n",            // Set CPU, memory and replicas
+		"y",            // Save
+	)
+
+	project, err := configProject.Load(dir, "")
+	if err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	monolith := project.Monolith
+	if monolith == nil {
+		t.Fatal("no monolith config written")
+	}
+	got := []string{monolith.ProjectName, monolith.CloudProvider, monolith.Environment, monolith.Region, monolith.Namespace, monolith.Language}
+	want := []string{"shop", "gcp", "staging", "europe-west1", "shop", "go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("config = %q, want %q", got, want)
+	}
+	if monolith.Port != 9000 {
+		t.Errorf("port = %d, want 9000", monolith.Port)
+	}
+	if monolith.Resources != nil || monolith.Autoscaling != nil {
+		t.Errorf("workload = %+v %+v, want the defaults of the language", monolith.Resources, monolith.Autoscaling)
+	}
+	if !strings.Contains(out, "📋 Summary:") {
+		t.Errorf("the summary was not written to the output:\n%s", out)
+	}
+}
+
+func TestInitMicroservice(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"web/package.json": `{"name": "web", "dependencies": {"express": "^4.0.0"}}`,
+	}
+	for name, content := range goModule("users") {
+		files["users/"+name] = content
+	}
+	writeFiles(t, dir, files)
+
+	_, out := runScripted(t, dir,
+		"microservice", // Project type
+		"shop",         // Project name
+		"aws",          // Cloud provider
+		"",             // Deployment target: kubernetes
+		"",             // Environment
+		"",             // Region: us-east-1
+		"123456789012", // AWS account id
+		"",             // Container registry: derived from the account
+		"",             // Kubernetes namespace
+		"n",            // Expose with an ingress
+		"users",        // Service to edit
+		"9090",         // Application port
+		"",             // Container registry: inherited
+		"y",            // Set CPU, memory and replicas
+		"",             // CPU request
+		"",             // Memory request
+		"",             // CPU limit
+		"",             // Memory limit
+		"y",            // Enable autoscaling
+		"2",            // Minimum replicas
+		"4",            // Maximum replicas
+		"",             // Target CPU usage
+		"",             // Service to edit: done
+		"y",            // Save
+	)
+
+	project, err := configProject.Load(dir, "")
+	if err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	if project.Root == nil {
+		t.Fatal("no daab.root.yaml written")
+	}
+	if want := "123456789012.dkr.ecr.us-east-1.amazonaws.com"; project.Root.ContainerRegistry != want {
+		t.Errorf("root registry = %q, want %q", project.Root.ContainerRegistry, want)
+	}
+
+	services := map[string]configProject.Service{}
+	for _, svc := range project.Services {
+		services[svc.Name] = svc
+	}
+	if len(services) != 2 {
+		t.Fatalf("services = %v, want users and web", project.Services)
+	}
+	users, web := services["users"].Effective, services["web"].Effective
+	if users.Language != "go" || web.Language != "nodejs" {
+		t.Errorf("languages = %s, %s, want go, nodejs", users.Language, web.Language)
+	}
+	if users.Port != 9090 {
+		t.Errorf("users port = %d, want 9090", users.Port)
+	}
+	if users.Autoscaling == nil || users.Autoscaling.MinReplicas != 2 || users.Autoscaling.MaxReplicas != 4 {
+		t.Errorf("users autoscaling = %+v, want 2 to 4 pods", users.Autoscaling)
+	}
+	if services["users"].Config.ContainerRegistry != "" || users.ContainerRegistry != project.Root.ContainerRegistry {
+		t.Errorf("users registry = %q (own %q), want it inherited", users.ContainerRegistry, services["users"].Config.ContainerRegistry)
+	}
+	if !strings.Contains(out, "2 to 4 pods") {
+		t.Errorf("the reviewed scaling is not in the table:\n%s", out)
+	}
+}
+
+func TestInitBackNavigation(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, goModule("shop"))
+
+	scripted, _ := runScripted(t, dir,
+		"monolith", // Project type
+		"first",    // Project name
+		"<",        // Cloud provider: back to the project name
+		"second",   // Project name
+		"azure",    // Cloud provider
+		"",         // Deployment target
+		"<",        // Environment: back to the target
+		"<",        // Deployment target: back to the cloud provider
+		"aws",      // Cloud provider
+		"",         // Deployment target
+		"",         // Environment
+		"",         // Region
+		"",         // AWS account id
+		"",         // Container registry
+		"",         // Kubernetes namespace
+		"n",        // Expose with an ingress
+		"",         // Application port
+		"n",        // Set CPU, memory and replicas
+		"y",        // Save
+	)
+
+	wantAsked := []string{
+		"Project type", "your monolith Project name", "Cloud provider",
+		"your monolith Project name", "Cloud provider", "Deployment target", "Environment",
+		"Deployment target", "Cloud provider", "Deployment target", "Environment",
+	}
+	if got := scripted.Asked[:len(wantAsked)]; !reflect.DeepEqual(got, wantAsked) {
+		t.Errorf("asked %q, want %q", got, wantAsked)
+	}
+
+	project, err := configProject.Load(dir, "")
+	if err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	if project.Monolith.ProjectName != "second" || project.Monolith.CloudProvider != "aws" {
+		t.Errorf("config = %s on %s, want second on aws", project.Monolith.ProjectName, project.Monolith.CloudProvider)
+	}
+}
+
+func TestInitAnswersAgainAfterSummary(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, goModule("shop"))
+
+	scripted, _ := runScripted(t, dir,
+		"monolith", "shop", "aws", "", "", "", "", "", "", "n", "", "n",
+		"n", // Save: no, go through the questions again
+		// The previous answers are the defaults
+		"", "", "", "", "", "", "", "", "prod", "", "8081", "",
+		"y", // Save
+	)
+	if n := strings.Count(strings.Join(scripted.Asked, "\n"), "Save this configuration?"); n != 2 {
+		t.Errorf("summary confirmed %d times, want 2", n)
+	}
+
+	project, err := configProject.Load(dir, "")
+	if err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	if project.Monolith.ProjectName != "shop" || project.Monolith.Namespace != "prod" || project.Monolith.Port != 8081 {
+		t.Errorf("config = %s in %s on %d, want shop in prod on 8081", project.Monolith.ProjectName, project.Monolith.Namespace, project.Monolith.Port)
+	}
+}
+
+func TestInitBackFromApplicationQuestions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, goModule("shop"))
+
+	scripted, _ := runScripted(t, dir,
+		"monolith", "shop", "aws", "", "", "", "", "",
+		"first", // Kubernetes namespace
+		"n",     // Expose with an ingress
+		"<",     // Application port: back to the ingress
+		"<",     // Expose with an ingress: back to the namespace
+		"second",
+		"n",
+		"",     // Application port
+		"<",    // Set CPU, memory and replicas: back to the port
+		"9001", // Application port
+		"n",
+		"y",
+	)
+
+	wantAsked := []string{
+		"Kubernetes namespace", "Expose the project outside the cluster with an ingress?", "Application port",
+		"Expose the project outside the cluster with an ingress?", "Kubernetes namespace",
+		"Expose the project outside the cluster with an ingress?", "Application port",
+		"Set CPU, memory and replicas now?", "Application port", "Set CPU, memory and replicas now?",
+	}
+	if got := scripted.Asked[8 : 8+len(wantAsked)]; !reflect.DeepEqual(got, wantAsked) {
+		t.Errorf("asked %q, want %q", got, wantAsked)
+	}
+
+	project, err := configProject.Load(dir, "")
+	if err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	if project.Monolith.Namespace != "second" || project.Monolith.Port != 9001 {
+		t.Errorf("config = %s on %d, want second on 9001", project.Monolith.Namespace, project.Monolith.Port)
+	}
+}
+
+func TestInitBackWhileEditingAService(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{}
+	for name, content := range goModule("users") {
+		files["users/"+name] = content
+	}
+	writeFiles(t, dir, files)
+
+	scripted, _ := runScripted(t, dir,
+		"microservice", "shop", "aws", "", "", "", "", "", "", "n",
+		"users", // Service to edit
+		"9090",  // Application port
+		"",      // Container registry
+		"<",     // Set CPU, memory and replicas: back to the registry
+		"<",     // Container registry: back to the port
+		"9091",
+		"",
+		"n",
+		"", // Service to edit: done
+		"y",
+	)
+
+	wantAsked := []string{
+		"Application port", "Container registry (leave empty for default)", "Set CPU, memory and replicas now?",
+		"Container registry (leave empty for default)", "Application port",
+		"Container registry (leave empty for default)", "Set CPU, memory and replicas now?",
+	}
+	if got := scripted.Asked[11 : 11+len(wantAsked)]; !reflect.DeepEqual(got, wantAsked) {
+		t.Errorf("asked %q, want %q", got, wantAsked)
+	}
+
+	project, err := configProject.Load(dir, "")
+	if err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	if port := project.Services[0].Effective.Port; port != 9091 {
+		t.Errorf("port = %d, want 9091", port)
+	}
+}
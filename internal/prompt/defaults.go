@@ -0,0 +1,44 @@
+package prompt
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Defaults answers every question with its default value, for non-interactive runs.
+// A default failing validation is an error since nobody can be asked for another value.
+type Defaults struct{}
+
+func NewDefaults() *Defaults {
+	return &Defaults{}
+}
+
+func (Defaults) answer(question, value string, opts []Option) (string, error) {
+	if err := newOptions(opts).check(value); err != nil {
+		return "", fmt.Errorf("%s: %w", question, err)
+	}
+	return value, nil
+}
+
+func (d Defaults) String(question, defaultValue string, opts ...Option) (string, error) {
+	return d.answer(question, defaultValue, opts)
+}
+
+func (d Defaults) Int(question string, defaultValue int, opts ...Option) (int, error) {
+	if _, err := d.answer(question, strconv.Itoa(defaultValue), opts); err != nil {
+		return 0, err
+	}
+	return defaultValue, nil
+}
+
+func (d Defaults) Select(question string, options []string, defaultValue string, opts ...Option) (string, error) {
+	return d.answer(question, defaultValue, opts)
+}
+
+func (d Defaults) Search(question string, options []string, defaultValue string, opts ...Option) (string, error) {
+	return d.answer(question, defaultValue, opts)
+}
+
+func (d Defaults) Confirm(question string, defaultValue bool, opts ...Option) (bool, error) {
+	return defaultValue, nil
+}
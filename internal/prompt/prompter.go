@@ -0,0 +1,154 @@
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrBack is returned by a Prompter when the user asks to go back to the previous question.
+var ErrBack = errors.New("go back to the previous question")
+
+// errNarrowed is returned by a Search parse when a fragment matched several options.
+var errNarrowed = errors.New("several options match")
+
+// Answers typed in line mode to get help or go back.
+const (
+	helpInput = "?"
+	backInput = "<"
+)
+
+// Prompter asks the user questions. Each method returns defaultValue when the
+// answer is empty. Answers failing the Validate option are asked again when possible.
+type Prompter interface {
+	// String asks for free text
+	String(question, defaultValue string, opts ...Option) (string, error)
+	// Int asks for an integer
+	Int(question string, defaultValue int, opts ...Option) (int, error)
+	// Select asks to pick one of a few options, by number or name
+	Select(question string, options []string, defaultValue string, opts ...Option) (string, error)
+	// Search is Select for long lists: a fragment matching several options narrows the list
+	Search(question string, options []string, defaultValue string, opts ...Option) (string, error)
+	// Confirm asks a yes/no question
+	Confirm(question string, defaultValue bool, opts ...Option) (bool, error)
+}
+
+// Option customises a single question.
+type Option func(*options)
+
+type options struct {
+	help     string
+	validate func(string) error
+}
+
+// Help sets the text shown when the user asks for help on a question.
+func Help(text string) Option {
+	return func(o *options) {
+		o.help = text
+	}
+}
+
+// Validate sets a check applied to the answer. Int answers are validated in their decimal form.
+func Validate(fn func(string) error) Option {
+	return func(o *options) {
+		o.validate = fn
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *options) check(value string) error {
+	if o.validate == nil {
+		return nil
+	}
+	return o.validate(value)
+}
+
+// parseInt converts an answer to an integer.
+func parseInt(input string, defaultValue int) (int, error) {
+	if input == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(input)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number: %s", input)
+	}
+	return value, nil
+}
+
+// parseSelect maps an answer to an option by number or case-insensitive name.
+func parseSelect(input string, options []string, defaultValue string) (string, error) {
+	if input == "" {
+		return defaultValue, nil
+	}
+
+	// Try to parse as number
+	index, err := strconv.Atoi(input)
+	if err == nil {
+		if index < 1 || index > len(options) {
+			return "", fmt.Errorf("invalid selection: %d", index)
+		}
+		return options[index-1], nil
+	}
+
+	// Try to match as string
+	for _, option := range options {
+		if strings.EqualFold(input, option) {
+			return option, nil
+		}
+	}
+
+	return "", fmt.Errorf("invalid selection: %s", input)
+}
+
+// parseSearch is parseSelect that also accepts a fragment. When the fragment matches
+// several options, they are returned as narrowed so the caller can ask again.
+func parseSearch(input string, options []string, defaultValue string) (selected string, narrowed []string, err error) {
+	if selected, err := parseSelect(input, options, defaultValue); err == nil {
+		return selected, nil, nil
+	}
+
+	narrowed = filterOptions(options, input)
+	switch len(narrowed) {
+	case 0:
+		return "", nil, fmt.Errorf("invalid selection: %s", input)
+	case 1:
+		return narrowed[0], nil, nil
+	}
+	return "", narrowed, nil
+}
+
+func filterOptions(options []string, filter string) []string {
+	var matches []string
+	for _, option := range options {
+		if strings.Contains(strings.ToLower(option), strings.ToLower(filter)) {
+			matches = append(matches, option)
+		}
+	}
+	return matches
+}
+
+// parseConfirm converts a yes/no answer.
+func parseConfirm(input string, defaultValue bool) (bool, error) {
+	input = strings.ToLower(input)
+	if input == "" {
+		return defaultValue, nil
+	}
+
+	switch input {
+	case "y", "yes":
+		return true, nil
+	case "n", "no":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid input: %s", input)
+	}
+}
@@ -0,0 +1,117 @@
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Scripted answers questions from a fixed list, in order. An empty answer selects the
+// default, exactly like pressing enter on a terminal, and "<" goes back. Invalid answers
+// consume the next answer, as a user re-typing would. It records every question asked
+// so a test can check the flow.
+type Scripted struct {
+	answers []string
+	Asked   []string
+}
+
+func NewScripted(answers ...string) *Scripted {
+	return &Scripted{
+		answers: answers,
+	}
+}
+
+// Remaining returns the number of answers not consumed yet.
+func (s *Scripted) Remaining() int {
+	return len(s.answers)
+}
+
+// ask takes answers until one converts with parse and passes validation.
+func (s *Scripted) ask(question string, o *options, parse func(answer string) (string, error)) (string, error) {
+	var lastErr error
+	for {
+		s.Asked = append(s.Asked, question)
+		if len(s.answers) == 0 {
+			if lastErr != nil {
+				return "", fmt.Errorf("%s: %w", question, lastErr)
+			}
+			return "", fmt.Errorf("no scripted answer left for %q", question)
+		}
+		answer := s.answers[0]
+		s.answers = s.answers[1:]
+
+		if answer == backInput {
+			return "", ErrBack
+		}
+
+		value, err := parse(answer)
+		if errors.Is(err, errNarrowed) {
+			return "", err
+		}
+		if err == nil {
+			err = o.check(value)
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return value, nil
+	}
+}
+
+func (s *Scripted) String(question, defaultValue string, opts ...Option) (string, error) {
+	return s.ask(question, newOptions(opts), func(answer string) (string, error) {
+		if answer == "" {
+			return defaultValue, nil
+		}
+		return answer, nil
+	})
+}
+
+func (s *Scripted) Int(question string, defaultValue int, opts ...Option) (int, error) {
+	value, err := s.ask(question, newOptions(opts), func(answer string) (string, error) {
+		value, err := parseInt(answer, defaultValue)
+		return strconv.Itoa(value), err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+func (s *Scripted) Select(question string, options []string, defaultValue string, opts ...Option) (string, error) {
+	return s.ask(question, newOptions(opts), func(answer string) (string, error) {
+		return parseSelect(answer, options, defaultValue)
+	})
+}
+
+func (s *Scripted) Search(question string, options []string, defaultValue string, opts ...Option) (string, error) {
+	o := newOptions(opts)
+	for {
+		var narrowed []string
+		selected, err := s.ask(question, o, func(answer string) (string, error) {
+			selected, matches, err := parseSearch(answer, options, defaultValue)
+			if matches != nil {
+				narrowed = matches
+				return "", errNarrowed
+			}
+			return selected, err
+		})
+		if !errors.Is(err, errNarrowed) {
+			return selected, err
+		}
+		options = narrowed
+		defaultValue = narrowed[0]
+	}
+}
+
+func (s *Scripted) Confirm(question string, defaultValue bool, opts ...Option) (bool, error) {
+	value, err := s.ask(question, newOptions(opts), func(answer string) (string, error) {
+		confirmed, err := parseConfirm(answer, defaultValue)
+		return strconv.FormatBool(confirmed), err
+	})
+	if err != nil {
+		return false, err
+	}
+	return value == "true", nil
+}
@@ -0,0 +1,41 @@
+package prompt
+
+import "errors"
+
+// Step is one question of a sequence run by RunSteps.
+type Step struct {
+	// Skip reports whether the question does not apply, e.g. because of an earlier answer
+	Skip func() bool
+	Ask  func() error
+}
+
+// RunSteps asks steps in order. When a step returns ErrBack, the previous step that is
+// not skipped is asked again; going back from the first step asks it again.
+func RunSteps(steps []Step) error {
+	for i := 0; i < len(steps); {
+		if steps[i].Skip != nil && steps[i].Skip() {
+			i++
+			continue
+		}
+
+		err := steps[i].Ask()
+		if errors.Is(err, ErrBack) {
+			i = previousStep(steps, i)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		i++
+	}
+	return nil
+}
+
+func previousStep(steps []Step, current int) int {
+	for i := current - 1; i >= 0; i-- {
+		if steps[i].Skip == nil || !steps[i].Skip() {
+			return i
+		}
+	}
+	return current
+}
@@ -0,0 +1,391 @@
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Terminal prompts on a reader/writer pair, usually stdin and stdout. A single buffered
+// reader is kept for the whole session so piped input is not lost between questions.
+//
+// When the input is a terminal, Select and Search show an arrow-key list; otherwise
+// every question is answered with a line of text. In line mode "?" shows the help of
+// the question and "<" goes back to the previous one. Invalid answers are asked again.
+type Terminal struct {
+	reader *bufio.Reader
+	out    io.Writer
+	// fd of the input terminal, -1 when the input is not a terminal
+	fd int
+}
+
+func NewTerminal(in io.Reader, out io.Writer) *Terminal {
+	fd := -1
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fd = int(f.Fd())
+	}
+	return &Terminal{
+		reader: bufio.NewReader(in),
+		out:    out,
+		fd:     fd,
+	}
+}
+
+// NewStdTerminal prompts on os.Stdin and os.Stdout.
+func NewStdTerminal() *Terminal {
+	return NewTerminal(os.Stdin, os.Stdout)
+}
+
+func (t *Terminal) readLine() (string, error) {
+	input, err := t.reader.ReadString('\n')
+	if err != nil && !(err == io.EOF && input != "") {
+		return "", err
+	}
+	return strings.TrimSpace(input), nil
+}
+
+// ask prints label, reads a line and converts it with parse until it is valid.
+func (t *Terminal) ask(label string, o *options, parse func(input string) (string, error)) (string, error) {
+	for {
+		fmt.Fprint(t.out, label)
+
+		input, err := t.readLine()
+		if err != nil {
+			return "", err
+		}
+
+		switch input {
+		case backInput:
+			return "", ErrBack
+		case helpInput:
+			t.printHelp(o)
+			continue
+		}
+
+		value, err := parse(input)
+		if errors.Is(err, errNarrowed) {
+			return "", err
+		}
+		if err == nil {
+			err = o.check(value)
+		}
+		if err != nil {
+			fmt.Fprintf(t.out, "  ✗ %v\n", err)
+			continue
+		}
+		return value, nil
+	}
+}
+
+func (t *Terminal) printHelp(o *options) {
+	if o.help == "" {
+		fmt.Fprintln(t.out, "  No help available for this question. Type < to go back.")
+		return
+	}
+	fmt.Fprintf(t.out, "  ℹ %s\n", o.help)
+}
+
+func (t *Terminal) String(question, defaultValue string, opts ...Option) (string, error) {
+	label := fmt.Sprintf("? %s: ", question)
+	if defaultValue != "" {
+		label = fmt.Sprintf("? %s [%s]: ", question, defaultValue)
+	}
+
+	return t.ask(label, newOptions(opts), func(input string) (string, error) {
+		if input == "" {
+			return defaultValue, nil
+		}
+		return input, nil
+	})
+}
+
+func (t *Terminal) Int(question string, defaultValue int, opts ...Option) (int, error) {
+	label := fmt.Sprintf("? %s [%d]: ", question, defaultValue)
+
+	value, err := t.ask(label, newOptions(opts), func(input string) (string, error) {
+		value, err := parseInt(input, defaultValue)
+		return strconv.Itoa(value), err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+func (t *Terminal) printOptions(question string, options []string, defaultValue string) {
+	fmt.Fprintf(t.out, "? %s\n", question)
+	for i, option := range options {
+		prefix := " "
+		if option == defaultValue {
+			prefix = ">"
+		}
+		fmt.Fprintf(t.out, "  %s %d) %s\n", prefix, i+1, option)
+	}
+}
+
+func (t *Terminal) Select(question string, options []string, defaultValue string, opts ...Option) (string, error) {
+	o := newOptions(opts)
+	if t.fd >= 0 {
+		return t.selectList(question, options, defaultValue, o, false)
+	}
+
+	t.printOptions(question, options, defaultValue)
+	label := fmt.Sprintf("Select [1-%d] (default: %s): ", len(options), defaultValue)
+	return t.ask(label, o, func(input string) (string, error) {
+		return parseSelect(input, options, defaultValue)
+	})
+}
+
+func (t *Terminal) Search(question string, options []string, defaultValue string, opts ...Option) (string, error) {
+	o := newOptions(opts)
+	if t.fd >= 0 {
+		return t.selectList(question, options, defaultValue, o, true)
+	}
+
+	for {
+		t.printOptions(question, options, defaultValue)
+		label := fmt.Sprintf("Select [1-%d] or type to search (default: %s): ", len(options), defaultValue)
+
+		var narrowed []string
+		selected, err := t.ask(label, o, func(input string) (string, error) {
+			selected, matches, err := parseSearch(input, options, defaultValue)
+			if matches != nil {
+				narrowed = matches
+				return "", errNarrowed
+			}
+			return selected, err
+		})
+		if !errors.Is(err, errNarrowed) {
+			return selected, err
+		}
+		options = narrowed
+		defaultValue = narrowed[0]
+	}
+}
+
+func (t *Terminal) Confirm(question string, defaultValue bool, opts ...Option) (bool, error) {
+	defaultStr := "y/N"
+	if defaultValue {
+		defaultStr = "Y/n"
+	}
+	label := fmt.Sprintf("? %s [%s]: ", question, defaultStr)
+
+	value, err := t.ask(label, newOptions(opts), func(input string) (string, error) {
+		confirmed, err := parseConfirm(input, defaultValue)
+		return strconv.FormatBool(confirmed), err
+	})
+	if err != nil {
+		return false, err
+	}
+	return value == "true", nil
+}
+
+/******************************************************/
+/************Arrow-key lists**************************/
+/****************************************************/
+
+const maxVisibleOptions = 10
+
+type key int
+
+const (
+	keyRune key = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyEnter
+	keyBackspace
+	keyInterrupt
+	keyEOF
+)
+
+// selectList shows options as a list navigated with the arrow keys (or j/k). Enter picks
+// the highlighted option, ? toggles the help, < or the left arrow goes back. When
+// filterable, typed characters narrow the list.
+func (t *Terminal) selectList(question string, options []string, defaultValue string, o *options, filterable bool) (string, error) {
+	state, err := term.MakeRaw(t.fd)
+	if err != nil {
+		return "", fmt.Errorf("failed to switch the terminal to raw mode: %w", err)
+	}
+	defer term.Restore(t.fd, state)
+
+	cursor := 0
+	for i, option := range options {
+		if option == defaultValue {
+			cursor = i
+		}
+	}
+
+	filter := ""
+	showHelp := false
+	message := ""
+	lines := 0
+	for {
+		visible := options
+		if filter != "" {
+			visible = filterOptions(options, filter)
+		}
+		cursor = max(0, min(cursor, len(visible)-1))
+		lines = t.renderList(lines, question, visible, cursor, filter, filterable, showHelp, o.help, message)
+		message = ""
+
+		k, r, err := t.readKey()
+		if err != nil {
+			t.clearLines(lines)
+			return "", err
+		}
+
+		switch k {
+		case keyUp:
+			cursor--
+		case keyDown:
+			cursor++
+		case keyLeft:
+			t.clearLines(lines)
+			return "", ErrBack
+		case keyInterrupt:
+			t.clearLines(lines)
+			return "", fmt.Errorf("interrupted")
+		case keyEOF:
+			t.clearLines(lines)
+			return "", io.EOF
+		case keyBackspace:
+			if filterable && filter != "" {
+				_, size := utf8.DecodeLastRuneInString(filter)
+				filter = filter[:len(filter)-size]
+				cursor = 0
+			}
+		case keyEnter:
+			if len(visible) == 0 {
+				continue
+			}
+			value := visible[cursor]
+			if err := o.check(value); err != nil {
+				message = err.Error()
+				continue
+			}
+			t.clearLines(lines)
+			fmt.Fprintf(t.out, "? %s: %s\r\n", question, value)
+			return value, nil
+		case keyRune:
+			switch {
+			case r == 0:
+				// Unsupported control key
+			case r == '?':
+				showHelp = !showHelp
+			case r == '<':
+				t.clearLines(lines)
+				return "", ErrBack
+			case filterable:
+				filter += string(r)
+				cursor = 0
+			case r == 'k':
+				cursor--
+			case r == 'j':
+				cursor++
+			}
+		}
+	}
+}
+
+// renderList redraws the list over the previous rendering of prevLines lines and
+// returns the number of lines written.
+func (t *Terminal) renderList(prevLines int, question string, visible []string, cursor int, filter string, filterable, showHelp bool, help, message string) int {
+	t.clearLines(prevLines)
+
+	var rows []string
+	header := fmt.Sprintf("? %s", question)
+	if filterable {
+		header += fmt.Sprintf(" (type to filter) %s", filter)
+	}
+	rows = append(rows, header)
+
+	start := 0
+	if cursor >= maxVisibleOptions {
+		start = cursor - maxVisibleOptions + 1
+	}
+	end := min(len(visible), start+maxVisibleOptions)
+	for i := start; i < end; i++ {
+		if i == cursor {
+			rows = append(rows, fmt.Sprintf("  \x1b[36m❯ %s\x1b[0m", visible[i]))
+		} else {
+			rows = append(rows, "    "+visible[i])
+		}
+	}
+	if len(visible) == 0 {
+		rows = append(rows, "    (no match)")
+	} else if len(visible) > maxVisibleOptions {
+		rows = append(rows, fmt.Sprintf("    \x1b[2m%d/%d\x1b[0m", cursor+1, len(visible)))
+	}
+
+	if message != "" {
+		rows = append(rows, fmt.Sprintf("  \x1b[31m✗ %s\x1b[0m", message))
+	}
+	if showHelp && help != "" {
+		rows = append(rows, fmt.Sprintf("  \x1b[2mℹ %s\x1b[0m", help))
+	}
+	rows = append(rows, "  \x1b[2m↑/↓ move · enter select · ? help · < back\x1b[0m")
+
+	fmt.Fprint(t.out, strings.Join(rows, "\r\n"))
+	return len(rows)
+}
+
+// clearLines erases the last n lines written by renderList.
+func (t *Terminal) clearLines(n int) {
+	if n == 0 {
+		return
+	}
+	if n > 1 {
+		fmt.Fprintf(t.out, "\x1b[%dA", n-1)
+	}
+	fmt.Fprint(t.out, "\r\x1b[J")
+}
+
+func (t *Terminal) readKey() (key, rune, error) {
+	r, _, err := t.reader.ReadRune()
+	if err != nil {
+		return keyEOF, 0, err
+	}
+
+	switch r {
+	case '\r', '\n':
+		return keyEnter, 0, nil
+	case 127, '\b':
+		return keyBackspace, 0, nil
+	case 3:
+		return keyInterrupt, 0, nil
+	case 4:
+		return keyEOF, 0, nil
+	case 0x1b:
+		// Escape sequences: ESC [ A (up), B (down), D (left)
+		next, err := t.reader.ReadByte()
+		if err != nil || (next != '[' && next != 'O') {
+			return keyRune, 0, err
+		}
+		code, err := t.reader.ReadByte()
+		if err != nil {
+			return keyRune, 0, err
+		}
+		switch code {
+		case 'A':
+			return keyUp, 0, nil
+		case 'B':
+			return keyDown, 0, nil
+		case 'D':
+			return keyLeft, 0, nil
+		}
+		return keyRune, 0, nil
+	}
+
+	if r < 32 {
+		return keyRune, 0, nil
+	}
+	return keyRune, r, nil
+}
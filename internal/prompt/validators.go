@@ -0,0 +1,44 @@
+package prompt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mouad4949/DAAB/pkg/config"
+)
+
+// Port accepts TCP ports from 1 to 65535.
+func Port(value string) error {
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid number: %s", value)
+	}
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535, got %d", port)
+	}
+	return nil
+}
+
+// DNSName accepts RFC 1123 labels, as used for Kubernetes names and namespaces.
+func DNSName(value string) error {
+	return config.ValidateDNSName(value)
+}
+
+// NotEmpty rejects empty answers.
+func NotEmpty(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("a value is required")
+	}
+	return nil
+}
+
+// Optional applies validate only to non-empty answers.
+func Optional(validate func(string) error) func(string) error {
+	return func(value string) error {
+		if value == "" {
+			return nil
+		}
+		return validate(value)
+	}
+}
@@ -0,0 +1,116 @@
+package pushcmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mouad4949/DAAB/internal/fsutil"
+	"github.com/mouad4949/DAAB/pkg/build"
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+	"github.com/mouad4949/DAAB/pkg/registry"
+	"github.com/spf13/cobra"
+)
+
+type PushFlags struct {
+	ProjectPath string
+	Env         string
+	Services    []string
+	Registry    string
+	Concurrency int
+	Retries     int
+	PlainHTTP   bool
+}
+
+func NewPushCommand() *cobra.Command {
+	flags := &PushFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "push",
+		Short: "Push the built images to the container registry",
+		Long: `Push the images of the last 'daab build' to their container_registry, and record the
+digest of every pushed image in .init/builds.yaml.
+
+Images built with docker or buildah are pushed by that tool, with its own credentials.
+Images built with the oci builder are pushed by daab, with the first credentials found in:
+  1. $DAAB_REGISTRY_USERNAME and $DAAB_REGISTRY_PASSWORD
+  2. the auths of the docker config.json ($DOCKER_CONFIG or ~/.docker), as saved by 'docker login'
+  3. the docker credential helper of the registry (credHelpers, credsStore), e.g. ecr-login
+Failed requests are retried with an exponential backoff.`,
+		Example: `  daab push
+  daab push --service users-api
+  daab push --registry localhost:5000 --concurrency 4`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags.Env, _ = cmd.Flags().GetString("env")
+			cmd.SilenceUsage = true
+			return runPush(cmd, flags)
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.ProjectPath, "project-path", ".", "Path to the project directory")
+	cmd.Flags().StringSliceVar(&flags.Services, "service", nil, "Only push these services (repeatable)")
+	cmd.Flags().StringVar(&flags.Registry, "registry", "", "Push to this registry instead of the configured container_registry")
+	cmd.Flags().IntVar(&flags.Concurrency, "concurrency", 2, "Number of images pushed at the same time")
+	cmd.Flags().IntVar(&flags.Retries, "retries", 3, "Number of retries of a failed request")
+	cmd.Flags().BoolVar(&flags.PlainHTTP, "plain-http", false, "Use HTTP instead of HTTPS (registries on localhost always use HTTP)")
+
+	return cmd
+}
+
+func runPush(cmd *cobra.Command, flags *PushFlags) error {
+	project, err := configProject.Load(flags.ProjectPath, flags.Env)
+	if err != nil {
+		return err
+	}
+
+	apps, err := build.SelectApps(project, flags.Services)
+	if err != nil {
+		return err
+	}
+
+	state, err := build.LoadState(build.StatePath(flags.ProjectPath))
+	if err != nil {
+		return err
+	}
+
+	client := registry.NewClient()
+	client.Retries = flags.Retries
+	client.PlainHTTP = flags.PlainHTTP
+
+	fmt.Printf("📤 Pushing %d images...\n", len(apps))
+	results := build.PushAll(cmd.Context(), apps, build.PushOptions{
+		Concurrency: flags.Concurrency,
+		Registry:    flags.Registry,
+		Client:      client,
+		State:       state,
+		Output:      os.Stdout,
+	})
+
+	// Record the digests of the successful pushes even when others failed
+	data, err := state.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFileAtomic(state.Path(), data, 0644); err != nil {
+		return fmt.Errorf("failed to save build state: %w", err)
+	}
+
+	fmt.Println()
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			fmt.Fprintf(w, "❌ %s\t%v\n", result.App, result.Err)
+			continue
+		}
+		fmt.Fprintf(w, "✅ %s\t%s@%s (%s)\n", result.App, result.Image, result.Digest, result.Duration.Round(100*time.Millisecond))
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d images failed to push", failed, len(results))
+	}
+	return nil
+}
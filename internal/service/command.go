@@ -0,0 +1,258 @@
+package servicecmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mouad4949/DAAB/internal/cloud"
+	"github.com/mouad4949/DAAB/internal/fsutil"
+	configMicroservice "github.com/mouad4949/DAAB/pkg/config/microservice"
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+	"github.com/mouad4949/DAAB/pkg/detect"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+type ServiceFlags struct {
+	ProjectPath string
+	Name        string
+	Aliases     []string
+	Port        int
+	Registry    string
+	Force       bool
+}
+
+func NewServiceCommand() *cobra.Command {
+	flags := &ServiceFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "service",
+		Short: "Manage the services of a microservice project",
+	}
+	cmd.PersistentFlags().StringVar(&flags.ProjectPath, "project-path", ".", "Path to the project directory")
+
+	addCmd := &cobra.Command{
+		Use:   "add <path>",
+		Short: "Detect a new service folder and add it to the project",
+		Long: `Detect the folder of a new service, write its .init/daab.yaml and register it in
+.init/daab.root.yaml. The service inherits the shared settings of daab.root.yaml
+(registry, region, namespace, ...) unless they are overridden with flags.
+The folder must be inside the project, the service is named after it unless --name is given.`,
+		Example: `  daab service add ./payments
+  daab service add payments --port 9000
+  daab service add services/billing-v2 --name billing --alias invoices`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAdd(flags, args[0])
+		},
+	}
+	addCmd.Flags().StringVar(&flags.Name, "name", "", "DNS-safe name of the service (default: derived from the folder name)")
+	addCmd.Flags().StringSliceVar(&flags.Aliases, "alias", nil, "Other name of the service, can be repeated")
+	addCmd.Flags().IntVar(&flags.Port, "port", 0, "Port of the service (default: the usual port of its language)")
+	addCmd.Flags().StringVar(&flags.Registry, "registry", "", "Container registry of the service (default: inherited from daab.root.yaml)")
+	addCmd.Flags().BoolVar(&flags.Force, "force", false, "Overwrite an existing .init/daab.yaml in the service folder")
+
+	removeCmd := &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove a service from the project, by name or alias",
+		Long: `Unregister a service from .init/daab.root.yaml. The service folder and its
+.init/daab.yaml are kept, so it can be added again later.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRemove(flags, args[0])
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the services of the project",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env, _ := cmd.Flags().GetString("env")
+			return runList(flags, env)
+		},
+	}
+
+	cmd.AddCommand(addCmd, removeCmd, listCmd)
+	return cmd
+}
+
+// loadMicroservice loads the base configuration and checks it is a microservice project.
+func loadMicroservice(projectPath, env string) (*configProject.Project, error) {
+	project, err := configProject.Load(projectPath, env)
+	if err != nil {
+		return nil, err
+	}
+	if !project.IsMicroservice() {
+		return nil, fmt.Errorf("%s is a monolith project, services can only be managed in microservice projects", projectPath)
+	}
+	return project, nil
+}
+
+func runAdd(flags *ServiceFlags, path string) error {
+	project, err := loadMicroservice(flags.ProjectPath, "")
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+
+	ref, err := configMicroservice.NewServiceRef(flags.ProjectPath, path)
+	if err != nil {
+		return err
+	}
+	if flags.Name != "" {
+		ref.Name = flags.Name
+	}
+	ref.Aliases = flags.Aliases
+
+	refs := append(append([]configMicroservice.ServiceRef{}, project.Root.ServiceRefs()...), ref)
+	if err := configMicroservice.ValidateServiceRefs(refs); err != nil {
+		return err
+	}
+
+	servicePath := filepath.Join(flags.ProjectPath, filepath.FromSlash(ref.Path))
+	configPath := filepath.Join(servicePath, configProject.ConfigDir, configProject.ConfigFile)
+	if _, err := os.Stat(configPath); err == nil && !flags.Force {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", configPath)
+	}
+
+	fmt.Printf("🔍 Detecting %s...\n", servicePath)
+	result, err := detect.NewDetector(servicePath).Detect()
+	if err != nil {
+		return fmt.Errorf("failed to detect %s: %w", servicePath, err)
+	}
+
+	root := project.Root
+	svc := configMicroservice.NewConfigMicroservice()
+	svc.ProjectName = ref.Name
+	svc.ProjectType = root.ProjectType
+	svc.CloudProvider = root.CloudProvider
+	svc.Language = result.Language
+	svc.Framework = result.Framework
+	svc.DetectedFiles = result.DetectedFiles
+	svc.Port = detect.DefaultPort(result.Language)
+	if flags.Port != 0 {
+		if flags.Port < 1 || flags.Port > 65535 {
+			return fmt.Errorf("port must be between 1 and 65535, got %d", flags.Port)
+		}
+		svc.Port = flags.Port
+	}
+	if flags.Registry != "" {
+		if err := cloud.ValidateRegistry(flags.Registry); err != nil {
+			return err
+		}
+		svc.ContainerRegistry = flags.Registry
+	}
+
+	data, err := yaml.Marshal(svc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	if err := saveServices(flags.ProjectPath, refs, configPath, data); err != nil {
+		return err
+	}
+
+	effective := configMicroservice.Resolve(root, svc)
+	fmt.Printf("✅ Added %s from %s (%s", ref.Name, ref.Path, svc.Language)
+	if svc.Framework != "" {
+		fmt.Printf("/%s", svc.Framework)
+	}
+	fmt.Printf(", port %d, registry %s)\n", svc.Port, effective.ContainerRegistry)
+	return nil
+}
+
+func runRemove(flags *ServiceFlags, name string) error {
+	project, err := loadMicroservice(flags.ProjectPath, "")
+	if err != nil {
+		return err
+	}
+
+	svc, ok := project.FindService(name)
+	if !ok {
+		return fmt.Errorf("service %q not found", name)
+	}
+
+	var refs []configMicroservice.ServiceRef
+	removed := configMicroservice.ServiceRef{Name: svc.Name, Aliases: svc.Aliases}
+	for _, ref := range project.Root.ServiceRefs() {
+		if ref.Name == svc.Name {
+			continue
+		}
+		// The other services no longer depend on the removed one
+		var dependsOn []string
+		for _, dependency := range ref.DependsOn {
+			if removed.Matches(dependency) {
+				fmt.Printf("⚠️  %s depended on %s, the dependency was removed\n", ref.Name, svc.Name)
+				continue
+			}
+			dependsOn = append(dependsOn, dependency)
+		}
+		ref.DependsOn = dependsOn
+		refs = append(refs, ref)
+	}
+	if err := saveServices(flags.ProjectPath, refs, "", nil); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Removed %s from the project, %s was kept\n", svc.Name, filepath.Join(svc.Path, configProject.ConfigDir))
+	return nil
+}
+
+func runList(flags *ServiceFlags, env string) error {
+	project, err := loadMicroservice(flags.ProjectPath, env)
+	if err != nil {
+		return err
+	}
+
+	if len(project.Services) == 0 {
+		fmt.Println("No services, add one with 'daab service add <path>'.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPATH\tALIASES\tLANGUAGE\tFRAMEWORK\tPORT\tREGISTRY")
+	for _, svc := range project.Services {
+		effective := svc.Effective
+		framework := effective.Framework
+		if framework == "" {
+			framework = "-"
+		}
+		aliases := strings.Join(svc.Aliases, ",")
+		if aliases == "" {
+			aliases = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", svc.Name, svc.Path, aliases, effective.Language, framework, effective.Port, effective.ContainerRegistry)
+	}
+	return w.Flush()
+}
+
+// saveServices writes the service list of daab.root.yaml, together with the config of a
+// new service when configPath is set. Both files are written or none.
+func saveServices(projectPath string, services []configMicroservice.ServiceRef, configPath string, config []byte) error {
+	rootPath := filepath.Join(projectPath, configProject.ConfigDir, configProject.RootConfigFile)
+	content, err := os.ReadFile(rootPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", rootPath, err)
+	}
+	updated, err := configProject.SetRootServices(content, services)
+	if err != nil {
+		return err
+	}
+
+	tx := fsutil.NewTransaction()
+	if configPath != "" {
+		tx.Stage(configPath, config, 0644)
+	}
+	tx.Stage(rootPath, updated, 0644)
+	return tx.Commit()
+}
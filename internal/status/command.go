@@ -0,0 +1,121 @@
+package statuscmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+type StatusFlags struct {
+	ProjectPath string
+	Env         string
+	Output      string
+}
+
+func NewStatusCommand() *cobra.Command {
+	flags := &StatusFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show what DAAB knows about your project",
+		Long: `Load .init/daab.yaml (or .init/daab.root.yaml for microservices) and show every
+service with its language, framework, port, registry and namespace. For each service
+it also checks that the detected files still exist, runs the detection again to find
+drift from the stored config, and lists the generated artifacts that are present,
+missing or stale (older than the config they were generated from).`,
+		Example: `  daab status
+  daab status --env production
+  daab status --output json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags.Env, _ = cmd.Flags().GetString("env")
+			return runStatus(flags)
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.ProjectPath, "project-path", ".", "Path to the project directory")
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "table", "Output format: table or json")
+
+	return cmd
+}
+
+func runStatus(flags *StatusFlags) error {
+	if flags.Output != "table" && flags.Output != "json" {
+		return fmt.Errorf("unknown output format %q: use table or json", flags.Output)
+	}
+
+	status, err := Collect(flags.ProjectPath, flags.Env)
+	if err != nil {
+		return err
+	}
+
+	if flags.Output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(status)
+	}
+	printTable(status)
+	return nil
+}
+
+func printTable(status *ProjectStatus) {
+	fmt.Printf("📦 Project:  %s (%s)\n", status.Name, status.Type)
+	fmt.Printf("☁️  Cloud:    %s, %s\n", status.CloudProvider, status.Region)
+	if status.Environment != "" {
+		fmt.Printf("🌍 Env:      %s\n", status.Environment)
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tLANGUAGE\tFRAMEWORK\tPORT\tREGISTRY\tTARGET\tNAMESPACE\tFILES\tDRIFT\tARTIFACTS")
+	for _, svc := range status.Services {
+		files := "ok"
+		if missing := len(svc.MissingFiles()); missing > 0 {
+			files = fmt.Sprintf("%d missing", missing)
+		}
+		drift := "no"
+		if svc.HasDrift() {
+			drift = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n", svc.Name, orNone(svc.Language), orNone(svc.Framework),
+			svc.Port, orNone(svc.Registry), svc.Target, orNone(svc.Namespace), files, drift, artifactSummary(svc.Artifacts))
+	}
+	w.Flush()
+
+	// Details of everything that needs attention
+	for _, svc := range status.Services {
+		var details []string
+		for _, file := range svc.MissingFiles() {
+			details = append(details, fmt.Sprintf("detected file %s no longer exists", file))
+		}
+		for _, drift := range svc.Drift {
+			details = append(details, "drift: "+drift)
+		}
+		for _, artifact := range svc.Artifacts {
+			if artifact.State == ArtifactStale {
+				details = append(details, fmt.Sprintf("%s is older than the config, run 'daab generate'", artifact.Path))
+			}
+		}
+		if len(details) == 0 {
+			continue
+		}
+		fmt.Println()
+		fmt.Printf("⚠️  %s (%s)\n", svc.Name, filepath.Clean(svc.Path))
+		for _, detail := range details {
+			fmt.Printf("   - %s\n", detail)
+		}
+	}
+}
+
+// artifactSummary renders e.g. "2/4 (1 stale)".
+func artifactSummary(artifacts []ArtifactStatus) string {
+	counts := countArtifacts(artifacts)
+	summary := fmt.Sprintf("%d/%d", counts[ArtifactPresent]+counts[ArtifactStale], len(artifacts))
+	if counts[ArtifactStale] > 0 {
+		summary += fmt.Sprintf(" (%d stale)", counts[ArtifactStale])
+	}
+	return summary
+}
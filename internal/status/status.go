@@ -0,0 +1,231 @@
+package statuscmd
+
+import (
+	"os"
+	"path/filepath"
+
+	config "github.com/mouad4949/DAAB/pkg/config"
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+	"github.com/mouad4949/DAAB/pkg/detect"
+	"github.com/mouad4949/DAAB/pkg/generate"
+)
+
+// Artifact states reported by daab status.
+const (
+	ArtifactPresent = "present"
+	ArtifactStale   = "stale"
+	ArtifactMissing = "missing"
+)
+
+// ProjectStatus is what daab knows about an initialised project.
+type ProjectStatus struct {
+	Path          string          `json:"path"`
+	Name          string          `json:"name"`
+	Type          string          `json:"type"`
+	Environment   string          `json:"environment,omitempty"`
+	CloudProvider string          `json:"cloud_provider"`
+	Region        string          `json:"region"`
+	Services      []ServiceStatus `json:"services"`
+}
+
+// ServiceStatus describes one service, or the application of a monolith project.
+type ServiceStatus struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Language  string `json:"language"`
+	Framework string `json:"framework,omitempty"`
+	Port      int    `json:"port"`
+	Registry  string `json:"registry,omitempty"`
+	Target    string `json:"target"`
+	// Namespace is only set for the kubernetes target
+	Namespace     string           `json:"namespace,omitempty"`
+	DetectedFiles []FileStatus     `json:"detected_files"`
+	Drift         []string         `json:"drift,omitempty"`
+	Artifacts     []ArtifactStatus `json:"artifacts"`
+}
+
+// FileStatus tells whether a detected file is still in the service folder.
+type FileStatus struct {
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
+}
+
+// ArtifactStatus tells whether a generated file exists and is newer than the config.
+type ArtifactStatus struct {
+	Path  string `json:"path"`
+	State string `json:"state"`
+}
+
+// HasDrift reports whether the detection differs from the stored config.
+func (s *ServiceStatus) HasDrift() bool {
+	return len(s.Drift) > 0
+}
+
+// MissingFiles returns the detected files that no longer exist.
+func (s *ServiceStatus) MissingFiles() []string {
+	var missing []string
+	for _, file := range s.DetectedFiles {
+		if !file.Exists {
+			missing = append(missing, file.Path)
+		}
+	}
+	return missing
+}
+
+// Collect loads the project configuration for env and checks every service against
+// the files on disk.
+func Collect(projectPath, env string) (*ProjectStatus, error) {
+	project, err := configProject.Load(projectPath, env)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &ProjectStatus{
+		Path:        projectPath,
+		Environment: env,
+	}
+
+	if !project.IsMicroservice() {
+		monolith := project.Monolith
+		status.Name = monolith.ProjectName
+		status.Type = monolith.ProjectType
+		status.CloudProvider = monolith.CloudProvider
+		status.Region = monolith.Region
+
+		configFiles := configFilesFor(filepath.Join(projectPath, configProject.ConfigDir, configProject.ConfigFile), env)
+		service := serviceStatus(projectPath, &monolith.BaseConfigApp, configFiles)
+		if config.IsKubernetes(monolith.Target) {
+			service.Namespace = monolith.Namespace
+		}
+		status.Services = append(status.Services, service)
+		return status, nil
+	}
+
+	root := project.Root
+	status.Name = root.ProjectName
+	status.Type = root.ProjectType
+	status.CloudProvider = root.CloudProvider
+	status.Region = root.Region
+
+	rootFiles := configFilesFor(filepath.Join(projectPath, configProject.ConfigDir, configProject.RootConfigFile), env)
+	for _, svc := range project.Services {
+		// Inherited settings come from daab.root.yaml, so its changes make artifacts stale too
+		configFiles := append(configFilesFor(filepath.Join(svc.Path, configProject.ConfigDir, configProject.ConfigFile), env), rootFiles...)
+		service := serviceStatus(svc.Path, &svc.Effective.BaseConfigApp, configFiles)
+		service.Name = svc.Name
+		if config.IsKubernetes(svc.Effective.Target) {
+			service.Namespace = svc.Effective.Namespace
+		}
+		status.Services = append(status.Services, service)
+	}
+	return status, nil
+}
+
+func serviceStatus(path string, app *config.BaseConfigApp, configFiles []string) ServiceStatus {
+	service := ServiceStatus{
+		Name:      app.ProjectName,
+		Path:      path,
+		Language:  app.Language,
+		Framework: app.Framework,
+		Port:      app.Port,
+		Registry:  app.ContainerRegistry,
+		Target:    config.TargetOrDefault(app.Target),
+	}
+	if service.Name == "" {
+		service.Name = filepath.Base(path)
+	}
+
+	for _, file := range app.DetectedFiles {
+		service.DetectedFiles = append(service.DetectedFiles, FileStatus{
+			Path:   file,
+			Exists: fileExists(filepath.Join(path, file)),
+		})
+	}
+
+	service.Drift = detectDrift(path, app)
+	service.Artifacts = artifactStatus(path, generate.ArtifactsFor(app.Target), configFiles)
+	return service
+}
+
+// detectDrift runs the detection again and lists the differences with the stored config.
+func detectDrift(path string, app *config.BaseConfigApp) []string {
+	result, err := detect.NewDetector(path).Detect()
+	if err != nil {
+		return []string{"language can no longer be detected"}
+	}
+
+	var drift []string
+	if result.Language != app.Language {
+		drift = append(drift, "language: "+orNone(app.Language)+" -> "+result.Language)
+	}
+	if result.Framework != app.Framework {
+		drift = append(drift, "framework: "+orNone(app.Framework)+" -> "+orNone(result.Framework))
+	}
+
+	stored := map[string]bool{}
+	for _, file := range app.DetectedFiles {
+		stored[file] = true
+	}
+	for _, file := range result.DetectedFiles {
+		if !stored[file] {
+			drift = append(drift, "new detected file: "+file)
+		}
+	}
+	return drift
+}
+
+// artifactStatus checks the generated artifacts of a folder. An artifact is stale when
+// one of the config files it was generated from changed after it.
+func artifactStatus(path string, expected []string, configFiles []string) []ArtifactStatus {
+	var configTime int64
+	for _, file := range configFiles {
+		if info, err := os.Stat(file); err == nil && info.ModTime().UnixNano() > configTime {
+			configTime = info.ModTime().UnixNano()
+		}
+	}
+
+	artifacts := make([]ArtifactStatus, 0, len(expected))
+	for _, artifact := range expected {
+		state := ArtifactMissing
+		if info, err := os.Stat(filepath.Join(path, artifact)); err == nil {
+			state = ArtifactPresent
+			if info.ModTime().UnixNano() < configTime {
+				state = ArtifactStale
+			}
+		}
+		artifacts = append(artifacts, ArtifactStatus{Path: artifact, State: state})
+	}
+	return artifacts
+}
+
+// configFilesFor returns the base config file and, when it exists, the overlay for env.
+func configFilesFor(basePath, env string) []string {
+	files := []string{basePath}
+	if env != "" {
+		if overlay := config.OverlayPath(basePath, env); fileExists(overlay) {
+			files = append(files, overlay)
+		}
+	}
+	return files
+}
+
+// countArtifacts returns how many artifacts are in each state.
+func countArtifacts(artifacts []ArtifactStatus) map[string]int {
+	counts := map[string]int{}
+	for _, artifact := range artifacts {
+		counts[artifact.State]++
+	}
+	return counts
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
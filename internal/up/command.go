@@ -0,0 +1,333 @@
+package upcmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mouad4949/DAAB/internal/fsutil"
+	"github.com/mouad4949/DAAB/pkg/build"
+	config "github.com/mouad4949/DAAB/pkg/config"
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+	"github.com/mouad4949/DAAB/pkg/deploy"
+	"github.com/mouad4949/DAAB/pkg/generate"
+	"github.com/mouad4949/DAAB/pkg/local"
+	"github.com/mouad4949/DAAB/pkg/secrets"
+	"github.com/spf13/cobra"
+)
+
+// Files of the local cluster, in the .init folder of the project.
+const (
+	LocalDir    = "local"
+	ClusterFile = "cluster.yaml"
+	// BuildsFile records the local builds apart from the builds pushed to the registry
+	BuildsFile = "builds.yaml"
+)
+
+// localPath returns the path of a file of the local cluster of the project.
+func localPath(projectPath, name string) string {
+	return filepath.Join(projectPath, configProject.ConfigDir, LocalDir, name)
+}
+
+type UpFlags struct {
+	ProjectPath string
+	Env         string
+	Local       bool
+	Provider    string
+	Cluster     string
+	Services    []string
+	Force       bool
+	NoForward   bool
+}
+
+func NewUpCommand() *cobra.Command {
+	flags := &UpFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "up",
+		Short: "Run the project on a local Kubernetes cluster",
+		Long: `Try the generated Kubernetes deployment on your machine before deploying to the cloud.
+With --local, daab:
+
+  1. writes the config of a kind or k3d cluster to .init/local and creates the
+     cluster, named daab-<project>, unless it is already running;
+  2. builds the images with docker, without registry, recorded in .init/local/builds.yaml;
+  3. loads the images into the nodes of the cluster;
+  4. deploys the manifests to the namespace of every service, with its strategy;
+  5. forwards the port of every service to localhost until Ctrl+C.
+
+Running 'daab up --local' again reuses the cluster and only rebuilds the services that
+changed. Services with a serverless target are skipped. 'daab down' deletes the cluster.`,
+		Example: `  daab up --local
+  daab up --local --provider k3d
+  daab up --local --service users-api --no-port-forward`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags.Env, _ = cmd.Flags().GetString("env")
+			if !flags.Local {
+				return fmt.Errorf("daab up only runs local clusters, use --local ('daab deploy' deploys to a remote cluster)")
+			}
+			cmd.SilenceUsage = true
+			return runUp(cmd.Context(), flags)
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.ProjectPath, "project-path", ".", "Path to the project directory")
+	cmd.Flags().BoolVar(&flags.Local, "local", false, "Run the project on a kind or k3d cluster on this machine")
+	cmd.Flags().StringVar(&flags.Provider, "provider", "auto", fmt.Sprintf("Local cluster tool: auto, %v", local.Providers()))
+	cmd.Flags().StringVar(&flags.Cluster, "cluster", "", "Name of the local cluster (default: daab-<project>)")
+	cmd.Flags().StringSliceVar(&flags.Services, "service", nil, "Only run these services (repeatable)")
+	cmd.Flags().BoolVar(&flags.Force, "force", false, "Build every service, even when it did not change")
+	cmd.Flags().BoolVar(&flags.NoForward, "no-port-forward", false, "Exit once deployed, without forwarding the ports")
+
+	return cmd
+}
+
+func runUp(ctx context.Context, flags *UpFlags) error {
+	project, err := configProject.Load(flags.ProjectPath, flags.Env)
+	if err != nil {
+		return err
+	}
+	if err := project.ResolveSecrets(ctx, secrets.NewDefaultResolver(flags.ProjectPath)); err != nil {
+		return err
+	}
+
+	selected, err := build.SelectApps(project, flags.Services)
+	if err != nil {
+		return err
+	}
+	var apps []*generate.App
+	for _, app := range selected {
+		if !config.IsKubernetes(app.Target) {
+			fmt.Printf("⏭️  Skipping %s: the %s target does not run on Kubernetes\n", app.Name, app.Target)
+			continue
+		}
+		// Local images are loaded into the cluster, they are never pulled
+		app.Registry = ""
+		apps = append(apps, app)
+	}
+	if len(apps) == 0 {
+		return fmt.Errorf("no service runs on Kubernetes")
+	}
+
+	cluster, provider, err := ensureCluster(ctx, flags, project)
+	if err != nil {
+		return err
+	}
+
+	if err := buildAndLoad(ctx, flags, cluster, provider, apps); err != nil {
+		return err
+	}
+
+	fmt.Printf("🚀 Deploying %d services to %s...\n", len(apps), cluster.Context)
+	_, err = deploy.Deploy(ctx, apps, deploy.Options{
+		Cluster:     &deploy.Kubectl{Context: cluster.Context, Output: os.Stdout},
+		Output:      os.Stdout,
+		Environment: flags.Env,
+	})
+	if err != nil {
+		return err
+	}
+
+	if flags.NoForward {
+		fmt.Printf("✅ Running on %s, 'daab down' deletes the cluster\n", cluster.Context)
+		return nil
+	}
+
+	forwards := local.PlanForwards(apps)
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, forward := range forwards {
+		fmt.Fprintf(w, "🔌 %s\t%s\n", forward.App, forward.URL())
+	}
+	w.Flush()
+	fmt.Println()
+	fmt.Println("Press Ctrl+C to stop the port-forwards, 'daab down' deletes the cluster.")
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return local.RunForwards(ctx, cluster.Context, forwards, os.Stderr)
+}
+
+// ensureCluster creates the local cluster of the project, unless it is running, and
+// records it for 'daab down'.
+func ensureCluster(ctx context.Context, flags *UpFlags, project *configProject.Project) (*local.Cluster, local.Provider, error) {
+	recorded, err := local.LoadCluster(localPath(flags.ProjectPath, ClusterFile))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	providerName, name := flags.Provider, flags.Cluster
+	if recorded != nil {
+		// Keep using the cluster of the previous 'daab up'
+		if providerName == "" || providerName == "auto" {
+			providerName = recorded.Provider
+		}
+		if name == "" && providerName == recorded.Provider {
+			name = recorded.Name
+		}
+	}
+	if name == "" {
+		name = "daab-" + projectName(project)
+	}
+
+	provider, err := local.NewProvider(providerName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if recorded != nil && (recorded.Provider != provider.Name() || recorded.Name != name) {
+		return nil, nil, fmt.Errorf("the local cluster %s (%s) is still recorded, run 'daab down' first", recorded.Name, recorded.Provider)
+	}
+
+	if err := os.MkdirAll(localPath(flags.ProjectPath, ""), 0755); err != nil {
+		return nil, nil, err
+	}
+	exists, err := provider.Exists(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	cluster := &local.Cluster{Provider: provider.Name(), Name: name, Context: provider.Context(name), CreatedAt: time.Now().UTC()}
+	if recorded != nil {
+		cluster.CreatedAt = recorded.CreatedAt
+	}
+
+	if exists {
+		fmt.Printf("♻️  Reusing the %s cluster %s\n", provider.Name(), name)
+	} else {
+		configPath := localPath(flags.ProjectPath, provider.ConfigFile())
+		content, err := provider.Config(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := fsutil.WriteFileAtomic(configPath, append([]byte(generate.Header), content...), 0644); err != nil {
+			return nil, nil, err
+		}
+
+		fmt.Printf("☸️  Creating the %s cluster %s from %s...\n", provider.Name(), name, configPath)
+		if err := provider.Create(ctx, name, configPath, os.Stdout); err != nil {
+			return nil, nil, fmt.Errorf("failed to create the cluster: %w", err)
+		}
+		cluster.CreatedAt = time.Now().UTC()
+	}
+
+	data, err := cluster.Marshal()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := fsutil.WriteFileAtomic(localPath(flags.ProjectPath, ClusterFile), data, 0644); err != nil {
+		return nil, nil, fmt.Errorf("failed to record the cluster: %w", err)
+	}
+	return cluster, provider, nil
+}
+
+// buildAndLoad builds the images of apps with docker and loads them into the cluster.
+// Every app then runs its loaded image.
+func buildAndLoad(ctx context.Context, flags *UpFlags, cluster *local.Cluster, provider local.Provider, apps []*generate.App) error {
+	builder, err := build.New(build.BuilderDocker)
+	if err != nil {
+		return fmt.Errorf("%w: local clusters load the images of the docker daemon", err)
+	}
+	state, err := build.LoadState(localPath(flags.ProjectPath, BuildsFile))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🔨 Building %d images...\n", len(apps))
+	results := build.BuildAll(ctx, apps, build.Options{
+		Builder:     builder,
+		Concurrency: runtime.NumCPU(),
+		Force:       flags.Force,
+		State:       state,
+		Output:      os.Stdout,
+	})
+
+	data, err := state.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFileAtomic(state.Path(), data, 0644); err != nil {
+		return fmt.Errorf("failed to save build state: %w", err)
+	}
+
+	for idx, result := range results {
+		if result.Err != nil {
+			return fmt.Errorf("%s: %w", result.App, result.Err)
+		}
+		fmt.Printf("📦 Loading %s into %s\n", result.Image, cluster.Name)
+		if err := provider.LoadImage(ctx, cluster.Name, result.Image, os.Stdout); err != nil {
+			return fmt.Errorf("%s: failed to load the image, build it again with --force if it was removed from docker: %w", result.App, err)
+		}
+		apps[idx].Reference = result.Image
+	}
+	return nil
+}
+
+func projectName(project *configProject.Project) string {
+	if project.IsMicroservice() {
+		return config.DNSName(project.Root.ProjectName)
+	}
+	return config.DNSName(project.Monolith.ProjectName)
+}
+
+type DownFlags struct {
+	ProjectPath string
+}
+
+func NewDownCommand() *cobra.Command {
+	flags := &DownFlags{}
+
+	cmd := &cobra.Command{
+		Use:   "down",
+		Short: "Delete the local cluster created by 'daab up --local'",
+		Long: `Delete the kind or k3d cluster of the project, with everything deployed to it, and
+remove the .init/local folder: the cluster config and the local builds.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return runDown(cmd.Context(), flags)
+		},
+	}
+
+	cmd.Flags().StringVar(&flags.ProjectPath, "project-path", ".", "Path to the project directory")
+
+	return cmd
+}
+
+func runDown(ctx context.Context, flags *DownFlags) error {
+	cluster, err := local.LoadCluster(localPath(flags.ProjectPath, ClusterFile))
+	if err != nil {
+		return err
+	}
+	if cluster == nil {
+		return fmt.Errorf("no local cluster recorded in %s, start one with 'daab up --local'", localPath(flags.ProjectPath, ""))
+	}
+
+	provider, err := local.NewProvider(cluster.Provider)
+	if err != nil {
+		return err
+	}
+	exists, err := provider.Exists(ctx, cluster.Name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		fmt.Printf("🗑️  Deleting the %s cluster %s...\n", provider.Name(), cluster.Name)
+		if err := provider.Delete(ctx, cluster.Name, os.Stdout); err != nil {
+			return fmt.Errorf("failed to delete the cluster: %w", err)
+		}
+	} else {
+		fmt.Printf("⚠️  The %s cluster %s no longer exists\n", provider.Name(), cluster.Name)
+	}
+
+	if err := os.RemoveAll(localPath(flags.ProjectPath, "")); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Local cluster %s removed\n", cluster.Name)
+	return nil
+}
@@ -0,0 +1,297 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+	"github.com/mouad4949/DAAB/pkg/generate"
+)
+
+// Options configure BuildAll.
+type Options struct {
+	Builder Builder
+	// Concurrency is the maximum number of images built at the same time
+	Concurrency int
+	// Force builds every application, even when its sources did not change
+	Force bool
+	// Tag of the images, GitTag of the project when empty
+	Tag string
+	// State records the previous builds, it is updated with the successful ones
+	State *State
+	// Output receives the logs of the builds, each line prefixed with the application name
+	Output io.Writer
+}
+
+// Result is the outcome of building one application.
+type Result struct {
+	App   string
+	Image string
+	// Skipped is set when the sources did not change since the recorded build
+	Skipped  bool
+	Duration time.Duration
+	Err      error
+}
+
+// BuildAll builds the image of every app. Apps are built concurrently, at most
+// opts.Concurrency at a time; results are returned in the order of apps.
+func BuildAll(ctx context.Context, apps []*generate.App, opts Options) []Result {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	output := opts.Output
+	if output == nil {
+		output = io.Discard
+	}
+	logs := &lockedWriter{w: output}
+
+	results := make([]Result, len(apps))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(concurrency, len(apps)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				results[idx] = buildApp(ctx, apps[idx], opts, logs)
+			}
+		}()
+	}
+
+	for idx := range apps {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func buildApp(ctx context.Context, app *generate.App, opts Options, logs *lockedWriter) Result {
+	start := time.Now()
+	result := Result{App: app.Name}
+	fail := func(err error) Result {
+		result.Err = err
+		result.Duration = time.Since(start)
+		return result
+	}
+
+	dockerfile, cleanup, err := dockerfileFor(app)
+	if err != nil {
+		return fail(err)
+	}
+	defer cleanup()
+
+	hash, err := buildHash(app.Path, dockerfile)
+	if err != nil {
+		return fail(err)
+	}
+
+	tag := opts.Tag
+	if tag == "" {
+		if tag, err = GitTag(app.Path, hash); err != nil {
+			return fail(err)
+		}
+	}
+	result.Image = app.Image() + ":" + tag
+
+	if opts.State != nil && !opts.Force {
+		if previous, ok := opts.State.Get(app.Name); ok && previous.Hash == hash && previous.Image == app.Image() {
+			result.Image = previous.Reference()
+			result.Skipped = true
+			result.Duration = time.Since(start)
+			return result
+		}
+	}
+
+	out := &prefixWriter{prefix: "[" + app.Name + "] ", w: logs}
+	err = opts.Builder.Build(ctx, Request{
+		ContextDir: app.Path,
+		Dockerfile: dockerfile,
+		Tags:       []string{result.Image},
+		Output:     out,
+		App:        app,
+	})
+	out.Flush()
+	if err != nil {
+		return fail(err)
+	}
+
+	if opts.State != nil {
+		opts.State.Set(app.Name, Record{
+			Hash:    hash,
+			Image:   app.Image(),
+			Tag:     tag,
+			Builder: opts.Builder.Name(),
+			BuiltAt: time.Now().UTC(),
+		})
+	}
+	result.Duration = time.Since(start)
+	return result
+}
+
+// buildHash combines the hash of the build context with the Dockerfile, which may be
+// generated outside of it.
+func buildHash(dir, dockerfile string) (string, error) {
+	contextHash, err := HashDir(dir)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(dockerfile)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(contextHash))
+	hash.Write(content)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// dockerfileFor returns the Dockerfile of app: the one in its folder, or one rendered by
+// the generator into a temporary folder when the app has none.
+func dockerfileFor(app *generate.App) (string, func(), error) {
+	existing := filepath.Join(app.Path, generate.DockerfilePath)
+	if _, err := os.Stat(existing); err == nil {
+		return existing, func() {}, nil
+	}
+
+	files, err := generate.Generate(app, &generate.Dockerfile{})
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := os.MkdirTemp("", "daab-build-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	for _, file := range files {
+		if file.Path != generate.DockerfilePath {
+			continue
+		}
+		path := filepath.Join(dir, generate.DockerfilePath)
+		if err := os.WriteFile(path, file.Content, 0644); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		return path, cleanup, nil
+	}
+	cleanup()
+	return "", nil, fmt.Errorf("no Dockerfile generated for %s", app.Name)
+}
+
+// GitTag returns the short commit of the git repository holding dir, suffixed with
+// "-dirty" when dir has uncommitted changes. Outside a git repository, the tag is derived
+// from the content hash.
+func GitTag(dir, hash string) (string, error) {
+	sha, err := git(dir, "rev-parse", "--short=12", "HEAD")
+	if err != nil {
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+		return "dev-" + hash, nil
+	}
+
+	status, err := git(dir, "status", "--porcelain", "--", ".")
+	if err != nil {
+		return "", err
+	}
+	if status != "" {
+		sha += "-dirty"
+	}
+	return sha, nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// lockedWriter serialises writes from concurrent builds.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// prefixWriter writes complete lines, each prefixed, so concurrent logs stay readable.
+type prefixWriter struct {
+	prefix string
+	w      io.Writer
+	buf    bytes.Buffer
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.buf.Write(data)
+	for {
+		line, err := p.buf.ReadBytes('\n')
+		if err != nil {
+			// Keep the incomplete line for the next write
+			p.buf.Write(line)
+			return len(data), nil
+		}
+		if _, err := p.w.Write(append([]byte(p.prefix), line...)); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Flush writes the last line when it does not end with a newline.
+func (p *prefixWriter) Flush() {
+	if p.buf.Len() > 0 {
+		p.w.Write([]byte(p.prefix + p.buf.String() + "\n"))
+		p.buf.Reset()
+	}
+}
+
+// SelectApps returns the applications of the project, restricted to services when set.
+// The applications keep the order of the project, services after their dependencies.
+func SelectApps(project *configProject.Project, services []string) ([]*generate.App, error) {
+	apps := generate.AppsFromProject(project)
+	if len(services) == 0 {
+		return apps, nil
+	}
+	if !project.IsMicroservice() {
+		return nil, fmt.Errorf("--service can only be used in microservice projects")
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range services {
+		svc, ok := project.FindService(name)
+		if !ok {
+			return nil, fmt.Errorf("service %q not found", name)
+		}
+		wanted[svc.Name] = true
+	}
+
+	var selected []*generate.App
+	for _, app := range apps {
+		if wanted[app.Name] {
+			selected = append(selected, app)
+		}
+	}
+	return selected, nil
+}
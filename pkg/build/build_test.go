@@ -0,0 +1,52 @@
+package build
+
+import (
+	"reflect"
+	"testing"
+
+	configMicroservice "github.com/mouad4949/DAAB/pkg/config/microservice"
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+)
+
+func TestSelectApps(t *testing.T) {
+	project := &configProject.Project{Root: configMicroservice.NewConfigMicroRoot()}
+	// Services in deploy order: users before the services calling it
+	for _, name := range []string{"users", "billing", "web"} {
+		svc := configProject.Service{Name: name, Path: name, Effective: configMicroservice.NewConfigMicroservice()}
+		if name == "users" {
+			svc.Aliases = []string{"users-api"}
+		}
+		project.Services = append(project.Services, svc)
+	}
+
+	tests := []struct {
+		services []string
+		want     []string
+		wantErr  bool
+	}{
+		{want: []string{"users", "billing", "web"}},
+		{services: []string{"web", "users"}, want: []string{"users", "web"}},
+		{services: []string{"billing", "users-api", "billing"}, want: []string{"users", "billing"}},
+		{services: []string{"web", "orders"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		apps, err := SelectApps(project, tt.services)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("SelectApps(%q) succeeded, want an error", tt.services)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("SelectApps(%q): %v", tt.services, err)
+		}
+		var got []string
+		for _, app := range apps {
+			got = append(got, app.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SelectApps(%q) = %q, want %q", tt.services, got, tt.want)
+		}
+	}
+}
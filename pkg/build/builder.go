@@ -0,0 +1,223 @@
+// Package build builds the container images of DAAB applications with a pluggable
+// builder backend, skipping applications whose sources did not change.
+package build
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mouad4949/DAAB/pkg/generate"
+)
+
+// Request describes one image build.
+type Request struct {
+	// ContextDir is the build context, usually the application folder
+	ContextDir string
+	// Dockerfile is the path of the Dockerfile, it does not need to be inside ContextDir
+	Dockerfile string
+	// Tags are the full image references to tag the image with
+	Tags []string
+	// Output receives the logs of the build
+	Output io.Writer
+	// App is the application being built, for backends that do not use the Dockerfile
+	App *generate.App
+}
+
+// Builder is a backend that turns a Dockerfile and a context into an image.
+type Builder interface {
+	Name() string
+	// Available returns an error when the backend cannot be used on this machine
+	Available() error
+	Build(ctx context.Context, req Request) error
+}
+
+// CommandRunner runs an external command, writing its output to out.
+type CommandRunner func(ctx context.Context, out io.Writer, name string, args ...string) error
+
+// RunCommand is the CommandRunner used by the builders unless they are given another one.
+func RunCommand(ctx context.Context, out io.Writer, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	return nil
+}
+
+// Names of the builtin builders.
+const (
+	BuilderDocker   = "docker"
+	BuilderBuildKit = "buildkit"
+	BuilderBuildah  = "buildah"
+)
+
+// buildersMu guards builders: Register may run while commands look builders up.
+var buildersMu sync.RWMutex
+
+var builders = map[string]func() Builder{
+	BuilderDocker:   func() Builder { return &DockerBuilder{} },
+	BuilderBuildKit: func() Builder { return &BuildKitBuilder{} },
+	BuilderBuildah:  func() Builder { return &BuildahBuilder{} },
+	BuilderOCI:      func() Builder { return &OCIBuilder{} },
+}
+
+// autoOrder is the order in which New picks a builder when none is requested.
+var autoOrder = []string{BuilderDocker, BuilderBuildKit, BuilderBuildah, BuilderOCI}
+
+// Register adds a builder backend that can then be selected by name.
+func Register(name string, factory func() Builder) {
+	buildersMu.Lock()
+	defer buildersMu.Unlock()
+	builders[name] = factory
+}
+
+// Builders returns the names of the registered backends.
+func Builders() []string {
+	buildersMu.RLock()
+	defer buildersMu.RUnlock()
+	names := make([]string, 0, len(builders))
+	for name := range builders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns the builder called name. An empty name or "auto" picks the first available
+// of docker, buildkit, buildah and oci.
+func New(name string) (Builder, error) {
+	if name == "" || name == "auto" {
+		for _, candidate := range autoOrder {
+			factory, _ := lookupBuilder(candidate)
+			builder := factory()
+			if builder.Available() == nil {
+				return builder, nil
+			}
+		}
+		return nil, fmt.Errorf("no image builder available, install docker, buildctl (buildkit), buildah, or go for the oci builder")
+	}
+
+	factory, ok := lookupBuilder(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown builder %q, available builders: %v", name, Builders())
+	}
+	builder := factory()
+	if err := builder.Available(); err != nil {
+		return nil, err
+	}
+	return builder, nil
+}
+
+// lookupBuilder returns the factory of the builder called name.
+func lookupBuilder(name string) (func() Builder, bool) {
+	buildersMu.RLock()
+	defer buildersMu.RUnlock()
+	factory, ok := builders[name]
+	return factory, ok
+}
+
+func lookPath(binary, backend string) error {
+	if _, err := exec.LookPath(binary); err != nil {
+		return fmt.Errorf("the %s builder needs %s in PATH", backend, binary)
+	}
+	return nil
+}
+
+/******************************************************/
+/************Backends*********************************/
+/****************************************************/
+
+// DockerBuilder builds with the docker CLI and its daemon.
+type DockerBuilder struct {
+	Run CommandRunner
+}
+
+// Name returns "docker".
+func (b *DockerBuilder) Name() string {
+	return BuilderDocker
+}
+
+// Available checks that the docker CLI is installed.
+func (b *DockerBuilder) Available() error {
+	return lookPath("docker", BuilderDocker)
+}
+
+// Build runs 'docker build' with the Dockerfile and tags of req.
+func (b *DockerBuilder) Build(ctx context.Context, req Request) error {
+	args := []string{"build", "--file", req.Dockerfile}
+	for _, tag := range req.Tags {
+		args = append(args, "--tag", tag)
+	}
+	args = append(args, req.ContextDir)
+	return runner(b.Run)(ctx, req.Output, "docker", args...)
+}
+
+// BuildKitBuilder builds with buildctl against a buildkitd, selected with $BUILDKIT_HOST.
+// buildkitd can run rootless, so CI jobs do not need privileged containers.
+type BuildKitBuilder struct {
+	Run CommandRunner
+}
+
+// Name returns "buildkit".
+func (b *BuildKitBuilder) Name() string {
+	return BuilderBuildKit
+}
+
+// Available checks that buildctl is installed.
+func (b *BuildKitBuilder) Available() error {
+	return lookPath("buildctl", BuilderBuildKit)
+}
+
+// Build runs 'buildctl build' with the dockerfile frontend and exports an image named
+// with the tags of req.
+func (b *BuildKitBuilder) Build(ctx context.Context, req Request) error {
+	args := []string{
+		"build",
+		"--frontend", "dockerfile.v0",
+		"--local", "context=" + req.ContextDir,
+		"--local", "dockerfile=" + filepath.Dir(req.Dockerfile),
+		"--opt", "filename=" + filepath.Base(req.Dockerfile),
+		"--output", "type=image,\"name=" + strings.Join(req.Tags, ",") + "\",push=false",
+	}
+	return runner(b.Run)(ctx, req.Output, "buildctl", args...)
+}
+
+// BuildahBuilder builds without any daemon with buildah, using chroot isolation so it
+// works in unprivileged CI containers.
+type BuildahBuilder struct {
+	Run CommandRunner
+}
+
+// Name returns "buildah".
+func (b *BuildahBuilder) Name() string {
+	return BuilderBuildah
+}
+
+// Available checks that buildah is installed.
+func (b *BuildahBuilder) Available() error {
+	return lookPath("buildah", BuilderBuildah)
+}
+
+// Build runs 'buildah bud' with the Dockerfile and tags of req.
+func (b *BuildahBuilder) Build(ctx context.Context, req Request) error {
+	args := []string{"bud", "--isolation", "chroot", "--file", req.Dockerfile}
+	for _, tag := range req.Tags {
+		args = append(args, "--tag", tag)
+	}
+	args = append(args, req.ContextDir)
+	return runner(b.Run)(ctx, req.Output, "buildah", args...)
+}
+
+func runner(run CommandRunner) CommandRunner {
+	if run == nil {
+		return RunCommand
+	}
+	return run
+}
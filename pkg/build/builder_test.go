@@ -0,0 +1,43 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+type fakeBuilder struct{ name string }
+
+func (b *fakeBuilder) Name() string                                 { return b.name }
+func (b *fakeBuilder) Available() error                             { return nil }
+func (b *fakeBuilder) Build(ctx context.Context, req Request) error { return nil }
+
+func TestRegisterWhileLookingUp(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("fake-%d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			Register(name, func() Builder { return &fakeBuilder{name: name} })
+		}()
+		go func() {
+			defer wg.Done()
+			Builders()
+			New(name)
+		}()
+	}
+	wg.Wait()
+
+	builder, err := New("fake-3")
+	if err != nil {
+		t.Fatalf("New(fake-3): %v", err)
+	}
+	if builder.Name() != "fake-3" {
+		t.Errorf("New(fake-3) returned %s", builder.Name())
+	}
+	if _, err := New("missing"); err == nil {
+		t.Error("New(missing) succeeded, want an error")
+	}
+}
@@ -0,0 +1,166 @@
+package build
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+	"github.com/mouad4949/DAAB/pkg/generate"
+)
+
+// alwaysIgnored are never part of the build context hash: VCS data and DAAB's own files.
+var alwaysIgnored = []string{".git", configProject.ConfigDir}
+
+// generatedPaths are written into the application folder by 'daab generate'. They
+// describe how the image is deployed, not what is in it, so they are left out of the
+// hash. They only match at the root of the folder: a source package in internal/k8s
+// is still hashed.
+var generatedPaths = []string{
+	path.Dir(generate.DeploymentPath),
+	path.Dir(generate.CloudRunServicePath),
+	path.Dir(generate.ContainerAppPath),
+	path.Dir(generate.TaskDefinitionPath),
+	generate.SAMTemplatePath,
+}
+
+// HashDir returns a hash of the files of dir that end up in the build context: their
+// paths, modes and contents. Files matched by the .dockerignore of dir, DAAB's config
+// folder and the files written by 'daab generate' are skipped.
+func HashDir(dir string) (string, error) {
+	ignore, err := readDockerignore(filepath.Join(dir, ".dockerignore"))
+	if err != nil {
+		return "", err
+	}
+	ignore = append(ignore, alwaysIgnored...)
+
+	var files []string
+	err = filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if isIgnored(rel, ignore) || isGenerated(rel) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.IsDir() {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", dir, err)
+	}
+	sort.Strings(files)
+
+	hash := sha256.New()
+	for _, rel := range files {
+		if err := hashFile(hash, dir, rel); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashFile(hash io.Writer, dir, rel string) error {
+	full := filepath.Join(dir, filepath.FromSlash(rel))
+	info, err := os.Lstat(full)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(hash, "%s\x00%o\x00", rel, info.Mode())
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(full)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00", target)
+		return nil
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(full)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(hash, f)
+	return err
+}
+
+// isGenerated reports whether rel, relative to the application folder, is one of the
+// generatedPaths.
+func isGenerated(rel string) bool {
+	for _, generated := range generatedPaths {
+		if rel == generated {
+			return true
+		}
+	}
+	return false
+}
+
+// readDockerignore returns the patterns of a .dockerignore file, nil when it does not exist.
+func readDockerignore(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, strings.Trim(path.Clean(line), "/"))
+	}
+	return patterns, scanner.Err()
+}
+
+// isIgnored matches rel against the patterns the way the usual .dockerignore files are
+// written: a pattern matches the path, any parent folder of it, or any path component
+// when the pattern has no '/'. Exclusions ("!pattern") are not supported.
+func isIgnored(rel string, patterns []string) bool {
+	parts := strings.Split(rel, "/")
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			continue
+		}
+		pattern = strings.TrimPrefix(pattern, "**/")
+		for idx := range parts {
+			if ok, _ := path.Match(pattern, strings.Join(parts[:idx+1], "/")); ok {
+				return true
+			}
+			if !strings.Contains(pattern, "/") {
+				if ok, _ := path.Match(pattern, parts[idx]); ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func hashDir(t *testing.T, dir string) string {
+	t.Helper()
+	hash, err := HashDir(dir)
+	if err != nil {
+		t.Fatalf("HashDir(): %v", err)
+	}
+	return hash
+}
+
+func TestHashDirSkipsGeneratedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "main.go", "package main\n")
+	writeFile(t, dir, "internal/k8s/client.go", "package k8s\n")
+	before := hashDir(t, dir)
+
+	for _, name := range []string{
+		"k8s/deployment.yaml",
+		"cloudrun/service.yaml",
+		"containerapp/containerapp.yaml",
+		"ecs/task-definition.yaml",
+		"template.yaml",
+		".init/daab.yaml",
+		".init/oci/index.json",
+		".git/HEAD",
+	} {
+		writeFile(t, dir, name, "generated")
+		if after := hashDir(t, dir); after != before {
+			t.Errorf("writing %s changed the hash", name)
+		}
+	}
+
+	// Only the generated folders at the root are skipped
+	writeFile(t, dir, "internal/k8s/client.go", "package k8s\n\nconst Version = 2\n")
+	if hashDir(t, dir) == before {
+		t.Error("changing internal/k8s/client.go did not change the hash")
+	}
+}
+
+func TestHashDirFollowsDockerignore(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, ".dockerignore", "node_modules\n*.log\n")
+	writeFile(t, dir, "index.js", "console.log(1)\n")
+	before := hashDir(t, dir)
+
+	writeFile(t, dir, "node_modules/express/index.js", "module.exports = {}\n")
+	writeFile(t, dir, "logs/app.log", "started\n")
+	if hashDir(t, dir) != before {
+		t.Error("ignored files changed the hash")
+	}
+
+	writeFile(t, dir, "index.js", "console.log(2)\n")
+	if hashDir(t, dir) == before {
+		t.Error("changing index.js did not change the hash")
+	}
+}
@@ -0,0 +1,307 @@
+package build
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+	"github.com/mouad4949/DAAB/pkg/generate"
+	"github.com/mouad4949/DAAB/pkg/oci"
+)
+
+// BuilderOCI assembles images without any container runtime, see OCIBuilder.
+const BuilderOCI = "oci"
+
+// LayoutDir is the folder, relative to the application, where OCIBuilder writes the
+// image layout.
+var LayoutDir = filepath.Join(configProject.ConfigDir, "oci")
+
+// staticFolders are the usual output folders of static site generators, the first one
+// holding an index.html is copied instead of the whole application folder.
+var staticFolders = []string{"dist", "build", "public", "out", "_site"}
+
+// caBundles are the usual locations of the CA certificates of the host, copied into the
+// images so applications can call HTTPS services.
+var caBundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/cert.pem",
+}
+
+// staticServer is the source of the file server of static sites. Its build tag keeps it
+// out of the builds of this module, the oci builder compiles it with staticServerTag.
+//
+//go:embed staticserver/main.go
+var staticServer []byte
+
+const staticServerTag = "staticserver"
+
+// OCIBuilder builds go and static applications into an OCI image layout on disk, in the
+// LayoutDir of the application, without docker or any daemon. The Go binary is compiled
+// on the host with 'go build', static sites are served by a small Go file server. The
+// image has a distroless-style base: no shell, a nonroot user, CA certificates.
+// Files are dated $SOURCE_DATE_EPOCH (the epoch by default) so builds are reproducible.
+type OCIBuilder struct {
+	Run CommandRunner
+	// Arch is the GOARCH of the image, the architecture of the host when empty
+	Arch string
+}
+
+// Name returns "oci".
+func (b *OCIBuilder) Name() string {
+	return BuilderOCI
+}
+
+// Available checks that the go toolchain is installed.
+func (b *OCIBuilder) Available() error {
+	return lookPath("go", BuilderOCI)
+}
+
+// Build writes the image of req.App to its LayoutDir, tagged with the tags of req.
+func (b *OCIBuilder) Build(ctx context.Context, req Request) error {
+	app := req.App
+	if app == nil {
+		return fmt.Errorf("the %s builder needs the application to build", BuilderOCI)
+	}
+
+	work, err := os.MkdirTemp("", "daab-oci-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+
+	var layer *oci.Layer
+	var entrypoint []string
+	switch app.Language {
+	case "go":
+		layer, entrypoint, err = b.goLayer(ctx, req, work)
+	case "static":
+		layer, entrypoint, err = b.staticLayer(ctx, req, work)
+	default:
+		return fmt.Errorf("the %s builder only builds go and static applications, %s is %s: use docker, buildkit or buildah", BuilderOCI, app.Name, app.Language)
+	}
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(app.StartCommand) != "" {
+		if entrypoint, err = execEntrypoint(app.StartCommand); err != nil {
+			return fmt.Errorf("%s: %w", app.Name, err)
+		}
+	}
+
+	base, err := baseLayer()
+	if err != nil {
+		return err
+	}
+
+	created := oci.SourceDateEpoch()
+	img := &oci.Image{
+		Config: oci.ImageConfig{
+			Created:      &created,
+			Architecture: b.arch(),
+			OS:           "linux",
+			Config: oci.ContainerConfig{
+				User: "65532:65532",
+				Env: []string{
+					"PATH=/usr/local/bin:/usr/bin:/bin",
+					"SSL_CERT_FILE=/etc/ssl/certs/ca-certificates.crt",
+					fmt.Sprintf("PORT=%d", app.Port),
+				},
+				ExposedPorts: map[string]struct{}{fmt.Sprintf("%d/tcp", app.Port): {}},
+				Entrypoint:   entrypoint,
+				WorkingDir:   "/",
+				Labels:       imageLabels(app),
+			},
+		},
+		Layers: []*oci.Layer{base, layer},
+	}
+
+	dir := filepath.Join(req.ContextDir, LayoutDir)
+	manifest, err := oci.WriteLayout(dir, img, req.Tags)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(req.Output, "wrote %s to %s\n", manifest.Digest, dir)
+	return nil
+}
+
+// shellSyntax are the characters that only mean something to a shell: quoting,
+// variables, globs, redirections, pipes and command lists.
+const shellSyntax = "\"'`$\\|&;<>()*?[]{}~#\n"
+
+// execEntrypoint splits command into a program and its arguments. The images have no
+// shell to run it, so commands using shell syntax or starting with variable assignments
+// are rejected instead of failing when the container starts.
+func execEntrypoint(command string) ([]string, error) {
+	fields := strings.Fields(command)
+	if strings.ContainsAny(command, shellSyntax) || (len(fields) > 0 && strings.Contains(fields[0], "=")) {
+		return nil, fmt.Errorf("the %s builder writes images without a shell, start_command %q needs one: write it as a program and its arguments, or use docker, buildkit or buildah", BuilderOCI, command)
+	}
+	return fields, nil
+}
+
+func (b *OCIBuilder) arch() string {
+	if b.Arch != "" {
+		return b.Arch
+	}
+	return runtime.GOARCH
+}
+
+// compile builds the main package of dir into a static linux binary at output.
+func (b *OCIBuilder) compile(ctx context.Context, req Request, dir, output string, flags ...string) error {
+	args := []string{
+		"CGO_ENABLED=0", "GOOS=linux", "GOARCH=" + b.arch(),
+		"go", "-C", dir, "build", "-trimpath", "-buildvcs=false", "-ldflags=-s -w -buildid=", "-o", output,
+	}
+	args = append(append(args, flags...), ".")
+	return runner(b.Run)(ctx, req.Output, "env", args...)
+}
+
+func (b *OCIBuilder) goLayer(ctx context.Context, req Request, work string) (*oci.Layer, []string, error) {
+	if req.App.BuildCommand != "" {
+		return nil, nil, fmt.Errorf("the %s builder compiles %s with 'go build', remove its build_command or use docker, buildkit or buildah", BuilderOCI, req.App.Name)
+	}
+
+	binary := filepath.Join(work, "app")
+	if err := b.compile(ctx, req, req.ContextDir, binary); err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(binary)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	layer := oci.NewLayer("go build " + req.App.Name)
+	layer.AddFile("/app", 0755, data)
+	return layer, []string{"/app"}, nil
+}
+
+func (b *OCIBuilder) staticLayer(ctx context.Context, req Request, work string) (*oci.Layer, []string, error) {
+	if command := req.App.BuildCommand; command != "" {
+		// $1 is the application folder, so the command runs there whatever its quoting
+		if err := runner(b.Run)(ctx, req.Output, "sh", "-c", `cd "$1" && `+command, "sh", req.ContextDir); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// The file server is a standalone module, so it compiles outside of the project
+	src := filepath.Join(work, "server")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(filepath.Join(src, "main.go"), staticServer, 0644); err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(filepath.Join(src, "go.mod"), []byte("module staticserver\n\ngo 1.21\n"), 0644); err != nil {
+		return nil, nil, err
+	}
+	binary := filepath.Join(work, "server-bin")
+	if err := b.compile(ctx, req, src, binary, "-tags="+staticServerTag); err != nil {
+		return nil, nil, err
+	}
+	server, err := os.ReadFile(binary)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	layer := oci.NewLayer("copy static site " + req.App.Name)
+	layer.AddFile("/server", 0755, server)
+	if err := addSite(layer, req.ContextDir, "/srv/www"); err != nil {
+		return nil, nil, err
+	}
+	return layer, []string{"/server", "-root", "/srv/www"}, nil
+}
+
+// addSite copies the static site of dir under target: its output folder when it has one,
+// the whole folder otherwise, without the files ignored by its .dockerignore.
+func addSite(layer *oci.Layer, dir, target string) error {
+	ignore, err := readDockerignore(filepath.Join(dir, ".dockerignore"))
+	if err != nil {
+		return err
+	}
+	ignore = append(ignore, alwaysIgnored...)
+
+	root := dir
+	for _, folder := range staticFolders {
+		if _, err := os.Stat(filepath.Join(dir, folder, "index.html")); err == nil {
+			root = filepath.Join(dir, folder)
+			break
+		}
+	}
+
+	return filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if isIgnored(rel, ignore) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			layer.AddDir(target+"/"+rel, 0755, 0, 0)
+			return nil
+		}
+
+		// Symlinks are copied as the file they point to
+		info, err := os.Stat(p)
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		layer.AddFile(target+"/"+rel, 0644, data)
+		return nil
+	})
+}
+
+// baseLayer is the distroless-style base of the images: users and groups, a home for the
+// nonroot user, /tmp, and the CA certificates of the host.
+func baseLayer() (*oci.Layer, error) {
+	layer := oci.NewLayer("daab distroless base")
+	layer.AddFile("/etc/passwd", 0644, []byte(
+		"root:x:0:0:root:/root:/sbin/nologin\n"+
+			"nobody:x:65534:65534:nobody:/nonexistent:/sbin/nologin\n"+
+			"nonroot:x:65532:65532:nonroot:/home/nonroot:/sbin/nologin\n"))
+	layer.AddFile("/etc/group", 0644, []byte("root:x:0:\nnobody:x:65534:\nnonroot:x:65532:\n"))
+	layer.AddFile("/etc/nsswitch.conf", 0644, []byte("hosts: files dns\n"))
+	layer.AddDir("/root", 0700, 0, 0)
+	layer.AddDir("/home/nonroot", 0700, 65532, 65532)
+	layer.AddDir("/tmp", 01777, 0, 0)
+
+	for _, bundle := range caBundles {
+		data, err := os.ReadFile(bundle)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+		}
+		layer.AddFile("/etc/ssl/certs/ca-certificates.crt", 0644, data)
+		break
+	}
+	return layer, nil
+}
+
+// imageLabels returns the labels of app with the standard OCI title.
+func imageLabels(app *generate.App) map[string]string {
+	labels := map[string]string{"org.opencontainers.image.title": app.Name}
+	for key, value := range app.Labels {
+		labels[key] = value
+	}
+	return labels
+}
@@ -0,0 +1,42 @@
+package build
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExecEntrypoint(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+		wantErr bool
+	}{
+		{command: "/app", want: []string{"/app"}},
+		{command: "/app serve --port=8080  -v", want: []string{"/app", "serve", "--port=8080", "-v"}},
+		{command: "/app --port $PORT", wantErr: true},
+		{command: "/app migrate && /app serve", wantErr: true},
+		{command: "/app | tee log", wantErr: true},
+		{command: `/app --name "my app"`, wantErr: true},
+		{command: "/app > /tmp/log", wantErr: true},
+		{command: "/server -root /srv/*", wantErr: true},
+		{command: "GODEBUG=1 /app", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			got, err := execEntrypoint(tt.command)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("execEntrypoint(%q) = %q, want an error", tt.command, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("execEntrypoint(%q): %v", tt.command, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("execEntrypoint(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
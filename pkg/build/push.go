@@ -0,0 +1,205 @@
+package build
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mouad4949/DAAB/pkg/generate"
+	"github.com/mouad4949/DAAB/pkg/oci"
+	"github.com/mouad4949/DAAB/pkg/registry"
+)
+
+// PushRequest describes the push of a built image.
+type PushRequest struct {
+	// ContextDir is the folder of the application
+	ContextDir string
+	// Source is the reference the image was built with
+	Source string
+	// Target is the reference to push, Source unless the registry is overridden
+	Target string
+	Output io.Writer
+	// Registry pushes images that are not held by a container tool
+	Registry *registry.Client
+}
+
+// Pusher is implemented by the builders whose images can be pushed after the build.
+// Push returns the digest of the pushed manifest.
+type Pusher interface {
+	Push(ctx context.Context, req PushRequest) (string, error)
+}
+
+// PushOptions configure PushAll.
+type PushOptions struct {
+	// Concurrency is the maximum number of images pushed at the same time
+	Concurrency int
+	// Registry replaces the registry of the images when set
+	Registry string
+	// Client pushes the images of the oci builder, registry.NewClient() when nil
+	Client *registry.Client
+	// State holds the builds to push, pushes are recorded in it
+	State  *State
+	Output io.Writer
+}
+
+// PushResult is the outcome of pushing the image of one application.
+type PushResult struct {
+	App      string
+	Image    string
+	Digest   string
+	Duration time.Duration
+	Err      error
+}
+
+// PushAll pushes the last build of every app with the builder that built it, at most
+// opts.Concurrency at a time; results are returned in the order of apps.
+func PushAll(ctx context.Context, apps []*generate.App, opts PushOptions) []PushResult {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	output := opts.Output
+	if output == nil {
+		output = io.Discard
+	}
+	if opts.Client == nil {
+		opts.Client = registry.NewClient()
+	}
+	logs := &lockedWriter{w: output}
+
+	results := make([]PushResult, len(apps))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(concurrency, len(apps)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				results[idx] = pushApp(ctx, apps[idx], opts, logs)
+			}
+		}()
+	}
+
+	for idx := range apps {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func pushApp(ctx context.Context, app *generate.App, opts PushOptions, logs *lockedWriter) PushResult {
+	start := time.Now()
+	result := PushResult{App: app.Name}
+	fail := func(err error) PushResult {
+		result.Err = err
+		result.Duration = time.Since(start)
+		return result
+	}
+
+	record, ok := opts.State.Get(app.Name)
+	if !ok {
+		return fail(fmt.Errorf("no build of %s, run 'daab build' first", app.Name))
+	}
+	if record.Image != app.Image() {
+		return fail(fmt.Errorf("%s was built as %s but is now configured as %s, run 'daab build' again", app.Name, record.Image, app.Image()))
+	}
+
+	factory, ok := lookupBuilder(record.Builder)
+	if !ok {
+		return fail(fmt.Errorf("%s was built with the unknown builder %q", app.Name, record.Builder))
+	}
+	pusher, ok := factory().(Pusher)
+	if !ok {
+		return fail(fmt.Errorf("images built with %s cannot be pushed afterwards, build %s with docker, buildah or oci", record.Builder, app.Name))
+	}
+
+	result.Image = record.Reference()
+	if opts.Registry != "" {
+		result.Image = strings.TrimSuffix(opts.Registry, "/") + "/" + app.Name + ":" + record.Tag
+	}
+
+	out := &prefixWriter{prefix: "[" + app.Name + "] ", w: logs}
+	digest, err := pusher.Push(ctx, PushRequest{
+		ContextDir: app.Path,
+		Source:     record.Reference(),
+		Target:     result.Image,
+		Output:     out,
+		Registry:   opts.Client,
+	})
+	out.Flush()
+	if err != nil {
+		return fail(err)
+	}
+
+	now := time.Now().UTC()
+	record.Digest = digest
+	record.PushedTo = result.Image
+	record.PushedAt = &now
+	opts.State.Set(app.Name, record)
+
+	result.Digest = digest
+	result.Duration = time.Since(start)
+	return result
+}
+
+/******************************************************/
+/************Pushers**********************************/
+/****************************************************/
+
+// Push pushes the image with docker, which uses its own credentials ('docker login').
+func (b *DockerBuilder) Push(ctx context.Context, req PushRequest) (string, error) {
+	run := runner(b.Run)
+	if req.Target != req.Source {
+		if err := run(ctx, req.Output, "docker", "tag", req.Source, req.Target); err != nil {
+			return "", err
+		}
+	}
+	if err := run(ctx, req.Output, "docker", "push", req.Target); err != nil {
+		return "", err
+	}
+
+	var digests strings.Builder
+	if err := run(ctx, &digests, "docker", "image", "inspect", "--format", "{{range .RepoDigests}}{{println .}}{{end}}", req.Target); err != nil {
+		return "", err
+	}
+	repository, _ := oci.SplitReference(req.Target)
+	for _, line := range strings.Fields(digests.String()) {
+		if name, digest, ok := strings.Cut(line, "@"); ok && name == repository {
+			return digest, nil
+		}
+	}
+	return "", fmt.Errorf("docker did not report the digest of %s", req.Target)
+}
+
+// Push pushes the image with buildah, which uses the credentials of 'buildah login'
+// or the docker config.json.
+func (b *BuildahBuilder) Push(ctx context.Context, req PushRequest) (string, error) {
+	file, err := os.CreateTemp("", "daab-digest-")
+	if err != nil {
+		return "", err
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	if err := runner(b.Run)(ctx, req.Output, "buildah", "push", "--digestfile", file.Name(), req.Source, "docker://"+req.Target); err != nil {
+		return "", err
+	}
+	digest, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(digest)), nil
+}
+
+// Push uploads the image layout of the application with the registry client.
+func (b *OCIBuilder) Push(ctx context.Context, req PushRequest) (string, error) {
+	return req.Registry.PushLayout(ctx, filepath.Join(req.ContextDir, LayoutDir), req.Target, req.Output)
+}
@@ -0,0 +1,107 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+	"github.com/mouad4949/DAAB/pkg/oci"
+	"gopkg.in/yaml.v3"
+)
+
+// StateFile records the last build of every service, in the .init folder of the project.
+const StateFile = "builds.yaml"
+
+// StatePath returns the path of the build state of the project.
+func StatePath(projectPath string) string {
+	return filepath.Join(projectPath, configProject.ConfigDir, StateFile)
+}
+
+// Record is the last successful build of an application.
+type Record struct {
+	// Hash of the build context, see HashDir
+	Hash    string    `yaml:"hash"`
+	Image   string    `yaml:"image"`
+	Tag     string    `yaml:"tag"`
+	Builder string    `yaml:"builder"`
+	BuiltAt time.Time `yaml:"built_at"`
+
+	// Digest of the pushed manifest, set by 'daab push'
+	Digest string `yaml:"digest,omitempty"`
+	// PushedTo is the reference the image was pushed as
+	PushedTo string     `yaml:"pushed_to,omitempty"`
+	PushedAt *time.Time `yaml:"pushed_at,omitempty"`
+}
+
+// Reference returns the full image reference of the build.
+func (r *Record) Reference() string {
+	return r.Image + ":" + r.Tag
+}
+
+// PushedReference returns the pushed image pinned by its digest, e.g.
+// "ghcr.io/acme/api@sha256:...", empty when the build was not pushed.
+func (r *Record) PushedReference() string {
+	if r.Digest == "" || r.PushedTo == "" {
+		return ""
+	}
+	repository, _ := oci.SplitReference(r.PushedTo)
+	return repository + "@" + r.Digest
+}
+
+// State records the last build of every application, so unchanged applications are not
+// built again. It is safe for concurrent use.
+type State struct {
+	mu      sync.Mutex
+	path    string
+	Records map[string]Record `yaml:"builds"`
+}
+
+// LoadState reads the state file at path, an empty state when it does not exist.
+func LoadState(path string) (*State, error) {
+	state := &State{path: path, Records: map[string]Record{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read build state: %w", err)
+	}
+	if err := yaml.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if state.Records == nil {
+		state.Records = map[string]Record{}
+	}
+	return state, nil
+}
+
+// Get returns the last build of app.
+func (s *State) Get(app string) (Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.Records[app]
+	return record, ok
+}
+
+// Set records a successful build of app.
+func (s *State) Set(app string, record Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Records[app] = record
+}
+
+// Path returns the file the state is loaded from and saved to.
+func (s *State) Path() string {
+	return s.path
+}
+
+// Marshal returns the content of the state file.
+func (s *State) Marshal() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return yaml.Marshal(s)
+}
@@ -0,0 +1,39 @@
+//go:build staticserver
+
+// Command staticserver serves the files of a static site. The oci builder embeds this file
+// and compiles it into the images of static applications, which have no web server of
+// their own. The staticserver tag keeps it out of the builds of this module.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+func main() {
+	root := flag.String("root", "/srv/www", "Folder of the site")
+	flag.Parse()
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	files := http.FileServer(http.Dir(*root))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Unknown paths get index.html, so client-side routes of single page apps work
+		name := filepath.Join(*root, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			http.ServeFile(w, r, filepath.Join(*root, "index.html"))
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+
+	log.Printf("serving %s on :%s", *root, port)
+	log.Fatal(http.ListenAndServe(":"+port, handler))
+}
@@ -0,0 +1,65 @@
+package config
+
+import "fmt"
+
+// BaseConfigApp holds the settings of a deployable application, shared by monoliths and
+// microservices.
+type BaseConfigApp struct {
+	BaseConfig `yaml:",inline"`
+	// Detection results
+	Language      string   `yaml:"language"`       // nodejs, go, python, etc.
+	Framework     string   `yaml:"framework"`      // express, gin, flask, etc.
+	DetectedFiles []string `yaml:"detected_files"` // Files used for detection
+	Port          int      `yaml:"port"`
+
+	// Container configuration
+	ContainerRegistry string `yaml:"container_registry,omitempty"` // ECR, GCR, ACR, or custom
+
+	// Build configuration
+	BuildCommand   string `yaml:"build_command,omitempty"`
+	StartCommand   string `yaml:"start_command,omitempty"`
+	HealthEndpoint string `yaml:"health_endpoint,omitempty"`
+
+	// Deployment configuration
+	Strategy *Strategy `yaml:"strategy,omitempty"`
+	// Resources of the container, the defaults of the language when unset
+	Resources *Resources `yaml:"resources,omitempty"`
+	// Replicas is the number of pods, 1 when unset; ignored with autoscaling
+	Replicas    int          `yaml:"replicas,omitempty"`
+	Autoscaling *Autoscaling `yaml:"autoscaling,omitempty"`
+}
+
+// NewBaseConfigApp is a constructor for BaseConfig, setting default values.
+func NewBaseConfigApp() *BaseConfigApp {
+	base := NewBaseConfig()
+	return &BaseConfigApp{
+		BaseConfig: base,
+	}
+}
+
+// Validate checks the deployment configuration of the application.
+func (b *BaseConfigApp) Validate() error {
+	if err := ValidateTarget(b.CloudProvider, b.Target); err != nil {
+		return err
+	}
+	if !IsKubernetes(b.Target) {
+		// The serverless platforms replace the running version at once
+		if kind := b.Strategy.Kind(); kind != StrategyRolling {
+			return fmt.Errorf("strategy %s needs the kubernetes target, %s only supports rolling", kind, b.Target)
+		}
+		if b.Autoscaling != nil && len(b.Autoscaling.Metrics) > 0 {
+			return fmt.Errorf("autoscaling: custom metrics need the kubernetes target, %s scales on its own", b.Target)
+		}
+	}
+	if b.Replicas < 0 {
+		return fmt.Errorf("replicas must not be negative, got %d", b.Replicas)
+	}
+	// The defaults of the language fill in the values that are not set
+	if err := MergeResources(DefaultResources(b.Language), b.Resources).Validate(); err != nil {
+		return err
+	}
+	if err := b.Autoscaling.Validate(); err != nil {
+		return err
+	}
+	return b.Strategy.Validate()
+}
@@ -1,3 +1,5 @@
+// Package config holds the configuration models shared by monolith and microservice
+// projects, and the loading of environment overlays.
 package config
 
 import "time"
@@ -14,8 +16,14 @@ type BaseConfig struct {
 	ProjectType string `yaml:"project_type"` // monolith, microservice
 
 	// Cloud configuration
-	CloudProvider string `yaml:"cloud_provider"` // aws, gcp, azure
+	CloudProvider string `yaml:"cloud_provider"`       // aws, gcp, azure
+	AccountID     string `yaml:"account_id,omitempty"` // AWS account id, GCP project id or Azure registry name
+	// Target is where the applications run: kubernetes (default), cloud-run, lambda,
+	// container-apps or ecs-fargate
+	Target string `yaml:"target,omitempty"`
 
+	// Environment overlays, keyed by environment name (staging, production, ...)
+	Environments map[string]map[string]interface{} `yaml:"environments,omitempty"`
 }
 
 // NewBaseConfig is a constructor for BaseConfig, setting default values.
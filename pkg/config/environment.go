@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var environmentNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// ValidateEnvironmentName checks that an environment name can be used as an overlay file suffix.
+func ValidateEnvironmentName(env string) error {
+	if !environmentNamePattern.MatchString(env) {
+		return fmt.Errorf("invalid environment name %q: use lowercase letters, digits and '-'", env)
+	}
+	return nil
+}
+
+// OverlayPath returns the path of the overlay file for env next to the base config file,
+// e.g. .init/daab.yaml -> .init/daab.staging.yaml.
+func OverlayPath(basePath, env string) string {
+	ext := filepath.Ext(basePath)
+	return strings.TrimSuffix(basePath, ext) + "." + env + ext
+}
+
+// LoadFile reads a config file into out, applying the overlay for env when env is not empty.
+// Overlays are applied in order: the `environments.<env>` section of the base file, then the
+// .init/daab.<env>.yaml file. A selected environment must be defined by at least one of them.
+func LoadFile(basePath, env string, out interface{}) error {
+	base, err := readYAMLMap(basePath)
+	if err != nil {
+		return err
+	}
+
+	if env != "" {
+		if err := ValidateEnvironmentName(env); err != nil {
+			return err
+		}
+
+		found := false
+		if envs, ok := base["environments"].(map[string]interface{}); ok {
+			if section, ok := envs[env].(map[string]interface{}); ok {
+				base = MergeMaps(base, section)
+				found = true
+			}
+		}
+
+		overlayPath := OverlayPath(basePath, env)
+		if _, err := os.Stat(overlayPath); err == nil {
+			overlay, err := readYAMLMap(overlayPath)
+			if err != nil {
+				return err
+			}
+			base = MergeMaps(base, overlay)
+			found = true
+		}
+
+		if !found {
+			return fmt.Errorf("environment %q is not defined in %s", env, basePath)
+		}
+
+		// The resolved config describes a single environment
+		delete(base, "environments")
+		base["environment"] = env
+	}
+
+	data, err := yaml.Marshal(base)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", basePath, err)
+	}
+	return nil
+}
+
+// ListEnvironments returns every environment defined for the config file at basePath,
+// either in its `environments` section or as an overlay file.
+func ListEnvironments(basePath string) ([]string, error) {
+	base, err := readYAMLMap(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	if envs, ok := base["environments"].(map[string]interface{}); ok {
+		for name := range envs {
+			seen[name] = true
+		}
+	}
+
+	ext := filepath.Ext(basePath)
+	prefix := strings.TrimSuffix(filepath.Base(basePath), ext) + "."
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(basePath), prefix+"*"+ext))
+	for _, match := range matches {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), prefix), ext)
+		// daab.root.yaml shares the daab. prefix but is not an overlay
+		if ValidateEnvironmentName(name) == nil && name != "root" {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// MergeMaps deep-merges src into dst. Nested maps are merged key by key,
+// any other value in src replaces the one in dst.
+func MergeMaps(dst, src map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(dst))
+	for k, v := range dst {
+		out[k] = v
+	}
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]interface{})
+		dstMap, dstIsMap := out[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			out[k] = MergeMaps(dstMap, srcMap)
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func readYAMLMap(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return m, nil
+}
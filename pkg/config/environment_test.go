@@ -0,0 +1,222 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeMaps(t *testing.T) {
+	tests := []struct {
+		name string
+		dst  map[string]interface{}
+		src  map[string]interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "scalars are replaced",
+			dst:  map[string]interface{}{"port": 8080, "language": "go"},
+			src:  map[string]interface{}{"port": 9090},
+			want: map[string]interface{}{"port": 9090, "language": "go"},
+		},
+		{
+			name: "nested maps are merged key by key",
+			dst: map[string]interface{}{"resources": map[string]interface{}{
+				"requests": map[string]interface{}{"cpu": "100m", "memory": "128Mi"},
+				"limits":   map[string]interface{}{"cpu": "500m"},
+			}},
+			src: map[string]interface{}{"resources": map[string]interface{}{
+				"requests": map[string]interface{}{"memory": "256Mi"},
+			}},
+			want: map[string]interface{}{"resources": map[string]interface{}{
+				"requests": map[string]interface{}{"cpu": "100m", "memory": "256Mi"},
+				"limits":   map[string]interface{}{"cpu": "500m"},
+			}},
+		},
+		{
+			name: "lists are replaced, not appended",
+			dst:  map[string]interface{}{"detected_files": []interface{}{"go.mod", "main.go"}},
+			src:  map[string]interface{}{"detected_files": []interface{}{"package.json"}},
+			want: map[string]interface{}{"detected_files": []interface{}{"package.json"}},
+		},
+		{
+			name: "a map replaces a scalar and the other way round",
+			dst:  map[string]interface{}{"strategy": "rolling", "autoscaling": map[string]interface{}{"max_replicas": 5}},
+			src:  map[string]interface{}{"strategy": map[string]interface{}{"type": "canary"}, "autoscaling": nil},
+			want: map[string]interface{}{"strategy": map[string]interface{}{"type": "canary"}, "autoscaling": nil},
+		},
+		{
+			name: "new keys are added",
+			dst:  map[string]interface{}{"port": 8080},
+			src:  map[string]interface{}{"namespace": "shop"},
+			want: map[string]interface{}{"port": 8080, "namespace": "shop"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeMaps(tt.dst, tt.src); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeMaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeMapsLeavesItsArgumentsUnchanged(t *testing.T) {
+	dst := map[string]interface{}{"resources": map[string]interface{}{"cpu": "100m"}}
+	src := map[string]interface{}{"resources": map[string]interface{}{"cpu": "200m"}}
+	MergeMaps(dst, src)
+	if cpu := dst["resources"].(map[string]interface{})["cpu"]; cpu != "100m" {
+		t.Errorf("MergeMaps() changed dst to %v", cpu)
+	}
+}
+
+const baseConfig = `project_name: shop
+port: 8080
+start_command: ./server
+container_registry: ghcr.io/acme
+detected_files: [go.mod, main.go]
+resources:
+  requests:
+    cpu: 100m
+    memory: 128Mi
+environments:
+  staging:
+    port: 8081
+    resources:
+      requests:
+        memory: 256Mi
+  production:
+    port: 8082
+    container_registry: registry.acme.com
+    resources:
+      limits:
+        cpu: "1"
+`
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		overlay string
+		want    BaseConfigApp
+		wantErr string
+	}{
+		{
+			name: "no environment",
+			want: BaseConfigApp{
+				Port: 8080, StartCommand: "./server", ContainerRegistry: "ghcr.io/acme",
+				DetectedFiles: []string{"go.mod", "main.go"},
+				Resources:     &Resources{Requests: ResourceList{CPU: "100m", Memory: "128Mi"}},
+			},
+		},
+		{
+			name: "environments section",
+			env:  "staging",
+			want: BaseConfigApp{
+				Port: 8081, StartCommand: "./server", ContainerRegistry: "ghcr.io/acme",
+				DetectedFiles: []string{"go.mod", "main.go"},
+				Resources:     &Resources{Requests: ResourceList{CPU: "100m", Memory: "256Mi"}},
+			},
+		},
+		{
+			name: "the overlay file wins over the environments section",
+			env:  "production",
+			overlay: `port: 9090
+detected_files: [Dockerfile]
+resources:
+  limits:
+    memory: 1Gi
+`,
+			want: BaseConfigApp{
+				Port: 9090, StartCommand: "./server", ContainerRegistry: "registry.acme.com",
+				DetectedFiles: []string{"Dockerfile"},
+				Resources: &Resources{
+					Requests: ResourceList{CPU: "100m", Memory: "128Mi"},
+					Limits:   ResourceList{CPU: "1", Memory: "1Gi"},
+				},
+			},
+		},
+		{
+			name:    "overlay file only",
+			env:     "preview",
+			overlay: "start_command: ./server --debug\n",
+			want: BaseConfigApp{
+				Port: 8080, StartCommand: "./server --debug", ContainerRegistry: "ghcr.io/acme",
+				DetectedFiles: []string{"go.mod", "main.go"},
+				Resources:     &Resources{Requests: ResourceList{CPU: "100m", Memory: "128Mi"}},
+			},
+		},
+		{
+			name:    "undefined environment",
+			env:     "qa",
+			wantErr: `environment "qa" is not defined`,
+		},
+		{
+			name:    "invalid environment name",
+			env:     "Prod_1",
+			wantErr: "invalid environment name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			basePath := writeConfig(t, dir, "daab.yaml", baseConfig)
+			if tt.overlay != "" {
+				writeConfig(t, dir, "daab."+tt.env+".yaml", tt.overlay)
+			}
+
+			var got BaseConfigApp
+			err := LoadFile(basePath, tt.env, &got)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadFile(%q) error = %v, want %q", tt.env, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadFile(%q): %v", tt.env, err)
+			}
+
+			// The resolved config of an environment has no overlays left
+			if tt.env != "" && got.Environments != nil {
+				t.Errorf("LoadFile(%q) kept the environments: %v", tt.env, got.Environments)
+			}
+			got.BaseConfig = BaseConfig{}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadFile(%q) = %+v, want %+v", tt.env, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListEnvironments(t *testing.T) {
+	dir := t.TempDir()
+	basePath := writeConfig(t, dir, "daab.yaml", baseConfig)
+	writeConfig(t, dir, "daab.preview.yaml", "port: 9000\n")
+	writeConfig(t, dir, "daab.staging.yaml", "port: 9001\n")
+	writeConfig(t, dir, "daab.root.yaml", "project_name: shop\n")
+	writeConfig(t, dir, "daab.Not_An_Env.yaml", "port: 9002\n")
+
+	got, err := ListEnvironments(basePath)
+	if err != nil {
+		t.Fatalf("ListEnvironments(): %v", err)
+	}
+	want := []string{"preview", "production", "staging"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListEnvironments() = %v, want %v", got, want)
+	}
+}
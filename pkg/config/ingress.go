@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Types of entry points.
+const (
+	// IngressTypeIngress routes the traffic with a networking.k8s.io/v1 Ingress
+	IngressTypeIngress = "ingress"
+	// IngressTypeGateway routes the traffic with Gateway API HTTPRoutes
+	IngressTypeGateway = "gateway"
+)
+
+var hostPattern = regexp.MustCompile(`^(\*\.)?[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+// Ingress is how the traffic reaches the project from outside the cluster: a single entry
+// point for the monolith, or for every microservice with a path prefix.
+type Ingress struct {
+	// Type is ingress (default) or gateway
+	Type string `yaml:"type,omitempty"`
+	// Class is the IngressClass, or the GatewayClass of the Gateway created by daab
+	Class string `yaml:"class,omitempty"`
+	// Gateway is an existing Gateway the routes attach to; without it daab creates one
+	Gateway *GatewayRef `yaml:"gateway,omitempty"`
+	// Hosts are the domain names served, every host when empty
+	Hosts []string `yaml:"hosts,omitempty"`
+	TLS   *TLS     `yaml:"tls,omitempty"`
+}
+
+// GatewayRef identifies an existing Gateway API Gateway.
+type GatewayRef struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+	// SectionName is the listener of the Gateway, every listener when empty
+	SectionName string `yaml:"section_name,omitempty"`
+}
+
+// TLS serves the hosts over HTTPS, with a certificate issued by cert-manager or stored
+// in an existing secret.
+type TLS struct {
+	// Issuer is the cert-manager ClusterIssuer issuing the certificate, e.g. "letsencrypt"
+	Issuer string `yaml:"issuer,omitempty"`
+	// Secret holds the certificate, <project>-tls when Issuer is set and Secret is empty
+	Secret string `yaml:"secret,omitempty"`
+}
+
+// Kind returns the type of the entry point, ingress when i has no type.
+func (i *Ingress) Kind() string {
+	if i.Type == "" {
+		return IngressTypeIngress
+	}
+	return i.Type
+}
+
+// SecretName returns the secret of the certificate of the entry point named name.
+func (t *TLS) SecretName(name string) string {
+	if t.Secret != "" {
+		return t.Secret
+	}
+	return name + "-tls"
+}
+
+// Validate checks the entry point. A nil entry point is valid.
+func (i *Ingress) Validate() error {
+	if i == nil {
+		return nil
+	}
+	for _, host := range i.Hosts {
+		if !hostPattern.MatchString(host) {
+			return fmt.Errorf("ingress: invalid host %q, use a lowercase domain name such as \"api.example.com\"", host)
+		}
+	}
+	if i.TLS != nil {
+		if i.TLS.Issuer == "" && i.TLS.Secret == "" {
+			return fmt.Errorf("ingress: tls needs a cert-manager issuer or a secret")
+		}
+		if len(i.Hosts) == 0 {
+			return fmt.Errorf("ingress: tls needs at least one host")
+		}
+	}
+
+	switch i.Kind() {
+	case IngressTypeIngress:
+		if i.Gateway != nil {
+			return fmt.Errorf("ingress: gateway is only used with type %s", IngressTypeGateway)
+		}
+	case IngressTypeGateway:
+		if i.Gateway == nil && i.Class == "" {
+			return fmt.Errorf("ingress: set the GatewayClass in class, or an existing gateway")
+		}
+		if i.Gateway != nil {
+			if i.Gateway.Name == "" {
+				return fmt.Errorf("ingress: gateway needs a name")
+			}
+			// The listeners, and so the certificates, belong to the existing Gateway
+			if i.TLS != nil {
+				return fmt.Errorf("ingress: tls is configured on the listeners of gateway %s, remove it here", i.Gateway.Name)
+			}
+		}
+	default:
+		return fmt.Errorf("ingress: unknown type %q, use %s or %s", i.Type, IngressTypeIngress, IngressTypeGateway)
+	}
+	return nil
+}
+
+// ValidatePathPrefix checks the path prefix routing the traffic to a service.
+func ValidatePathPrefix(prefix string) error {
+	if !strings.HasPrefix(prefix, "/") || strings.ContainsAny(prefix, " ?#*") {
+		return fmt.Errorf("invalid path prefix %q, use a path such as \"/users\"", prefix)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+// Package configMicroservice holds the daab.root.yaml and per-service daab.yaml models of
+// microservice projects and resolves the settings services inherit from the root.
+package configMicroservice
+
+import (
+	config "github.com/mouad4949/DAAB/pkg/config"
+)
+
+// ConfigMicroservice is the daab.yaml of one service of a microservice project.
+type ConfigMicroservice struct {
+	config.BaseConfigApp `yaml:",inline"` // Embed BaseConfig
+
+	// Overrides of the settings inherited from daab.root.yaml, empty means inherited
+	Region      string            `yaml:"region,omitempty"`
+	Environment string            `yaml:"environment,omitempty"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+
+	// PathPrefix routes the requests starting with it from the ingress of daab.root.yaml to
+	// the service, e.g. "/users"; services without one are not exposed
+	PathPrefix string `yaml:"path_prefix,omitempty"`
+}
+
+// NewConfigMicroservice returns a service config with the default application settings.
+func NewConfigMicroservice() *ConfigMicroservice {
+	baseAppConfig := config.NewBaseConfigApp()
+	return &ConfigMicroservice{
+		BaseConfigApp: *baseAppConfig,
+	}
+}
@@ -0,0 +1,43 @@
+package configMicroservice
+
+import (
+	config "github.com/mouad4949/DAAB/pkg/config"
+)
+
+// ConfigMicroRoot is the daab.root.yaml of a microservice project, the settings shared by
+// every service.
+type ConfigMicroRoot struct {
+	config.BaseConfig `yaml:",inline"` // Embed BaseConfig
+	// Metadata
+
+	// Services of the project, see ServiceRefs
+	Services []ServiceRef `yaml:"services,omitempty"`
+	// Deprecated: paths of the services as written by older versions, replaced by Services
+	DetectedMicroservices []string `yaml:"detected_files,omitempty"`
+	Environment           string   `yaml:"environment"` // production, staging, development
+
+	// Cloud configuration
+	Region string `yaml:"region"`
+
+	Namespace string `yaml:"namespace"`
+
+	// Settings inherited by every microservice unless the service overrides them
+	ContainerRegistry string              `yaml:"container_registry,omitempty"`
+	Resources         *config.Resources   `yaml:"resources,omitempty"`
+	Labels            map[string]string   `yaml:"labels,omitempty"`
+	Strategy          *config.Strategy    `yaml:"strategy,omitempty"`
+	Replicas          int                 `yaml:"replicas,omitempty"`
+	Autoscaling       *config.Autoscaling `yaml:"autoscaling,omitempty"`
+
+	// Ingress is the single entry point of the project, every service with a path prefix
+	// is routed from it
+	Ingress *config.Ingress `yaml:"ingress,omitempty"`
+}
+
+// NewConfigMicroRoot returns a root config with the default base settings.
+func NewConfigMicroRoot() *ConfigMicroRoot {
+	base := config.NewBaseConfig()
+	return &ConfigMicroRoot{
+		BaseConfig: base,
+	}
+}
@@ -0,0 +1,41 @@
+package configMicroservice
+
+import (
+	config "github.com/mouad4949/DAAB/pkg/config"
+)
+
+// Resolve returns the effective config of a service: every setting the service does not
+// override is inherited from the root config. Neither argument is modified.
+func Resolve(root *ConfigMicroRoot, svc *ConfigMicroservice) *ConfigMicroservice {
+	effective := *svc
+
+	effective.CloudProvider = firstNonEmpty(svc.CloudProvider, root.CloudProvider)
+	effective.AccountID = firstNonEmpty(svc.AccountID, root.AccountID)
+	effective.Target = firstNonEmpty(svc.Target, root.Target)
+	effective.ContainerRegistry = firstNonEmpty(svc.ContainerRegistry, root.ContainerRegistry)
+	effective.Region = firstNonEmpty(svc.Region, root.Region)
+	effective.Environment = firstNonEmpty(svc.Environment, root.Environment)
+	effective.Namespace = firstNonEmpty(svc.Namespace, root.Namespace)
+	effective.Resources = config.MergeResources(root.Resources, svc.Resources)
+	effective.Labels = config.MergeLabels(root.Labels, svc.Labels)
+	if svc.Strategy == nil {
+		effective.Strategy = root.Strategy
+	}
+	if svc.Replicas == 0 {
+		effective.Replicas = root.Replicas
+	}
+	if svc.Autoscaling == nil {
+		effective.Autoscaling = root.Autoscaling
+	}
+
+	return &effective
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,161 @@
+package configMicroservice
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	config "github.com/mouad4949/DAAB/pkg/config"
+	"github.com/mouad4949/DAAB/pkg/graph"
+)
+
+// ServiceRef identifies a service of a microservice project independently of where the
+// repository is checked out or which --project-path is used.
+type ServiceRef struct {
+	// Name is the DNS-safe name of the service, used for images and Kubernetes resources
+	Name string `yaml:"name"`
+	// Path is the service folder relative to the project root, with forward slashes
+	Path string `yaml:"path"`
+	// Aliases are other names the service can be referred to by on the command line
+	Aliases []string `yaml:"aliases,omitempty"`
+	// DependsOn are the services this service calls, deployed before it
+	DependsOn []string `yaml:"depends_on,omitempty"`
+}
+
+// NewServiceRef returns the reference of the service in folder servicePath of the project
+// at projectPath, named after the folder.
+func NewServiceRef(projectPath, servicePath string) (ServiceRef, error) {
+	projectAbs, err := filepath.Abs(projectPath)
+	if err != nil {
+		return ServiceRef{}, err
+	}
+	serviceAbs, err := filepath.Abs(servicePath)
+	if err != nil {
+		return ServiceRef{}, err
+	}
+	rel, err := filepath.Rel(projectAbs, serviceAbs)
+	if err != nil {
+		return ServiceRef{}, err
+	}
+
+	ref := ServiceRef{
+		Name: config.DNSName(filepath.Base(serviceAbs)),
+		Path: filepath.ToSlash(rel),
+	}
+	if err := validatePath(ref.Path); err != nil {
+		return ServiceRef{}, fmt.Errorf("%s: %w", servicePath, err)
+	}
+	return ref, nil
+}
+
+// Matches reports whether name is the name or one of the aliases of the service.
+func (s *ServiceRef) Matches(name string) bool {
+	if s.Name == name {
+		return true
+	}
+	for _, alias := range s.Aliases {
+		if alias == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ServiceRefs returns the services of the project. Configs written by older versions only
+// list service paths in detected_files; their services are named after their folder.
+func (r *ConfigMicroRoot) ServiceRefs() []ServiceRef {
+	if len(r.Services) > 0 || len(r.DetectedMicroservices) == 0 {
+		return r.Services
+	}
+
+	refs := make([]ServiceRef, 0, len(r.DetectedMicroservices))
+	for _, detected := range r.DetectedMicroservices {
+		// Older versions stored the service folder joined with --project-path, and
+		// services were always direct children of the project folder
+		folder := filepath.Base(filepath.Clean(detected))
+		refs = append(refs, ServiceRef{Name: config.DNSName(folder), Path: folder})
+	}
+	return refs
+}
+
+// ValidateServiceRefs checks that every service has a valid name and path, and that
+// names, aliases and paths are unique.
+func ValidateServiceRefs(refs []ServiceRef) error {
+	// name or alias -> path of the service using it, path -> name
+	names := map[string]string{}
+	paths := map[string]string{}
+
+	for _, ref := range refs {
+		if err := config.ValidateDNSName(ref.Name); err != nil {
+			return fmt.Errorf("invalid service name: %w", err)
+		}
+		if err := validatePath(ref.Path); err != nil {
+			return fmt.Errorf("service %s: %w", ref.Name, err)
+		}
+
+		for _, name := range append([]string{ref.Name}, ref.Aliases...) {
+			if err := config.ValidateDNSName(name); err != nil {
+				return fmt.Errorf("service %s: invalid alias: %w", ref.Name, err)
+			}
+			if other, ok := names[name]; ok {
+				return fmt.Errorf("services in %s and %s are both named %q", other, ref.Path, name)
+			}
+			names[name] = ref.Path
+		}
+
+		clean := path.Clean(ref.Path)
+		if other, ok := paths[clean]; ok {
+			return fmt.Errorf("services %s and %s have the same path %q", other, ref.Name, ref.Path)
+		}
+		paths[clean] = ref.Name
+	}
+
+	dependencies, err := DependencyGraph(refs)
+	if err != nil {
+		return err
+	}
+	_, err = dependencies.Order()
+	return err
+}
+
+// DependencyGraph returns the graph of the depends_on of the services, with aliases
+// resolved to service names.
+func DependencyGraph(refs []ServiceRef) (*graph.Graph, error) {
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		names = append(names, ref.Name)
+	}
+	dependencies := graph.New(names...)
+
+	for _, ref := range refs {
+		for _, dependency := range ref.DependsOn {
+			target := dependency
+			for _, other := range refs {
+				if other.Matches(dependency) {
+					target = other.Name
+					break
+				}
+			}
+			if err := dependencies.AddEdge(graph.Edge{From: ref.Name, To: target}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return dependencies, nil
+}
+
+// validatePath accepts folders inside the project, written relative to its root.
+func validatePath(p string) error {
+	if p == "" {
+		return fmt.Errorf("path cannot be empty")
+	}
+	if path.IsAbs(p) || filepath.IsAbs(p) || strings.Contains(p, `\`) {
+		return fmt.Errorf("path %q must be relative to the project root and use '/'", p)
+	}
+	clean := path.Clean(p)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("path %q must be a folder inside the project", p)
+	}
+	return nil
+}
@@ -1,10 +1,11 @@
+// Package configMonolith holds the daab.yaml model of monolith projects.
 package configMonolith
 
 import (
-	config "github.com/mouad4949/DAAB/internal/init/config"
+	config "github.com/mouad4949/DAAB/pkg/config"
 )
 
-// Config represents the DAAB configuration structure
+// ConfigMonolith is the daab.yaml of a monolith project.
 type ConfigMonolith struct {
 	config.BaseConfigApp `yaml:",inline"` // Embed BaseConfig
 
@@ -13,12 +14,12 @@ type ConfigMonolith struct {
 	// Application configuration
 	Environment string `yaml:"environment"` // production, staging, development
 	Namespace   string `yaml:"namespace"`
-}
 
-func init() {
-	// Set default version
+	// Ingress exposes the application outside the cluster, at the root path
+	Ingress *config.Ingress `yaml:"ingress,omitempty"`
 }
 
+// NewConfigMonolith returns a monolith config with the default application settings.
 func NewConfigMonolith() *ConfigMonolith {
 	// Call the constructor for BaseConfigApp to get an instance of it.
 	baseAppConfig := config.NewBaseConfigApp() // Corrected: Get a *config.BaseConfigApp
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	dnsLabelPattern   = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+	dnsInvalidPattern = regexp.MustCompile(`[^a-z0-9-]+`)
+	dashesPattern     = regexp.MustCompile(`-+`)
+)
+
+// DNSName converts name (e.g. a folder name) to a DNS label usable as a Kubernetes
+// resource name. It returns an empty string when name has no usable character.
+func DNSName(name string) string {
+	name = dnsInvalidPattern.ReplaceAllString(strings.ToLower(name), "-")
+	name = strings.Trim(dashesPattern.ReplaceAllString(name, "-"), "-")
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-")
+	}
+	return name
+}
+
+// ValidateDNSName checks that name is an RFC 1123 label.
+func ValidateDNSName(name string) error {
+	if len(name) > 63 {
+		return fmt.Errorf("%q is longer than 63 characters", name)
+	}
+	if !dnsLabelPattern.MatchString(name) {
+		return fmt.Errorf("%q must contain only lowercase letters, digits and '-', and start and end with a letter or digit", name)
+	}
+	return nil
+}
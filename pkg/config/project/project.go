@@ -0,0 +1,214 @@
+// Package configProject loads the configuration of an initialised project, whatever its type.
+package configProject
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	config "github.com/mouad4949/DAAB/pkg/config"
+	configMicroservice "github.com/mouad4949/DAAB/pkg/config/microservice"
+	configMonolith "github.com/mouad4949/DAAB/pkg/config/monolith"
+	"github.com/mouad4949/DAAB/pkg/secrets"
+)
+
+// Names of the config folder and files inside a project.
+const (
+	ConfigDir      = ".init"
+	ConfigFile     = "daab.yaml"
+	RootConfigFile = "daab.root.yaml"
+)
+
+// Service is a microservice config together with its identity in daab.root.yaml and the
+// folder it was loaded from.
+type Service struct {
+	// Name is the stable, DNS-safe name of the service
+	Name    string
+	Aliases []string
+	// Path is the service folder: the path from daab.root.yaml joined to the project path
+	Path string
+	// Config is the service config as stored in its daab.yaml
+	Config *configMicroservice.ConfigMicroservice
+	// Effective is Config with the settings inherited from daab.root.yaml
+	Effective *configMicroservice.ConfigMicroservice
+}
+
+// Project is the resolved DAAB configuration of a repository for one environment.
+type Project struct {
+	Path        string
+	Environment string
+
+	// Set for monolith projects
+	Monolith *configMonolith.ConfigMonolith
+
+	// Set for microservice projects. Services are in deploy order: every service comes
+	// after the services it depends on
+	Root     *configMicroservice.ConfigMicroRoot
+	Services []Service
+}
+
+// IsMicroservice reports whether the project was initialised as a microservice project.
+func (p *Project) IsMicroservice() bool {
+	return p.Root != nil
+}
+
+// Load reads daab.root.yaml (microservices) or daab.yaml (monolith) under projectPath
+// and resolves the overlay for env. An empty env loads the base configuration.
+func Load(projectPath, env string) (*Project, error) {
+	project := &Project{
+		Path:        projectPath,
+		Environment: env,
+	}
+
+	rootPath := filepath.Join(projectPath, ConfigDir, RootConfigFile)
+	if fileExists(rootPath) {
+		root := configMicroservice.NewConfigMicroRoot()
+		if err := config.LoadFile(rootPath, env, root); err != nil {
+			return nil, err
+		}
+		project.Root = root
+
+		refs := root.ServiceRefs()
+		if err := configMicroservice.ValidateServiceRefs(refs); err != nil {
+			return nil, fmt.Errorf("%s: %w", rootPath, err)
+		}
+		if err := root.Ingress.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", rootPath, err)
+		}
+		prefixes := map[string]string{}
+		for _, ref := range refs {
+			servicePath := filepath.Join(projectPath, filepath.FromSlash(ref.Path))
+			svc := configMicroservice.NewConfigMicroservice()
+			if err := config.LoadFile(filepath.Join(servicePath, ConfigDir, ConfigFile), serviceEnv(servicePath, env), svc); err != nil {
+				return nil, fmt.Errorf("service %s: %w", ref.Name, err)
+			}
+
+			effective := configMicroservice.Resolve(root, svc)
+			effective.ProjectName = ref.Name
+			if err := effective.Validate(); err != nil {
+				return nil, fmt.Errorf("service %s: %w", ref.Name, err)
+			}
+			if prefix := effective.PathPrefix; prefix != "" {
+				if !config.IsKubernetes(effective.Target) {
+					return nil, fmt.Errorf("service %s: path_prefix routes from the ingress of the cluster, the %s target is exposed on its own", ref.Name, effective.Target)
+				}
+				if err := config.ValidatePathPrefix(prefix); err != nil {
+					return nil, fmt.Errorf("service %s: %w", ref.Name, err)
+				}
+				if other, ok := prefixes[prefix]; ok {
+					return nil, fmt.Errorf("services %s and %s both use the path prefix %s", other, ref.Name, prefix)
+				}
+				prefixes[prefix] = ref.Name
+			}
+			project.Services = append(project.Services, Service{
+				Name:      ref.Name,
+				Aliases:   ref.Aliases,
+				Path:      servicePath,
+				Config:    svc,
+				Effective: effective,
+			})
+		}
+		if err := project.orderServices(refs); err != nil {
+			return nil, fmt.Errorf("%s: %w", rootPath, err)
+		}
+		return project, nil
+	}
+
+	monolithPath := filepath.Join(projectPath, ConfigDir, ConfigFile)
+	if fileExists(monolithPath) {
+		monolith := configMonolith.NewConfigMonolith()
+		if err := config.LoadFile(monolithPath, env, monolith); err != nil {
+			return nil, err
+		}
+		if err := monolith.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", monolithPath, err)
+		}
+		if err := monolith.Ingress.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", monolithPath, err)
+		}
+		if monolith.Ingress != nil && !config.IsKubernetes(monolith.Target) {
+			return nil, fmt.Errorf("%s: the ingress needs the kubernetes target, the %s target is exposed on its own", monolithPath, monolith.Target)
+		}
+		project.Monolith = monolith
+		return project, nil
+	}
+
+	return nil, fmt.Errorf("no DAAB configuration found in %s, run 'daab init' first", projectPath)
+}
+
+// ResolveSecrets replaces the secret:// references of the configuration with their values.
+// It is called right before the configuration is rendered, so the references are never
+// resolved when the configuration is only inspected.
+func (p *Project) ResolveSecrets(ctx context.Context, resolver *secrets.Resolver) error {
+	if p.Monolith != nil {
+		return resolver.ResolveAll(ctx, p.Monolith)
+	}
+	if err := resolver.ResolveAll(ctx, p.Root); err != nil {
+		return fmt.Errorf("%s: %w", RootConfigFile, err)
+	}
+	for _, svc := range p.Services {
+		if err := resolver.ResolveAll(ctx, svc.Config); err != nil {
+			return fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+		if err := resolver.ResolveAll(ctx, svc.Effective); err != nil {
+			return fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+	}
+	return nil
+}
+
+// orderServices sorts the services so every service comes after the services it depends
+// on, which is the order they are deployed in.
+func (p *Project) orderServices(refs []configMicroservice.ServiceRef) error {
+	dependencies, err := configMicroservice.DependencyGraph(refs)
+	if err != nil {
+		return err
+	}
+	order, err := dependencies.Order()
+	if err != nil {
+		return err
+	}
+
+	byName := map[string]Service{}
+	for _, svc := range p.Services {
+		byName[svc.Name] = svc
+	}
+	p.Services = p.Services[:0]
+	for _, name := range order {
+		p.Services = append(p.Services, byName[name])
+	}
+	return nil
+}
+
+// Environments lists the environments defined for the project at projectPath.
+func Environments(projectPath string) ([]string, error) {
+	rootPath := filepath.Join(projectPath, ConfigDir, RootConfigFile)
+	if fileExists(rootPath) {
+		return config.ListEnvironments(rootPath)
+	}
+	return config.ListEnvironments(filepath.Join(projectPath, ConfigDir, ConfigFile))
+}
+
+// serviceEnv returns env if the service defines it. Services only need an overlay
+// when they differ from the root for that environment.
+func serviceEnv(servicePath, env string) string {
+	if env == "" {
+		return ""
+	}
+	envs, err := config.ListEnvironments(filepath.Join(servicePath, ConfigDir, ConfigFile))
+	if err != nil {
+		return ""
+	}
+	for _, name := range envs {
+		if name == env {
+			return env
+		}
+	}
+	return ""
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
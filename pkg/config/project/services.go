@@ -0,0 +1,92 @@
+package configProject
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	configMicroservice "github.com/mouad4949/DAAB/pkg/config/microservice"
+	"gopkg.in/yaml.v3"
+)
+
+// Matches reports whether name is the name or one of the aliases of the service.
+func (s *Service) Matches(name string) bool {
+	ref := configMicroservice.ServiceRef{Name: s.Name, Aliases: s.Aliases}
+	return ref.Matches(name)
+}
+
+// FindService returns the service named name, or with name as an alias.
+func (p *Project) FindService(name string) (*Service, bool) {
+	for idx := range p.Services {
+		if p.Services[idx].Matches(name) {
+			return &p.Services[idx], true
+		}
+	}
+	return nil, false
+}
+
+// SetRootServices replaces the services of the daab.root.yaml content and updates its
+// updated_at. The legacy detected_files list is dropped, the rest of the file, including
+// comments, is kept as is.
+func SetRootServices(content []byte, services []configMicroservice.ServiceRef) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", RootConfigFile, err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s is not a YAML mapping", RootConfigFile)
+	}
+	mapping := doc.Content[0]
+
+	if err := configMicroservice.ValidateServiceRefs(services); err != nil {
+		return nil, err
+	}
+
+	list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}
+	if len(services) > 0 {
+		if err := list.Encode(services); err != nil {
+			return nil, err
+		}
+	}
+	removeMappingKey(mapping, "detected_files")
+	setMappingValue(mapping, "services", list)
+	setMappingValue(mapping, "updated_at", &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   "!!timestamp",
+		Value: time.Now().UTC().Format(time.RFC3339Nano),
+	})
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(4)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// setMappingValue sets key in a mapping node, appending it when missing.
+func setMappingValue(mapping *yaml.Node, key string, value *yaml.Node) {
+	for idx := 0; idx+1 < len(mapping.Content); idx += 2 {
+		if mapping.Content[idx].Value == key {
+			mapping.Content[idx+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		value,
+	)
+}
+
+func removeMappingKey(mapping *yaml.Node, key string) {
+	for idx := 0; idx+1 < len(mapping.Content); idx += 2 {
+		if mapping.Content[idx].Value == key {
+			mapping.Content = append(mapping.Content[:idx], mapping.Content[idx+2:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ResourceList is an amount of CPU and memory in Kubernetes quantities ("250m", "512Mi").
+type ResourceList struct {
+	CPU    string `yaml:"cpu,omitempty"`
+	Memory string `yaml:"memory,omitempty"`
+}
+
+// Resources are the requests and limits of a workload container.
+type Resources struct {
+	Requests ResourceList `yaml:"requests,omitempty"`
+	Limits   ResourceList `yaml:"limits,omitempty"`
+}
+
+// MergeResources returns base with every value set in override replacing it.
+// Either argument may be nil.
+func MergeResources(base, override *Resources) *Resources {
+	if base == nil && override == nil {
+		return nil
+	}
+
+	merged := &Resources{}
+	if base != nil {
+		*merged = *base
+	}
+	if override == nil {
+		return merged
+	}
+
+	merged.Requests = mergeResourceList(merged.Requests, override.Requests)
+	merged.Limits = mergeResourceList(merged.Limits, override.Limits)
+	return merged
+}
+
+// DefaultResources returns the requests and limits suited to applications written in
+// language: JVM and .NET runtimes need more memory than Go or Rust binaries.
+func DefaultResources(language string) *Resources {
+	switch language {
+	case "java", "dotnet":
+		return &Resources{
+			Requests: ResourceList{CPU: "250m", Memory: "512Mi"},
+			Limits:   ResourceList{CPU: "1", Memory: "1Gi"},
+		}
+	case "nodejs", "python", "ruby", "php":
+		return &Resources{
+			Requests: ResourceList{CPU: "100m", Memory: "128Mi"},
+			Limits:   ResourceList{CPU: "500m", Memory: "512Mi"},
+		}
+	case "go", "rust", "static":
+		return &Resources{
+			Requests: ResourceList{CPU: "50m", Memory: "64Mi"},
+			Limits:   ResourceList{CPU: "500m", Memory: "256Mi"},
+		}
+	}
+	return &Resources{
+		Requests: ResourceList{CPU: "100m", Memory: "128Mi"},
+		Limits:   ResourceList{CPU: "500m", Memory: "512Mi"},
+	}
+}
+
+// Validate checks the quantities and that no request exceeds its limit. Nil is valid.
+func (r *Resources) Validate() error {
+	if r == nil {
+		return nil
+	}
+	values := map[string]float64{}
+	for _, q := range []struct{ field, value string }{
+		{"requests.cpu", r.Requests.CPU},
+		{"requests.memory", r.Requests.Memory},
+		{"limits.cpu", r.Limits.CPU},
+		{"limits.memory", r.Limits.Memory},
+	} {
+		if q.value == "" {
+			continue
+		}
+		value, err := ParseQuantity(q.value)
+		if err != nil || value <= 0 {
+			return fmt.Errorf("resources: invalid %s %q, use a quantity such as \"250m\", \"1\" or \"512Mi\"", q.field, q.value)
+		}
+		values[q.field] = value
+	}
+	for _, name := range []string{"cpu", "memory"} {
+		request, hasRequest := values["requests."+name]
+		limit, hasLimit := values["limits."+name]
+		if hasRequest && hasLimit && request > limit {
+			return fmt.Errorf("resources: the %s request is greater than its limit", name)
+		}
+	}
+	return nil
+}
+
+// quantitySuffixes are the multipliers of the suffixes of Kubernetes quantities.
+var quantitySuffixes = []struct {
+	suffix     string
+	multiplier float64
+}{
+	// Binary suffixes first, "Mi" would otherwise be read as "M"
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40}, {"Pi", 1 << 50}, {"Ei", 1 << 60},
+	{"m", 1e-3}, {"k", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12}, {"P", 1e15}, {"E", 1e18},
+}
+
+// ParseQuantity returns the value of a Kubernetes quantity such as "250m" (0.25), "2"
+// or "512Mi" (536870912).
+func ParseQuantity(quantity string) (float64, error) {
+	number, multiplier := quantity, 1.0
+	for _, s := range quantitySuffixes {
+		if strings.HasSuffix(quantity, s.suffix) {
+			number, multiplier = strings.TrimSuffix(quantity, s.suffix), s.multiplier
+			break
+		}
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || strings.ContainsAny(number, "eEnNxX+") {
+		return 0, fmt.Errorf("invalid quantity %q", quantity)
+	}
+	return value * multiplier, nil
+}
+
+func mergeResourceList(base, override ResourceList) ResourceList {
+	if override.CPU != "" {
+		base.CPU = override.CPU
+	}
+	if override.Memory != "" {
+		base.Memory = override.Memory
+	}
+	return base
+}
+
+// MergeLabels returns the union of base and override, override winning on conflicts.
+func MergeLabels(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,71 @@
+package config
+
+import "fmt"
+
+// Types of custom autoscaling metrics.
+const (
+	// MetricPods is a metric of the pods, averaged over them
+	MetricPods = "pods"
+	// MetricExternal is a metric from outside the cluster, e.g. the length of a queue
+	MetricExternal = "external"
+)
+
+// Autoscaling scales the replicas of an application between MinReplicas and MaxReplicas
+// to keep the usage around the targets.
+type Autoscaling struct {
+	MinReplicas int `yaml:"min_replicas"`
+	MaxReplicas int `yaml:"max_replicas"`
+	// TargetCPU is the average CPU usage, in percent of the CPU request
+	TargetCPU int `yaml:"target_cpu,omitempty"`
+	// TargetMemory is the average memory usage, in percent of the memory request
+	TargetMemory int            `yaml:"target_memory,omitempty"`
+	Metrics      []CustomMetric `yaml:"metrics,omitempty"`
+}
+
+// CustomMetric is a metric served by a metrics adapter, e.g. requests per second.
+type CustomMetric struct {
+	Name string `yaml:"name"`
+	// Type is pods (default) or external
+	Type string `yaml:"type,omitempty"`
+	// Target is the average value per pod, a quantity such as "100" or "500m"
+	Target string `yaml:"target"`
+}
+
+// MetricType returns the type of the metric, pods when it has none.
+func (m CustomMetric) MetricType() string {
+	if m.Type == "" {
+		return MetricPods
+	}
+	return m.Type
+}
+
+// Validate checks the bounds and the targets. Nil is valid.
+func (a *Autoscaling) Validate() error {
+	if a == nil {
+		return nil
+	}
+	if a.MinReplicas < 1 {
+		return fmt.Errorf("autoscaling: min_replicas must be at least 1, got %d", a.MinReplicas)
+	}
+	if a.MaxReplicas < a.MinReplicas {
+		return fmt.Errorf("autoscaling: max_replicas (%d) must not be lower than min_replicas (%d)", a.MaxReplicas, a.MinReplicas)
+	}
+	if a.TargetCPU < 0 || a.TargetMemory < 0 {
+		return fmt.Errorf("autoscaling: targets are percentages of the requests and must be positive")
+	}
+	if a.TargetCPU == 0 && a.TargetMemory == 0 && len(a.Metrics) == 0 {
+		return fmt.Errorf("autoscaling: set target_cpu, target_memory or custom metrics")
+	}
+	for idx, metric := range a.Metrics {
+		if metric.Name == "" {
+			return fmt.Errorf("autoscaling: metric %d needs a name", idx+1)
+		}
+		if metric.MetricType() != MetricPods && metric.MetricType() != MetricExternal {
+			return fmt.Errorf("autoscaling: metric %s: unknown type %q, use %s or %s", metric.Name, metric.Type, MetricPods, MetricExternal)
+		}
+		if value, err := ParseQuantity(metric.Target); err != nil || value <= 0 {
+			return fmt.Errorf("autoscaling: metric %s: invalid target %q, use a quantity such as \"100\"", metric.Name, metric.Target)
+		}
+	}
+	return nil
+}
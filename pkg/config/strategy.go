@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Deployment strategies.
+const (
+	StrategyRolling   = "rolling"
+	StrategyBlueGreen = "blue-green"
+	StrategyCanary    = "canary"
+)
+
+// DefaultStrategyTimeout bounds every health gate of a deployment.
+const DefaultStrategyTimeout = 5 * time.Minute
+
+// Strategy is how a new version of an application replaces the running one.
+type Strategy struct {
+	// Type is rolling (default), blue-green or canary
+	Type      string             `yaml:"type,omitempty"`
+	Rolling   *RollingStrategy   `yaml:"rolling,omitempty"`
+	BlueGreen *BlueGreenStrategy `yaml:"blue_green,omitempty"`
+	Canary    *CanaryStrategy    `yaml:"canary,omitempty"`
+	// Timeout of every health gate, e.g. "5m"; the deployment is aborted when it expires
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// RollingStrategy replaces the pods progressively. Values are pod counts ("1") or
+// percentages of the replicas ("25%").
+type RollingStrategy struct {
+	MaxSurge       string `yaml:"max_surge,omitempty"`
+	MaxUnavailable string `yaml:"max_unavailable,omitempty"`
+}
+
+// BlueGreenStrategy starts the new version next to the running one and switches the
+// Service to it once it is healthy.
+type BlueGreenStrategy struct {
+	// KeepPrevious keeps the previous version running after the switch, to switch back
+	// instantly, at the cost of twice the resources
+	KeepPrevious bool `yaml:"keep_previous,omitempty"`
+}
+
+// CanaryStrategy sends a growing share of the traffic to the new version, step by step.
+type CanaryStrategy struct {
+	Steps []CanaryStep `yaml:"steps"`
+}
+
+// CanaryStep sends Weight percent of the traffic to the new version, then waits Pause
+// (e.g. "2m") before the next step.
+type CanaryStep struct {
+	Weight int    `yaml:"weight"`
+	Pause  string `yaml:"pause,omitempty"`
+}
+
+// Kind returns the type of the strategy, rolling when s is nil or has no type.
+func (s *Strategy) Kind() string {
+	if s == nil || s.Type == "" {
+		return StrategyRolling
+	}
+	return s.Type
+}
+
+// TimeoutDuration returns the timeout of the health gates.
+func (s *Strategy) TimeoutDuration() time.Duration {
+	if s == nil || s.Timeout == "" {
+		return DefaultStrategyTimeout
+	}
+	timeout, err := time.ParseDuration(s.Timeout)
+	if err != nil {
+		return DefaultStrategyTimeout
+	}
+	return timeout
+}
+
+// PauseDuration returns the pause of the step, 0 when it has none.
+func (s CanaryStep) PauseDuration() time.Duration {
+	pause, _ := time.ParseDuration(s.Pause)
+	return pause
+}
+
+// Validate checks the strategy. A nil strategy is valid.
+func (s *Strategy) Validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.Timeout != "" {
+		if timeout, err := time.ParseDuration(s.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("strategy: invalid timeout %q, use a duration such as \"5m\"", s.Timeout)
+		}
+	}
+
+	switch s.Kind() {
+	case StrategyRolling:
+		if s.Rolling != nil {
+			if err := validateIntOrPercent("max_surge", s.Rolling.MaxSurge); err != nil {
+				return err
+			}
+			if err := validateIntOrPercent("max_unavailable", s.Rolling.MaxUnavailable); err != nil {
+				return err
+			}
+		}
+	case StrategyBlueGreen:
+	case StrategyCanary:
+		if s.Canary == nil || len(s.Canary.Steps) == 0 {
+			return fmt.Errorf("strategy: canary needs at least one step")
+		}
+		previous := 0
+		for idx, step := range s.Canary.Steps {
+			if step.Weight <= previous || step.Weight > 100 {
+				return fmt.Errorf("strategy: canary step %d: weights must grow between 1 and 100, got %d", idx+1, step.Weight)
+			}
+			previous = step.Weight
+			if step.Pause != "" {
+				if pause, err := time.ParseDuration(step.Pause); err != nil || pause < 0 {
+					return fmt.Errorf("strategy: canary step %d: invalid pause %q, use a duration such as \"2m\"", idx+1, step.Pause)
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("strategy: unknown type %q, use %s, %s or %s", s.Type, StrategyRolling, StrategyBlueGreen, StrategyCanary)
+	}
+	return nil
+}
+
+func validateIntOrPercent(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	number := strings.TrimSuffix(value, "%")
+	if n, err := strconv.Atoi(number); err != nil || n < 0 {
+		return fmt.Errorf("strategy: invalid %s %q, use a pod count (\"1\") or a percentage (\"25%%\")", field, value)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Deployment targets: where the applications of a project run.
+const (
+	TargetKubernetes = "kubernetes"
+	// TargetCloudRun runs the image as a Google Cloud Run service
+	TargetCloudRun = "cloud-run"
+	// TargetLambda runs the image as an AWS Lambda function behind an HTTP API
+	TargetLambda = "lambda"
+	// TargetContainerApps runs the image as an Azure Container App
+	TargetContainerApps = "container-apps"
+	// TargetECSFargate runs the image as an Amazon ECS task on Fargate
+	TargetECSFargate = "ecs-fargate"
+)
+
+// targetProviders is the cloud provider of every serverless target. Kubernetes runs
+// on every provider.
+var targetProviders = map[string]string{
+	TargetCloudRun:      "gcp",
+	TargetLambda:        "aws",
+	TargetContainerApps: "azure",
+	TargetECSFargate:    "aws",
+}
+
+// Targets returns the targets available on provider, kubernetes first. Every target is
+// returned when provider is empty.
+func Targets(provider string) []string {
+	targets := []string{TargetKubernetes}
+	for _, target := range []string{TargetCloudRun, TargetLambda, TargetContainerApps, TargetECSFargate} {
+		if provider == "" || targetProviders[target] == provider {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// TargetOrDefault returns target, kubernetes when it is empty.
+func TargetOrDefault(target string) string {
+	if target == "" {
+		return TargetKubernetes
+	}
+	return target
+}
+
+// IsKubernetes reports whether applications with target are deployed to a cluster.
+func IsKubernetes(target string) bool {
+	return TargetOrDefault(target) == TargetKubernetes
+}
+
+// ValidateTarget checks that target exists and runs on provider. An empty target is
+// kubernetes.
+func ValidateTarget(provider, target string) error {
+	target = TargetOrDefault(target)
+	if target == TargetKubernetes {
+		return nil
+	}
+	required, ok := targetProviders[target]
+	if !ok {
+		return fmt.Errorf("unknown target %q, use one of %s", target, strings.Join(Targets(""), ", "))
+	}
+	if provider != "" && provider != required {
+		return fmt.Errorf("target %s runs on %s, not %s; use one of %s", target, required, provider, strings.Join(Targets(provider), ", "))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTarget(t *testing.T) {
+	tests := []struct {
+		provider string
+		target   string
+		wantErr  string
+	}{
+		{provider: "aws", target: ""},
+		{provider: "gcp", target: TargetKubernetes},
+		{provider: "gcp", target: TargetCloudRun},
+		{provider: "aws", target: TargetLambda},
+		{provider: "azure", target: TargetContainerApps},
+		{provider: "aws", target: TargetECSFargate},
+		// The provider is not known yet
+		{provider: "", target: TargetCloudRun},
+		{provider: "aws", target: TargetCloudRun, wantErr: "runs on gcp, not aws"},
+		{provider: "gcp", target: TargetECSFargate, wantErr: "runs on aws, not gcp"},
+		{provider: "aws", target: "app-engine", wantErr: `unknown target "app-engine"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.target, func(t *testing.T) {
+			err := ValidateTarget(tt.provider, tt.target)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateTarget(%q, %q): %v", tt.provider, tt.target, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateTarget(%q, %q) error = %v, want %q", tt.provider, tt.target, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateServerlessApp(t *testing.T) {
+	tests := []struct {
+		name    string
+		app     BaseConfigApp
+		wantErr string
+	}{
+		{
+			name: "rolling updates",
+			app:  BaseConfigApp{BaseConfig: BaseConfig{CloudProvider: "gcp", Target: TargetCloudRun}, Strategy: &Strategy{Type: StrategyRolling}},
+		},
+		{
+			name:    "blue-green",
+			app:     BaseConfigApp{BaseConfig: BaseConfig{CloudProvider: "gcp", Target: TargetCloudRun}, Strategy: &Strategy{Type: StrategyBlueGreen}},
+			wantErr: "strategy blue-green needs the kubernetes target",
+		},
+		{
+			name: "custom metrics",
+			app: BaseConfigApp{
+				BaseConfig:  BaseConfig{CloudProvider: "aws", Target: TargetLambda},
+				Autoscaling: &Autoscaling{MinReplicas: 1, MaxReplicas: 3, Metrics: []CustomMetric{{Name: "requests_per_second"}}},
+			},
+			wantErr: "custom metrics need the kubernetes target",
+		},
+		{
+			name:    "wrong provider",
+			app:     BaseConfigApp{BaseConfig: BaseConfig{CloudProvider: "azure", Target: TargetLambda}},
+			wantErr: "runs on aws, not azure",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.app.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate(): %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
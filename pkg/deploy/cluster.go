@@ -0,0 +1,257 @@
+// Package deploy applies the manifests of applications to a Kubernetes cluster and keeps
+// the history of the releases, so a previous release can be applied again.
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Cluster is the Kubernetes cluster the applications are deployed to.
+type Cluster interface {
+	// Apply creates or updates the objects of manifests, a multi-document YAML stream,
+	// in namespace
+	Apply(ctx context.Context, namespace string, manifests []byte) error
+	// Get returns the manifest of an object, ErrNotFound when it does not exist
+	Get(ctx context.Context, namespace, kind, name string) ([]byte, error)
+	// Delete removes an object, it is not an error when it does not exist
+	Delete(ctx context.Context, namespace, kind, name string) error
+	// WaitReady waits until the rollout of a Deployment is complete and its pods are
+	// ready, which includes passing their readiness probes
+	WaitReady(ctx context.Context, namespace, deployment string, timeout time.Duration) error
+	// Undo reverts a Deployment to its previous revision
+	Undo(ctx context.Context, namespace, deployment string) error
+}
+
+// ErrNotFound is returned by Cluster.Get for missing objects.
+var ErrNotFound = errors.New("not found")
+
+// Kubectl applies manifests with kubectl, using the current kubeconfig.
+type Kubectl struct {
+	// Context is the kubeconfig context, the current one when empty
+	Context string
+	// Output receives the output of kubectl
+	Output io.Writer
+}
+
+// Apply runs 'kubectl apply' with the manifests on its input.
+func (k *Kubectl) Apply(ctx context.Context, namespace string, manifests []byte) error {
+	_, err := k.run(ctx, namespace, manifests, k.Output, "apply", "-f", "-")
+	return err
+}
+
+// Get returns the object as YAML, or ErrNotFound.
+func (k *Kubectl) Get(ctx context.Context, namespace, kind, name string) ([]byte, error) {
+	var out bytes.Buffer
+	stderr, err := k.run(ctx, namespace, nil, &out, "get", kind, name, "--output", "yaml")
+	if err != nil {
+		if strings.Contains(stderr, "NotFound") {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Delete deletes the object, ignoring objects that do not exist.
+func (k *Kubectl) Delete(ctx context.Context, namespace, kind, name string) error {
+	_, err := k.run(ctx, namespace, nil, k.Output, "delete", kind, name, "--ignore-not-found")
+	return err
+}
+
+// WaitReady waits for the rollout of the deployment with 'kubectl rollout status'.
+func (k *Kubectl) WaitReady(ctx context.Context, namespace, deployment string, timeout time.Duration) error {
+	_, err := k.run(ctx, namespace, nil, k.Output, "rollout", "status", "deployment/"+deployment, "--timeout", timeout.String())
+	return err
+}
+
+// Undo rolls the deployment back to its previous revision with 'kubectl rollout undo'.
+func (k *Kubectl) Undo(ctx context.Context, namespace, deployment string) error {
+	_, err := k.run(ctx, namespace, nil, k.Output, "rollout", "undo", "deployment/"+deployment)
+	return err
+}
+
+// run runs kubectl with the context and namespace, and returns its error output.
+func (k *Kubectl) run(ctx context.Context, namespace string, stdin []byte, stdout io.Writer, args ...string) (string, error) {
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return "", fmt.Errorf("deploying needs kubectl in PATH")
+	}
+	verb := args[0]
+	if k.Context != "" {
+		args = append([]string{"--context", k.Context}, args...)
+	}
+	if namespace != "" {
+		args = append(args, "--namespace", namespace)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return stderr.String(), fmt.Errorf("kubectl %s failed: %w: %s", verb, err, strings.TrimSpace(stderr.String()))
+	}
+	return stderr.String(), nil
+}
+
+// FakeCluster is an in-memory cluster recording what is applied, to test deployments
+// without Kubernetes. It is safe for concurrent use.
+type FakeCluster struct {
+	// Err, when set, is returned by every Apply
+	Err error
+	// Health, when set, decides whether a Deployment becomes ready in WaitReady
+	Health func(namespace, deployment string) error
+
+	mu      sync.Mutex
+	applied []Applied
+	// objects keeps every applied version of each object, the current one last
+	objects map[string][][]byte
+}
+
+// Applied is one call to FakeCluster.Apply.
+type Applied struct {
+	Namespace string
+	Manifests []byte
+}
+
+func objectKey(namespace, kind, name string) string {
+	return namespace + "/" + kind + "/" + name
+}
+
+// Apply records the manifests and adds a version to each of their objects, or returns Err.
+func (f *FakeCluster) Apply(ctx context.Context, namespace string, manifests []byte) error {
+	if f.Err != nil {
+		return f.Err
+	}
+	objects, err := splitObjects(namespace, manifests)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.objects == nil {
+		f.objects = map[string][][]byte{}
+	}
+	for _, object := range objects {
+		f.objects[object.key] = append(f.objects[object.key], object.data)
+	}
+	f.applied = append(f.applied, Applied{Namespace: namespace, Manifests: manifests})
+	return nil
+}
+
+// Get returns the latest version of the object, or ErrNotFound.
+func (f *FakeCluster) Get(ctx context.Context, namespace, kind, name string) ([]byte, error) {
+	if object, ok := f.Object(namespace, kind, name); ok {
+		return object, nil
+	}
+	return nil, ErrNotFound
+}
+
+// Delete forgets the object and its versions.
+func (f *FakeCluster) Delete(ctx context.Context, namespace, kind, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.objects, objectKey(namespace, kind, name))
+	return nil
+}
+
+// WaitReady fails when the deployment was never applied, and otherwise returns the
+// result of Health.
+func (f *FakeCluster) WaitReady(ctx context.Context, namespace, deployment string, timeout time.Duration) error {
+	if _, ok := f.Object(namespace, "Deployment", deployment); !ok {
+		return fmt.Errorf("deployment %s not found", deployment)
+	}
+	if f.Health != nil {
+		return f.Health(namespace, deployment)
+	}
+	return nil
+}
+
+// Undo drops the latest version of the deployment.
+func (f *FakeCluster) Undo(ctx context.Context, namespace, deployment string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := objectKey(namespace, "Deployment", deployment)
+	versions := f.objects[key]
+	if len(versions) < 2 {
+		return fmt.Errorf("deployment %s has no previous revision", deployment)
+	}
+	f.objects[key] = versions[:len(versions)-1]
+	return nil
+}
+
+// Applied returns every call to Apply, in order.
+func (f *FakeCluster) Applied() []Applied {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Applied(nil), f.applied...)
+}
+
+// Object returns the current manifest of the object, e.g. ("prod", "Deployment", "api").
+// Namespaces are cluster objects: ("", "Namespace", "prod").
+func (f *FakeCluster) Object(namespace, kind, name string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	versions := f.objects[objectKey(namespace, kind, name)]
+	if len(versions) == 0 {
+		return nil, false
+	}
+	return versions[len(versions)-1], true
+}
+
+type fakeObject struct {
+	key  string
+	data []byte
+}
+
+// splitObjects returns the documents of manifests with their namespace, kind and name.
+func splitObjects(namespace string, manifests []byte) ([]fakeObject, error) {
+	var objects []fakeObject
+	decoder := yaml.NewDecoder(bytes.NewReader(manifests))
+	for {
+		var object struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name      string `yaml:"name"`
+				Namespace string `yaml:"namespace"`
+			} `yaml:"metadata"`
+		}
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifests: %w", err)
+		}
+		if err := node.Decode(&object); err != nil {
+			return nil, fmt.Errorf("invalid manifests: %w", err)
+		}
+		if object.Kind == "" || object.Metadata.Name == "" {
+			return nil, fmt.Errorf("invalid manifests: objects need a kind and a name")
+		}
+
+		data, err := yaml.Marshal(&node)
+		if err != nil {
+			return nil, err
+		}
+		ns := object.Metadata.Namespace
+		if ns == "" && object.Kind != "Namespace" {
+			ns = namespace
+		}
+		objects = append(objects, fakeObject{key: objectKey(ns, object.Kind, object.Metadata.Name), data: data})
+	}
+}
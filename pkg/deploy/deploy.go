@@ -0,0 +1,356 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+	"time"
+
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+	"github.com/mouad4949/DAAB/pkg/generate"
+	"gopkg.in/yaml.v3"
+)
+
+// Options configure Deploy and Rollback.
+type Options struct {
+	Cluster Cluster
+	// Store records the releases, they are not recorded when nil
+	Store Store
+	// Output receives the progress of the deployment
+	Output io.Writer
+	// Pause waits between the steps of canary deployments, a plain sleep when nil
+	Pause func(ctx context.Context, d time.Duration) error
+	// EntryPoint is applied once every app is deployed, when set
+	EntryPoint *generate.EntryPoint
+
+	// Recorded in the release
+	Environment string
+	ConfigHash  string
+	GitCommit   string
+	User        string
+}
+
+func (o *Options) output() io.Writer {
+	if o.Output == nil {
+		return io.Discard
+	}
+	return o.Output
+}
+
+func (o *Options) pause(ctx context.Context, d time.Duration) error {
+	if o.Pause == nil {
+		return sleep(ctx, d)
+	}
+	return o.Pause(ctx, d)
+}
+
+// Manifests renders the Kubernetes manifests of app into one YAML stream, starting with
+// its Namespace so a first deployment creates it.
+func Manifests(app *generate.App) ([]byte, error) {
+	files, err := generate.Generate(app, &generate.Kubernetes{})
+	if err != nil {
+		return nil, err
+	}
+
+	var stream bytes.Buffer
+	if app.Namespace != "" {
+		fmt.Fprintf(&stream, "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: %s\n", app.Namespace)
+	}
+	for _, file := range files {
+		if stream.Len() > 0 {
+			stream.WriteString("---\n")
+		}
+		stream.Write(bytes.TrimPrefix(file.Content, []byte(generate.Header)))
+	}
+	return stream.Bytes(), nil
+}
+
+// Deploy rolls out every app, in order, with its strategy, and records the release in
+// opts.Store, failed or not. The image of each app is its Reference. The deployment stops
+// at the first app that fails its health gates, that app being reverted. The release
+// holds every service of the environment, the ones not in apps as in the latest release.
+func Deploy(ctx context.Context, apps []*generate.App, opts Options) (*Release, error) {
+	var previous []ReleaseService
+	if opts.Store != nil {
+		latest, err := latestRelease(opts.Store, opts.Environment)
+		if err != nil {
+			return nil, err
+		}
+		if latest != nil {
+			previous = latest.Services
+		}
+	}
+
+	release := newRelease(opts)
+	var deployErr error
+	for _, app := range apps {
+		manifests, err := rollout(ctx, app, opts)
+		if err != nil {
+			deployErr = fmt.Errorf("%s: %w", app.Name, err)
+			break
+		}
+		release.Services = append(release.Services, ReleaseService{
+			Name:      app.Name,
+			Namespace: app.Namespace,
+			Image:     app.ImageReference(),
+			Strategy:  app.Strategy.Kind(),
+			Manifests: string(manifests),
+		})
+	}
+	if deployErr == nil && opts.EntryPoint != nil {
+		svc, err := applyEntryPoint(ctx, opts)
+		if err != nil {
+			deployErr = fmt.Errorf("%s: %w", EntryPointService, err)
+		} else {
+			release.Services = append(release.Services, *svc)
+		}
+	}
+
+	release.Services = carryForward(previous, release.Services)
+	return release, record(release, deployErr, opts)
+}
+
+// carryForward returns the services of the previous release with the ones deployed
+// replaced, so a release deploying some services still holds the whole project and
+// rolling back to it restores every service. The services deployed for the first time
+// come before the entry point.
+func carryForward(previous, deployed []ReleaseService) []ReleaseService {
+	byName := map[string]ReleaseService{}
+	for _, svc := range deployed {
+		byName[svc.Name] = svc
+	}
+
+	var services []ReleaseService
+	for _, svc := range previous {
+		if current, ok := byName[svc.Name]; ok {
+			svc = current
+			delete(byName, svc.Name)
+		}
+		services = append(services, svc)
+	}
+
+	var added []ReleaseService
+	for _, svc := range deployed {
+		if _, ok := byName[svc.Name]; ok {
+			added = append(added, svc)
+		}
+	}
+	at := len(services)
+	if at > 0 && services[at-1].Name == EntryPointService {
+		at--
+	}
+	return append(services[:at], append(added, services[at:]...)...)
+}
+
+// EntryPointService is the name of the entry point in the services of a release.
+const EntryPointService = "ingress"
+
+// applyEntryPoint applies the Ingress or the Gateway API objects routing to the apps.
+func applyEntryPoint(ctx context.Context, opts Options) (*ReleaseService, error) {
+	var stream bytes.Buffer
+	for _, object := range opts.EntryPoint.Objects() {
+		data, err := generate.MarshalManifest(object)
+		if err != nil {
+			return nil, err
+		}
+		if stream.Len() > 0 {
+			stream.WriteString("---\n")
+		}
+		stream.Write(data)
+	}
+
+	fmt.Fprintf(opts.output(), "🌐 Applying the %s entry point\n", opts.EntryPoint.Ingress.Kind())
+	if err := opts.Cluster.Apply(ctx, "", stream.Bytes()); err != nil {
+		return nil, err
+	}
+	return &ReleaseService{Name: EntryPointService, Manifests: stream.String()}, nil
+}
+
+// Rollback applies the manifests of a previous release again and records it as a new
+// release. With to set, that release is applied; otherwise the last successful release
+// of the environment before the current one, or the last successful one when the latest
+// deploy failed.
+func Rollback(ctx context.Context, to int, opts Options) (*Release, error) {
+	if opts.Store == nil {
+		return nil, fmt.Errorf("rolling back needs the release history")
+	}
+
+	var target *Release
+	var err error
+	if to != 0 {
+		if target, err = opts.Store.Get(to); err != nil {
+			return nil, err
+		}
+		if target.Status != StatusDeployed {
+			return nil, fmt.Errorf("release %d %s, only deployed releases can be applied again", to, target.Status)
+		}
+	} else if target, err = previousRelease(opts.Store, opts.Environment); err != nil {
+		return nil, err
+	}
+
+	release := newRelease(opts)
+	release.Environment = target.Environment
+	release.Services = target.Services
+	release.ConfigHash = target.ConfigHash
+	release.GitCommit = target.GitCommit
+	release.RollbackOf = target.ID
+
+	return release, apply(ctx, release, opts)
+}
+
+// previousRelease returns the release of env to roll back to. A failed deploy leaves the
+// apps rolled out before the failure on its version, so after a failure that is the
+// latest successful release; otherwise it is the successful release before the current
+// one.
+func previousRelease(store Store, env string) (*Release, error) {
+	releases, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *Release
+	var deployed []*Release
+	for _, release := range releases {
+		if release.Environment != env {
+			continue
+		}
+		latest = release
+		if release.Status == StatusDeployed {
+			deployed = append(deployed, release)
+		}
+	}
+
+	if latest != nil && latest.Status == StatusFailed && len(deployed) > 0 {
+		return deployed[len(deployed)-1], nil
+	}
+	if len(deployed) < 2 {
+		return nil, fmt.Errorf("no previous release to roll back to%s", envSuffix(env))
+	}
+	return deployed[len(deployed)-2], nil
+}
+
+// latestRelease returns the last release of env whatever its status, nil when there is
+// none. The apps rolled out before a failure keep their new version, so a failed release
+// describes the cluster as well as a successful one.
+func latestRelease(store Store, env string) (*Release, error) {
+	releases, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	for idx := len(releases) - 1; idx >= 0; idx-- {
+		if releases[idx].Environment == env {
+			return releases[idx], nil
+		}
+	}
+	return nil, nil
+}
+
+func envSuffix(env string) string {
+	if env == "" {
+		return ""
+	}
+	return " in " + env
+}
+
+func newRelease(opts Options) *Release {
+	return &Release{
+		Time:        time.Now().UTC(),
+		Environment: opts.Environment,
+		ConfigHash:  opts.ConfigHash,
+		GitCommit:   opts.GitCommit,
+		User:        opts.User,
+	}
+}
+
+// apply applies the services of release and saves it with its outcome.
+func apply(ctx context.Context, release *Release, opts Options) error {
+	out := opts.output()
+	var applyErr error
+	for _, svc := range release.Services {
+		if svc.Image == "" {
+			fmt.Fprintf(out, "🚀 Applying %s\n", svc.Name)
+		} else {
+			fmt.Fprintf(out, "🚀 Applying %s (%s)\n", svc.Name, svc.Image)
+		}
+		if err := opts.Cluster.Apply(ctx, svc.Namespace, []byte(svc.Manifests)); err != nil {
+			applyErr = fmt.Errorf("%s: %w", svc.Name, err)
+			break
+		}
+	}
+
+	return record(release, applyErr, opts)
+}
+
+// record saves release with the outcome of its deployment, and returns that outcome.
+func record(release *Release, deployErr error, opts Options) error {
+	release.Status = StatusDeployed
+	if deployErr != nil {
+		release.Status = StatusFailed
+		release.Error = deployErr.Error()
+	}
+	if opts.Store != nil {
+		if err := opts.Store.Save(release); err != nil {
+			if deployErr != nil {
+				return fmt.Errorf("%w (and the release could not be recorded: %v)", deployErr, err)
+			}
+			return err
+		}
+	}
+	return deployErr
+}
+
+// ConfigHash returns a hash of the resolved configuration of the project, so releases
+// deployed with the same settings have the same hash.
+func ConfigHash(project *configProject.Project) (string, error) {
+	var resolved []interface{}
+	if project.IsMicroservice() {
+		resolved = append(resolved, project.Root)
+		for _, svc := range project.Services {
+			resolved = append(resolved, svc.Effective)
+		}
+	} else {
+		resolved = append(resolved, project.Monolith)
+	}
+
+	hash := sha256.New()
+	for _, config := range resolved {
+		data, err := yaml.Marshal(config)
+		if err != nil {
+			return "", err
+		}
+		hash.Write(data)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// GitCommit returns the commit checked out in dir, suffixed with "-dirty" when there are
+// uncommitted changes, empty outside a git repository.
+func GitCommit(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	commit := strings.TrimSpace(string(out))
+	if status, err := exec.Command("git", "-C", dir, "status", "--porcelain").Output(); err == nil && len(bytes.TrimSpace(status)) > 0 {
+		commit += "-dirty"
+	}
+	return commit
+}
+
+// CurrentUser returns who deploys: $DAAB_USER, or the login of the current user.
+func CurrentUser() string {
+	if name := os.Getenv("DAAB_USER"); name != "" {
+		return name
+	}
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,473 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mouad4949/DAAB/pkg/config"
+	"github.com/mouad4949/DAAB/pkg/generate"
+	"gopkg.in/yaml.v3"
+)
+
+// health makes the Deployments named in unhealthy fail their health gates.
+type health struct {
+	mu        sync.Mutex
+	unhealthy map[string]bool
+}
+
+func (h *health) fail(deployments ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unhealthy = map[string]bool{}
+	for _, deployment := range deployments {
+		h.unhealthy[deployment] = true
+	}
+}
+
+func (h *health) check(namespace, deployment string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.unhealthy[deployment] {
+		return fmt.Errorf("%s is crash looping", deployment)
+	}
+	return nil
+}
+
+func newTestOptions() (Options, *FakeCluster, *health) {
+	h := &health{}
+	cluster := &FakeCluster{Health: h.check}
+	opts := Options{
+		Cluster:     cluster,
+		Store:       &MemoryStore{},
+		Environment: "production",
+		Pause:       func(ctx context.Context, d time.Duration) error { return nil },
+	}
+	return opts, cluster, h
+}
+
+func newApp(name, version string, strategy *config.Strategy) *generate.App {
+	return &generate.App{
+		Name:           name,
+		Port:           8080,
+		HealthEndpoint: "/health",
+		Registry:       "ghcr.io/acme",
+		Reference:      "ghcr.io/acme/" + name + ":" + version,
+		Namespace:      "shop",
+		Strategy:       strategy,
+		Replicas:       4,
+	}
+}
+
+func apps(version string, names ...string) []*generate.App {
+	var result []*generate.App
+	for _, name := range names {
+		result = append(result, newApp(name, version, nil))
+	}
+	return result
+}
+
+// deployedImage returns the image of the current version of a Deployment, empty when
+// it does not exist.
+func deployedImage(t *testing.T, cluster *FakeCluster, deployment string) string {
+	t.Helper()
+	data, ok := cluster.Object("shop", "Deployment", deployment)
+	if !ok {
+		return ""
+	}
+	var object generate.Deployment
+	if err := yaml.Unmarshal(data, &object); err != nil {
+		t.Fatal(err)
+	}
+	return object.Spec.Template.Spec.Containers[0].Image
+}
+
+// serviceSlot returns the blue/green slot the Service of app selects.
+func serviceSlot(t *testing.T, cluster *FakeCluster, app string) string {
+	t.Helper()
+	data, ok := cluster.Object("shop", "Service", app)
+	if !ok {
+		t.Fatalf("no Service %s", app)
+	}
+	var object generate.Service
+	if err := yaml.Unmarshal(data, &object); err != nil {
+		t.Fatal(err)
+	}
+	return object.Spec.Selector[generate.SlotLabel]
+}
+
+func TestDeployRecordsReleases(t *testing.T) {
+	opts, cluster, _ := newTestOptions()
+	ctx := context.Background()
+
+	for i, version := range []string{"v1", "v2"} {
+		release, err := Deploy(ctx, apps(version, "api", "web"), opts)
+		if err != nil {
+			t.Fatalf("Deploy(%s): %v", version, err)
+		}
+		if release.ID != i+1 || release.Status != StatusDeployed || release.Environment != "production" {
+			t.Errorf("release = %d %s in %s, want %d deployed in production", release.ID, release.Status, release.Environment, i+1)
+		}
+		if len(release.Services) != 2 || release.Services[1].Image != "ghcr.io/acme/web:"+version {
+			t.Errorf("release services = %+v", release.Services)
+		}
+	}
+
+	if got := deployedImage(t, cluster, "api"); got != "ghcr.io/acme/api:v2" {
+		t.Errorf("api runs %s, want v2", got)
+	}
+	if _, ok := cluster.Object("", "Namespace", "shop"); !ok {
+		t.Error("the namespace was not created")
+	}
+	releases, _ := opts.Store.List()
+	if len(releases) != 2 {
+		t.Errorf("%d releases recorded, want 2", len(releases))
+	}
+}
+
+func TestDeployUndoesTheFailingApp(t *testing.T) {
+	opts, cluster, h := newTestOptions()
+	ctx := context.Background()
+
+	if _, err := Deploy(ctx, apps("v1", "api", "web"), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	h.fail("web")
+	release, err := Deploy(ctx, apps("v2", "api", "web", "worker"), opts)
+	if err == nil || !strings.Contains(err.Error(), "web") {
+		t.Fatalf("Deploy() error = %v, want web to fail", err)
+	}
+	if release.ID != 2 || release.Status != StatusFailed || release.Error == "" {
+		t.Errorf("release = %d %s %q, want 2 failed with its error", release.ID, release.Status, release.Error)
+	}
+
+	// api was rolled out before the failure, web is undone, worker is never deployed
+	want := map[string]string{"api": "ghcr.io/acme/api:v2", "web": "ghcr.io/acme/web:v1", "worker": ""}
+	for deployment, image := range want {
+		if got := deployedImage(t, cluster, deployment); got != image {
+			t.Errorf("%s runs %q, want %q", deployment, got, image)
+		}
+	}
+}
+
+func TestDeployDeletesANewUnhealthyApp(t *testing.T) {
+	opts, cluster, h := newTestOptions()
+
+	h.fail("api")
+	if _, err := Deploy(context.Background(), apps("v1", "api"), opts); err == nil {
+		t.Fatal("Deploy() succeeded, want api to fail")
+	}
+	if _, ok := cluster.Object("shop", "Deployment", "api"); ok {
+		t.Error("the unhealthy Deployment of a first deploy was kept")
+	}
+}
+
+func TestRollback(t *testing.T) {
+	opts, cluster, h := newTestOptions()
+	ctx := context.Background()
+
+	for _, version := range []string{"v1", "v2"} {
+		if _, err := Deploy(ctx, apps(version, "api"), opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	release, err := Rollback(ctx, 0, opts)
+	if err != nil {
+		t.Fatalf("Rollback(): %v", err)
+	}
+	if release.ID != 3 || release.RollbackOf != 1 || release.Status != StatusDeployed {
+		t.Errorf("rollback = %d of %d %s, want 3 of 1 deployed", release.ID, release.RollbackOf, release.Status)
+	}
+	if got := deployedImage(t, cluster, "api"); got != "ghcr.io/acme/api:v1" {
+		t.Errorf("api runs %s after the rollback, want v1", got)
+	}
+
+	// Rolling back again goes back to the release before the rollback
+	if release, err = Rollback(ctx, 0, opts); err != nil || release.RollbackOf != 2 {
+		t.Fatalf("second Rollback() = %+v, %v, want a rollback of 2", release, err)
+	}
+
+	// After a failed deploy, the last successful release is applied again
+	h.fail("web")
+	if _, err := Deploy(ctx, apps("v3", "api", "web"), opts); err == nil {
+		t.Fatal("Deploy(v3) succeeded, want web to fail")
+	}
+	if got := deployedImage(t, cluster, "api"); got != "ghcr.io/acme/api:v3" {
+		t.Fatalf("api runs %s after the failed deploy, want v3", got)
+	}
+	if release, err = Rollback(ctx, 0, opts); err != nil {
+		t.Fatalf("Rollback() after a failure: %v", err)
+	}
+	if release.RollbackOf != 4 {
+		t.Errorf("rollback after a failure applied release %d, want the last successful one, 4", release.RollbackOf)
+	}
+	if got := deployedImage(t, cluster, "api"); got != "ghcr.io/acme/api:v2" {
+		t.Errorf("api runs %s after the rollback, want v2", got)
+	}
+}
+
+func TestRollbackAfterServiceDeploys(t *testing.T) {
+	opts, cluster, _ := newTestOptions()
+	ctx := context.Background()
+
+	// daab deploy, then daab deploy --service api, then daab deploy --service web
+	if _, err := Deploy(ctx, apps("v1", "api", "web"), opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Deploy(ctx, apps("v2", "api"), opts); err != nil {
+		t.Fatal(err)
+	}
+	release, err := Deploy(ctx, apps("v2", "web"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The releases hold every service, in their deploy order
+	var images []string
+	for _, svc := range release.Services {
+		images = append(images, svc.Image)
+	}
+	if got := strings.Join(images, " "); got != "ghcr.io/acme/api:v2 ghcr.io/acme/web:v2" {
+		t.Errorf("release 3 services = %s, want api and web on v2", got)
+	}
+
+	// Release 2 deployed api only, rolling back to it restores web too
+	if release, err = Rollback(ctx, 0, opts); err != nil {
+		t.Fatalf("Rollback(): %v", err)
+	}
+	if release.RollbackOf != 2 {
+		t.Errorf("rollback of %d, want 2", release.RollbackOf)
+	}
+	want := map[string]string{"api": "ghcr.io/acme/api:v2", "web": "ghcr.io/acme/web:v1"}
+	for deployment, image := range want {
+		if got := deployedImage(t, cluster, deployment); got != image {
+			t.Errorf("%s runs %q after the rollback, want %q", deployment, got, image)
+		}
+	}
+}
+
+func TestCarryForward(t *testing.T) {
+	svc := func(name, image string) ReleaseService {
+		return ReleaseService{Name: name, Image: image}
+	}
+	tests := []struct {
+		name     string
+		previous []ReleaseService
+		deployed []ReleaseService
+		want     []ReleaseService
+	}{
+		{
+			name:     "first release",
+			deployed: []ReleaseService{svc("api", "v1")},
+			want:     []ReleaseService{svc("api", "v1")},
+		},
+		{
+			name:     "one service redeployed",
+			previous: []ReleaseService{svc("api", "v1"), svc("web", "v1"), svc(EntryPointService, "")},
+			deployed: []ReleaseService{svc("web", "v2")},
+			want:     []ReleaseService{svc("api", "v1"), svc("web", "v2"), svc(EntryPointService, "")},
+		},
+		{
+			name:     "new service before the entry point",
+			previous: []ReleaseService{svc("api", "v1"), svc(EntryPointService, "")},
+			deployed: []ReleaseService{svc("worker", "v1"), svc(EntryPointService, "")},
+			want:     []ReleaseService{svc("api", "v1"), svc("worker", "v1"), svc(EntryPointService, "")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := carryForward(tt.previous, tt.deployed)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("carryForward() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollbackTo(t *testing.T) {
+	opts, cluster, h := newTestOptions()
+	ctx := context.Background()
+
+	for _, version := range []string{"v1", "v2", "v3"} {
+		if _, err := Deploy(ctx, apps(version, "api"), opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.fail("api")
+	Deploy(ctx, apps("v4", "api"), opts)
+	h.fail()
+
+	release, err := Rollback(ctx, 1, opts)
+	if err != nil {
+		t.Fatalf("Rollback(1): %v", err)
+	}
+	if release.ID != 5 || release.RollbackOf != 1 {
+		t.Errorf("rollback = %d of %d, want 5 of 1", release.ID, release.RollbackOf)
+	}
+	if got := deployedImage(t, cluster, "api"); got != "ghcr.io/acme/api:v1" {
+		t.Errorf("api runs %s, want v1", got)
+	}
+
+	if _, err := Rollback(ctx, 4, opts); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("Rollback(4) of a failed release error = %v", err)
+	}
+	if _, err := Rollback(ctx, 42, opts); err == nil {
+		t.Error("Rollback(42) succeeded, want a not found error")
+	}
+}
+
+func TestRollbackWithoutPreviousRelease(t *testing.T) {
+	opts, _, _ := newTestOptions()
+	ctx := context.Background()
+
+	if _, err := Deploy(ctx, apps("v1", "api"), opts); err != nil {
+		t.Fatal(err)
+	}
+	// Releases of other environments are not candidates
+	staging := opts
+	staging.Environment = "staging"
+	if _, err := Deploy(ctx, apps("v2", "api"), staging); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Rollback(ctx, 0, opts); err == nil || !strings.Contains(err.Error(), "in production") {
+		t.Errorf("Rollback() error = %v, want no previous release in production", err)
+	}
+
+	opts.Store = nil
+	if _, err := Rollback(ctx, 0, opts); err == nil {
+		t.Error("Rollback() without a store succeeded")
+	}
+}
+
+func TestBlueGreen(t *testing.T) {
+	opts, cluster, h := newTestOptions()
+	ctx := context.Background()
+	strategy := &config.Strategy{Type: config.StrategyBlueGreen}
+
+	// A rolling Deployment of the app is replaced by the first slot
+	if _, err := Deploy(ctx, apps("v0", "api"), opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Deploy(ctx, []*generate.App{newApp("api", "v1", strategy)}, opts); err != nil {
+		t.Fatalf("Deploy(v1): %v", err)
+	}
+	if slot := serviceSlot(t, cluster, "api"); slot != generate.SlotBlue {
+		t.Errorf("traffic on %q, want blue", slot)
+	}
+	if got := deployedImage(t, cluster, "api"); got != "" {
+		t.Errorf("the rolling Deployment still runs %s", got)
+	}
+
+	release, err := Deploy(ctx, []*generate.App{newApp("api", "v2", strategy)}, opts)
+	if err != nil {
+		t.Fatalf("Deploy(v2): %v", err)
+	}
+	if slot := serviceSlot(t, cluster, "api"); slot != generate.SlotGreen {
+		t.Errorf("traffic on %q, want green", slot)
+	}
+	if got := deployedImage(t, cluster, "api-green"); got != "ghcr.io/acme/api:v2" {
+		t.Errorf("green runs %q, want v2", got)
+	}
+	if got := deployedImage(t, cluster, "api-blue"); got != "" {
+		t.Errorf("the previous slot still runs %s", got)
+	}
+	if release.Services[0].Strategy != config.StrategyBlueGreen {
+		t.Errorf("release strategy = %q", release.Services[0].Strategy)
+	}
+
+	// An unhealthy version never gets the traffic
+	h.fail("api-blue")
+	if _, err := Deploy(ctx, []*generate.App{newApp("api", "v3", strategy)}, opts); err == nil {
+		t.Fatal("Deploy(v3) succeeded, want the blue slot to fail")
+	}
+	if slot := serviceSlot(t, cluster, "api"); slot != generate.SlotGreen {
+		t.Errorf("traffic on %q after a failed switch, want green", slot)
+	}
+	if got := deployedImage(t, cluster, "api-blue"); got != "" {
+		t.Errorf("the unhealthy slot still runs %s", got)
+	}
+
+	// The previous slot is kept when asked
+	h.fail()
+	strategy = &config.Strategy{Type: config.StrategyBlueGreen, BlueGreen: &config.BlueGreenStrategy{KeepPrevious: true}}
+	if _, err := Deploy(ctx, []*generate.App{newApp("api", "v4", strategy)}, opts); err != nil {
+		t.Fatalf("Deploy(v4): %v", err)
+	}
+	if got := deployedImage(t, cluster, "api-green"); got != "ghcr.io/acme/api:v2" {
+		t.Errorf("the kept slot runs %q, want v2", got)
+	}
+}
+
+func TestCanary(t *testing.T) {
+	opts, cluster, h := newTestOptions()
+	ctx := context.Background()
+	var pauses []time.Duration
+	opts.Pause = func(ctx context.Context, d time.Duration) error {
+		pauses = append(pauses, d)
+		return nil
+	}
+	strategy := &config.Strategy{Type: config.StrategyCanary, Canary: &config.CanaryStrategy{Steps: []config.CanaryStep{
+		{Weight: 20, Pause: "1m"},
+		{Weight: 50, Pause: "2m"},
+		{Weight: 100},
+	}}}
+
+	// Without a running version, the first deploy is a rolling one
+	if _, err := Deploy(ctx, []*generate.App{newApp("api", "v1", strategy)}, opts); err != nil {
+		t.Fatalf("Deploy(v1): %v", err)
+	}
+	if len(pauses) != 0 {
+		t.Errorf("the first deploy paused %v", pauses)
+	}
+
+	if _, err := Deploy(ctx, []*generate.App{newApp("api", "v2", strategy)}, opts); err != nil {
+		t.Fatalf("Deploy(v2): %v", err)
+	}
+	if want := []time.Duration{time.Minute, 2 * time.Minute}; fmt.Sprint(pauses) != fmt.Sprint(want) {
+		t.Errorf("pauses = %v, want %v", pauses, want)
+	}
+	if got := deployedImage(t, cluster, "api"); got != "ghcr.io/acme/api:v2" {
+		t.Errorf("api runs %q after the promotion, want v2", got)
+	}
+	if got := deployedImage(t, cluster, "api-canary"); got != "" {
+		t.Errorf("the canary still runs %s after the promotion", got)
+	}
+	var canaries int
+	for _, applied := range cluster.Applied() {
+		if strings.Contains(string(applied.Manifests), "name: api-canary") {
+			canaries++
+		}
+	}
+	if canaries != 2 {
+		t.Errorf("%d canary steps applied, want 2", canaries)
+	}
+
+	// An unhealthy canary is removed and the stable version keeps running
+	h.fail("api-canary")
+	if _, err := Deploy(ctx, []*generate.App{newApp("api", "v3", strategy)}, opts); err == nil {
+		t.Fatal("Deploy(v3) succeeded, want the canary to fail")
+	}
+	if got := deployedImage(t, cluster, "api"); got != "ghcr.io/acme/api:v2" {
+		t.Errorf("api runs %q after the aborted canary, want v2", got)
+	}
+	if got := deployedImage(t, cluster, "api-canary"); got != "" {
+		t.Errorf("the aborted canary still runs %s", got)
+	}
+
+	// A canary stopped during a pause is aborted too
+	h.fail()
+	opts.Pause = func(ctx context.Context, d time.Duration) error { return context.Canceled }
+	if _, err := Deploy(ctx, []*generate.App{newApp("api", "v4", strategy)}, opts); err == nil {
+		t.Fatal("Deploy(v4) succeeded, want the interrupted canary to fail")
+	}
+	if got := deployedImage(t, cluster, "api-canary"); got != "" {
+		t.Errorf("the interrupted canary still runs %s", got)
+	}
+}
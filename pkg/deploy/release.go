@@ -0,0 +1,188 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mouad4949/DAAB/internal/fsutil"
+	"gopkg.in/yaml.v3"
+)
+
+// Status of a release.
+const (
+	StatusDeployed = "deployed"
+	StatusFailed   = "failed"
+)
+
+// Release is one deployment of the project: what was applied, by whom, from which commit.
+type Release struct {
+	ID          int       `yaml:"id" json:"id"`
+	Time        time.Time `yaml:"time" json:"time"`
+	Environment string    `yaml:"environment,omitempty" json:"environment,omitempty"`
+	// Services are every service of the environment, not only the ones deployed
+	Services []ReleaseService `yaml:"services" json:"services"`
+	// ConfigHash identifies the resolved DAAB configuration that was deployed
+	ConfigHash string `yaml:"config_hash" json:"config_hash"`
+	GitCommit  string `yaml:"git_commit,omitempty" json:"git_commit,omitempty"`
+	User       string `yaml:"user" json:"user"`
+	Status     string `yaml:"status" json:"status"`
+	Error      string `yaml:"error,omitempty" json:"error,omitempty"`
+	// RollbackOf is the release applied again by this one, 0 for a regular deploy
+	RollbackOf int `yaml:"rollback_of,omitempty" json:"rollback_of,omitempty"`
+}
+
+// ReleaseService is a service of a release with the exact manifests applied for it.
+type ReleaseService struct {
+	Name      string `yaml:"name" json:"name"`
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	// Image is empty for the entry point
+	Image string `yaml:"image,omitempty" json:"image,omitempty"`
+	// Strategy is the deployment strategy used, rolling when empty
+	Strategy  string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+	Manifests string `yaml:"manifests" json:"-"`
+}
+
+// Store keeps the releases of a project.
+type Store interface {
+	// List returns every release, oldest first
+	List() ([]*Release, error)
+	Get(id int) (*Release, error)
+	// Save records a release, giving it the next ID when it has none
+	Save(release *Release) error
+}
+
+// FileStore keeps every release in its own YAML file, <id>.yaml, in Dir.
+type FileStore struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+// NewFileStore returns a store of releases in dir, created on the first save.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir}
+}
+
+// List returns the releases in the folder, oldest first.
+func (s *FileStore) List() ([]*Release, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read releases: %w", err)
+	}
+
+	var releases []*Release
+	for _, entry := range entries {
+		id, err := strconv.Atoi(strings.TrimSuffix(entry.Name(), ".yaml"))
+		if err != nil || entry.IsDir() || !strings.HasSuffix(entry.Name(), ".yaml") {
+			continue
+		}
+		release, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	sort.Slice(releases, func(i, j int) bool { return releases[i].ID < releases[j].ID })
+	return releases, nil
+}
+
+// Get reads the release with the id.
+func (s *FileStore) Get(id int) (*Release, error) {
+	path := s.path(id)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("release %d not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read release %d: %w", id, err)
+	}
+	release := &Release{}
+	if err := yaml.Unmarshal(data, release); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return release, nil
+}
+
+// Save writes the release, numbering it after the latest one when it has no id yet.
+func (s *FileStore) Save(release *Release) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if release.ID == 0 {
+		releases, err := s.List()
+		if err != nil {
+			return err
+		}
+		release.ID = 1
+		if len(releases) > 0 {
+			release.ID = releases[len(releases)-1].ID + 1
+		}
+	}
+
+	data, err := yaml.Marshal(release)
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFileAtomic(s.path(release.ID), data, 0644); err != nil {
+		return fmt.Errorf("failed to save release %d: %w", release.ID, err)
+	}
+	return nil
+}
+
+func (s *FileStore) path(id int) string {
+	return filepath.Join(s.Dir, fmt.Sprintf("%d.yaml", id))
+}
+
+// MemoryStore keeps the releases in memory, for tests.
+type MemoryStore struct {
+	mu       sync.Mutex
+	releases []*Release
+}
+
+// List returns the releases, oldest first.
+func (s *MemoryStore) List() ([]*Release, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Release(nil), s.releases...), nil
+}
+
+// Get returns the release with the id.
+func (s *MemoryStore) Get(id int) (*Release, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, release := range s.releases {
+		if release.ID == id {
+			return release, nil
+		}
+	}
+	return nil, fmt.Errorf("release %d not found", id)
+}
+
+// Save stores the release, numbering it after the latest one when it has no id yet.
+func (s *MemoryStore) Save(release *Release) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if release.ID == 0 {
+		release.ID = 1
+		if len(s.releases) > 0 {
+			release.ID = s.releases[len(s.releases)-1].ID + 1
+		}
+	}
+	for idx, existing := range s.releases {
+		if existing.ID == release.ID {
+			s.releases[idx] = release
+			return nil
+		}
+	}
+	s.releases = append(s.releases, release)
+	return nil
+}
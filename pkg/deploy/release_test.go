@@ -0,0 +1,46 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "releases")
+	store := NewFileStore(dir)
+
+	if releases, err := store.List(); err != nil || len(releases) != 0 {
+		t.Fatalf("List() of a new store = %v, %v", releases, err)
+	}
+	for _, env := range []string{"staging", "production"} {
+		if err := store.Save(&Release{Environment: env, Status: StatusDeployed}); err != nil {
+			t.Fatalf("Save(): %v", err)
+		}
+	}
+	// Files that are not releases are ignored
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	releases, err := store.List()
+	if err != nil {
+		t.Fatalf("List(): %v", err)
+	}
+	if len(releases) != 2 || releases[0].ID != 1 || releases[1].ID != 2 || releases[1].Environment != "production" {
+		t.Fatalf("List() = %+v, want releases 1 and 2", releases)
+	}
+
+	release, err := NewFileStore(dir).Get(2)
+	if err != nil || release.Environment != "production" {
+		t.Errorf("Get(2) = %+v, %v", release, err)
+	}
+	if _, err := store.Get(3); err == nil {
+		t.Error("Get(3) succeeded, want a not found error")
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Errorf("%d files in the store, want the 2 releases and notes.txt without temporary files", len(entries))
+	}
+}
@@ -0,0 +1,306 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mouad4949/DAAB/pkg/config"
+	"github.com/mouad4949/DAAB/pkg/generate"
+	"gopkg.in/yaml.v3"
+)
+
+// rollout deploys app with its strategy, waiting for the new version to be healthy at
+// every step, and aborts by restoring the previous version when it is not. It returns
+// the manifests describing app once deployed, recorded in the release.
+func rollout(ctx context.Context, app *generate.App, opts Options) ([]byte, error) {
+	out := opts.output()
+	if app.HealthEndpoint == "" {
+		fmt.Fprintf(out, "⚠️  %s has no health_endpoint, health gates only wait for the pods to run\n", app.Name)
+	}
+
+	switch app.Strategy.Kind() {
+	case config.StrategyBlueGreen:
+		return blueGreen(ctx, app, opts)
+	case config.StrategyCanary:
+		return canary(ctx, app, opts)
+	default:
+		return rolling(ctx, app, opts)
+	}
+}
+
+// rolling applies the manifests of app and lets Kubernetes replace the pods
+// progressively. When the new pods do not become ready, the Deployment is reverted.
+func rolling(ctx context.Context, app *generate.App, opts Options) ([]byte, error) {
+	out := opts.output()
+	manifests, err := Manifests(app)
+	if err != nil {
+		return nil, err
+	}
+	existed, err := exists(ctx, opts.Cluster, app.Namespace, "Deployment", app.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(out, "🚀 Rolling out %s (%s)\n", app.Name, app.ImageReference())
+	if err := opts.Cluster.Apply(ctx, app.Namespace, manifests); err != nil {
+		return nil, err
+	}
+	if err := waitReady(ctx, app, app.Name, opts); err != nil {
+		fmt.Fprintf(out, "❌ %s is not healthy, reverting\n", app.Name)
+		if existed {
+			err = withCleanup(err, opts.Cluster.Undo(ctx, app.Namespace, app.Name))
+		} else {
+			err = withCleanup(err, opts.Cluster.Delete(ctx, app.Namespace, "Deployment", app.Name))
+		}
+		return nil, err
+	}
+
+	// Leftovers of a previous blue/green or canary deployment
+	for _, name := range []string{
+		app.Name + "-" + generate.SlotBlue,
+		app.Name + "-" + generate.SlotGreen,
+		app.Name + "-" + generate.CanaryTrack,
+	} {
+		if err := opts.Cluster.Delete(ctx, app.Namespace, "Deployment", name); err != nil {
+			return nil, err
+		}
+	}
+	if err := deleteStaleAutoscaler(ctx, app, opts); err != nil {
+		return nil, err
+	}
+	return manifests, nil
+}
+
+// blueGreen starts the new version on the idle slot, switches the Service to it once it
+// is healthy, then stops the previous slot unless it is kept.
+func blueGreen(ctx context.Context, app *generate.App, opts Options) ([]byte, error) {
+	out := opts.output()
+	active, err := activeSlot(ctx, opts.Cluster, app)
+	if err != nil {
+		return nil, err
+	}
+	next := generate.SlotBlue
+	if active == generate.SlotBlue {
+		next = generate.SlotGreen
+	}
+
+	deployment := generate.NewSlotDeployment(app, next)
+	manifests, err := joinManifests(namespaceManifest(app), deployment)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(out, "🚀 Starting %s on the %s slot (%s)\n", app.Name, next, app.ImageReference())
+	if err := opts.Cluster.Apply(ctx, app.Namespace, manifests); err != nil {
+		return nil, err
+	}
+	if err := waitReady(ctx, app, deployment.Metadata.Name, opts); err != nil {
+		fmt.Fprintf(out, "❌ %s is not healthy on the %s slot, traffic stays on the current version\n", app.Name, next)
+		return nil, withCleanup(err, opts.Cluster.Delete(ctx, app.Namespace, "Deployment", deployment.Metadata.Name))
+	}
+
+	// The autoscaler follows the slot receiving the traffic
+	switched := []interface{}{generate.NewSlotService(app, next)}
+	if app.Autoscaling != nil {
+		switched = append(switched, generate.NewHorizontalPodAutoscaler(app, deployment.Metadata.Name))
+	}
+	service, err := joinManifests(switched...)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(out, "🔀 Switching %s to the %s slot\n", app.Name, next)
+	if err := opts.Cluster.Apply(ctx, app.Namespace, service); err != nil {
+		return nil, withCleanup(err, opts.Cluster.Delete(ctx, app.Namespace, "Deployment", deployment.Metadata.Name))
+	}
+
+	// The Deployment of a previous rolling or canary deployment no longer gets traffic
+	stale := []string{app.Name, app.Name + "-" + generate.CanaryTrack}
+	if active != "" && !(app.Strategy.BlueGreen != nil && app.Strategy.BlueGreen.KeepPrevious) {
+		stale = append(stale, app.Name+"-"+active)
+	}
+	for _, name := range stale {
+		if err := opts.Cluster.Delete(ctx, app.Namespace, "Deployment", name); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := deleteStaleAutoscaler(ctx, app, opts); err != nil {
+		return nil, err
+	}
+	return joinManifests(append([]interface{}{namespaceManifest(app), deployment}, switched...)...)
+}
+
+// canary runs the new version next to the stable one, with a growing share of the pods
+// and so of the traffic, checking its health at every step, then promotes it.
+func canary(ctx context.Context, app *generate.App, opts Options) ([]byte, error) {
+	out := opts.output()
+	existed, err := exists(ctx, opts.Cluster, app.Namespace, "Deployment", app.Name)
+	if err != nil {
+		return nil, err
+	}
+	if !existed {
+		fmt.Fprintf(out, "ℹ️  %s is not running yet, nothing to compare the canary with\n", app.Name)
+		return rolling(ctx, app, opts)
+	}
+
+	name := app.Name + "-" + generate.CanaryTrack
+	abort := func(err error) error {
+		fmt.Fprintf(out, "❌ Canary of %s is not healthy, aborting\n", app.Name)
+		return withCleanup(err, opts.Cluster.Delete(ctx, app.Namespace, "Deployment", name))
+	}
+
+	stable := generate.NewDeployment(app).Spec.Replicas
+	for _, step := range app.Strategy.Canary.Steps {
+		if step.Weight >= 100 {
+			break
+		}
+		replicas := canaryReplicas(stable, step.Weight)
+		manifests, err := joinManifests(generate.NewCanaryDeployment(app, replicas))
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(out, "🐤 Canary of %s at %d%% (%d pods next to %d)\n", app.Name, step.Weight, replicas, stable)
+		if err := opts.Cluster.Apply(ctx, app.Namespace, manifests); err != nil {
+			return nil, abort(err)
+		}
+		if err := waitReady(ctx, app, name, opts); err != nil {
+			return nil, abort(err)
+		}
+		if pause := step.PauseDuration(); pause > 0 {
+			fmt.Fprintf(out, "⏸️  Watching the canary of %s for %s\n", app.Name, pause)
+			if err := opts.pause(ctx, pause); err != nil {
+				return nil, abort(err)
+			}
+			// The canary must still be healthy after the pause
+			if err := waitReady(ctx, app, name, opts); err != nil {
+				return nil, abort(err)
+			}
+		}
+	}
+
+	manifests, err := Manifests(app)
+	if err != nil {
+		return nil, abort(err)
+	}
+	fmt.Fprintf(out, "🚀 Promoting the canary of %s\n", app.Name)
+	if err := opts.Cluster.Apply(ctx, app.Namespace, manifests); err != nil {
+		return nil, abort(err)
+	}
+	if err := waitReady(ctx, app, app.Name, opts); err != nil {
+		return nil, abort(withCleanup(err, opts.Cluster.Undo(ctx, app.Namespace, app.Name)))
+	}
+	if err := opts.Cluster.Delete(ctx, app.Namespace, "Deployment", name); err != nil {
+		return nil, err
+	}
+	if err := deleteStaleAutoscaler(ctx, app, opts); err != nil {
+		return nil, err
+	}
+	return manifests, nil
+}
+
+// deleteStaleAutoscaler removes the autoscaler of app once autoscaling is turned off, so
+// it no longer overrides the replicas.
+func deleteStaleAutoscaler(ctx context.Context, app *generate.App, opts Options) error {
+	if app.Autoscaling != nil {
+		return nil
+	}
+	return opts.Cluster.Delete(ctx, app.Namespace, "HorizontalPodAutoscaler", app.Name)
+}
+
+// canaryReplicas returns the pods of the canary so it gets about weight percent of the
+// traffic next to stable pods, at least one.
+func canaryReplicas(stable, weight int) int {
+	replicas := (stable*weight + (100 - weight) - 1) / (100 - weight)
+	return max(replicas, 1)
+}
+
+// waitReady is the health gate: it waits for the pods of a Deployment of app to be ready,
+// which includes passing the readiness probe on the health endpoint.
+func waitReady(ctx context.Context, app *generate.App, deployment string, opts Options) error {
+	if err := opts.Cluster.WaitReady(ctx, app.Namespace, deployment, app.Strategy.TimeoutDuration()); err != nil {
+		return fmt.Errorf("health check of %s failed: %w", deployment, err)
+	}
+	return nil
+}
+
+// activeSlot returns the slot the Service of app sends the traffic to, empty when app
+// is not deployed with blue/green yet.
+func activeSlot(ctx context.Context, cluster Cluster, app *generate.App) (string, error) {
+	data, err := cluster.Get(ctx, app.Namespace, "Service", app.Name)
+	if errors.Is(err, ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var service struct {
+		Spec struct {
+			Selector map[string]string `yaml:"selector"`
+		} `yaml:"spec"`
+	}
+	if err := yaml.Unmarshal(data, &service); err != nil {
+		return "", fmt.Errorf("invalid service %s: %w", app.Name, err)
+	}
+	return service.Spec.Selector[generate.SlotLabel], nil
+}
+
+func exists(ctx context.Context, cluster Cluster, namespace, kind, name string) (bool, error) {
+	_, err := cluster.Get(ctx, namespace, kind, name)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// namespaceManifest returns the Namespace of app, nil when it has none.
+func namespaceManifest(app *generate.App) interface{} {
+	if app.Namespace == "" {
+		return nil
+	}
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]string{"name": app.Namespace},
+	}
+}
+
+// joinManifests renders objects into one YAML stream, skipping nil ones.
+func joinManifests(objects ...interface{}) ([]byte, error) {
+	var stream bytes.Buffer
+	for _, object := range objects {
+		if object == nil {
+			continue
+		}
+		data, err := generate.MarshalManifest(object)
+		if err != nil {
+			return nil, err
+		}
+		if stream.Len() > 0 {
+			stream.WriteString("---\n")
+		}
+		stream.Write(data)
+	}
+	return stream.Bytes(), nil
+}
+
+// withCleanup adds to err the failure of cleaning up after it.
+func withCleanup(err, cleanupErr error) error {
+	if cleanupErr == nil {
+		return err
+	}
+	return fmt.Errorf("%w (and cleaning up failed: %v)", err, cleanupErr)
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,321 @@
+package detect
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	configMicroservice "github.com/mouad4949/DAAB/pkg/config/microservice"
+	"github.com/mouad4949/DAAB/pkg/graph"
+	"gopkg.in/yaml.v3"
+)
+
+// Service is a service of a project whose dependencies are inferred.
+type Service struct {
+	// Name is the DNS-safe name of the service
+	Name    string
+	Aliases []string
+	// Dir is the folder of the service
+	Dir string
+}
+
+// Dependency is a call from a service to another one, found in Source.
+type Dependency struct {
+	From string
+	To   string
+	// Source tells where the dependency was found, e.g. "USERS_API_URL in .env"
+	Source string
+}
+
+// Limits of the scan of the files of a service.
+const (
+	maxScannedFiles    = 2000
+	maxScannedFileSize = 256 << 10
+)
+
+// skippedDirs are folders of dependencies and build outputs, not written by the team.
+var skippedDirs = map[string]bool{
+	"node_modules": true, "vendor": true, "dist": true, "build": true, "target": true,
+	"bin": true, "obj": true, "__pycache__": true, "venv": true, ".venv": true,
+}
+
+// scannedExtensions are the source and configuration files read for references.
+var scannedExtensions = map[string]bool{
+	".go": true, ".js": true, ".mjs": true, ".cjs": true, ".ts": true, ".jsx": true, ".tsx": true,
+	".py": true, ".java": true, ".kt": true, ".rb": true, ".php": true, ".cs": true, ".rs": true,
+	".yaml": true, ".yml": true, ".json": true, ".toml": true, ".ini": true, ".properties": true,
+	".conf": true, ".env": true,
+}
+
+// composeFiles are the names of a Docker Compose file at the root of the project.
+var composeFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// InferDependencies guesses which services call which:
+//
+//   - depends_on in the Docker Compose file of the project, whose services are matched
+//     by their build context or their name;
+//   - environment variables named after another service, e.g. USERS_API_URL or
+//     USERS_API_HOST, in the files of a service or its Compose environment;
+//   - URLs with the name of another service as host, e.g. http://users-api:8080.
+//
+// Dependencies are returned in the order of services, one per pair with its first source.
+func InferDependencies(projectPath string, services []Service) ([]Dependency, error) {
+	matchers := make([]*referenceMatcher, len(services))
+	for idx, svc := range services {
+		matchers[idx] = newReferenceMatcher(svc)
+	}
+
+	found := map[[2]string]Dependency{}
+	add := func(dependency Dependency) {
+		key := [2]string{dependency.From, dependency.To}
+		if _, ok := found[key]; !ok && dependency.From != dependency.To {
+			found[key] = dependency
+		}
+	}
+
+	if err := inferFromCompose(projectPath, services, matchers, add); err != nil {
+		return nil, err
+	}
+	for _, svc := range services {
+		if err := inferFromFiles(svc, matchers, add); err != nil {
+			return nil, err
+		}
+	}
+
+	var dependencies []Dependency
+	for _, from := range services {
+		for _, to := range services {
+			if dependency, ok := found[[2]string{from.Name, to.Name}]; ok {
+				dependencies = append(dependencies, dependency)
+			}
+		}
+	}
+	return dependencies, nil
+}
+
+// InferEdges returns the dependencies found by InferDependencies between the services
+// of daab.root.yaml, as edges of their dependency graph.
+func InferEdges(projectPath string, refs []configMicroservice.ServiceRef) ([]graph.Edge, error) {
+	services := make([]Service, 0, len(refs))
+	for _, ref := range refs {
+		services = append(services, Service{
+			Name:    ref.Name,
+			Aliases: ref.Aliases,
+			Dir:     filepath.Join(projectPath, filepath.FromSlash(ref.Path)),
+		})
+	}
+
+	inferred, err := InferDependencies(projectPath, services)
+	if err != nil {
+		return nil, err
+	}
+	edges := make([]graph.Edge, 0, len(inferred))
+	for _, dependency := range inferred {
+		edges = append(edges, graph.Edge{From: dependency.From, To: dependency.To, Source: dependency.Source})
+	}
+	return edges, nil
+}
+
+// referenceMatcher finds references to a service in text.
+type referenceMatcher struct {
+	service string
+	pattern *regexp.Regexp
+}
+
+func newReferenceMatcher(svc Service) *referenceMatcher {
+	var envNames, hosts []string
+	for _, name := range append([]string{svc.Name}, svc.Aliases...) {
+		envNames = append(envNames, regexp.QuoteMeta(strings.ToUpper(strings.ReplaceAll(name, "-", "_"))))
+		hosts = append(hosts, regexp.QuoteMeta(name))
+	}
+	envs, urls := strings.Join(envNames, "|"), strings.Join(hosts, "|")
+	return &referenceMatcher{
+		service: svc.Name,
+		pattern: regexp.MustCompile(
+			`\b(?:` + envs + `)_(?:BASE_)?(?:URL|URI|HOST|ADDR|ADDRESS|ENDPOINT|SERVICE_URL|SERVICE_HOST)\b` +
+				`|(?:https?|grpcs?)://(?:` + urls + `)(?:[:/"'\s]|$)`),
+	}
+}
+
+// find returns the first reference in text, empty when there is none.
+func (m *referenceMatcher) find(text string) string {
+	return strings.TrimRight(m.pattern.FindString(text), `:/"' `+"\t\n")
+}
+
+// inferFromFiles scans the source and configuration files of svc.
+func inferFromFiles(svc Service, matchers []*referenceMatcher, add func(Dependency)) error {
+	scanned := 0
+	return filepath.WalkDir(svc.Dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			if path != svc.Dir && (strings.HasPrefix(entry.Name(), ".") || skippedDirs[entry.Name()]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isScanned(entry.Name()) || scanned >= maxScannedFiles {
+			return nil
+		}
+		if info, err := entry.Info(); err != nil || info.Size() > maxScannedFileSize {
+			return nil
+		}
+		scanned++
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(svc.Dir, path)
+		for _, matcher := range matchers {
+			if matcher.service == svc.Name {
+				continue
+			}
+			if reference := matcher.find(string(content)); reference != "" {
+				add(Dependency{From: svc.Name, To: matcher.service, Source: fmt.Sprintf("%s in %s", reference, filepath.ToSlash(rel))})
+			}
+		}
+		return nil
+	})
+}
+
+func isScanned(name string) bool {
+	if name == ".env" || strings.HasPrefix(name, ".env.") {
+		return true
+	}
+	return scannedExtensions[filepath.Ext(name)]
+}
+
+// composeService is the part of a Docker Compose service used to infer dependencies.
+type composeService struct {
+	Build       yaml.Node `yaml:"build"`
+	DependsOn   yaml.Node `yaml:"depends_on"`
+	Environment yaml.Node `yaml:"environment"`
+}
+
+// inferFromCompose reads depends_on and environment of the Compose file of the project.
+func inferFromCompose(projectPath string, services []Service, matchers []*referenceMatcher, add func(Dependency)) error {
+	var file string
+	for _, name := range composeFiles {
+		if path := filepath.Join(projectPath, name); fileExists(path) {
+			file = path
+			break
+		}
+	}
+	if file == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var compose struct {
+		Services map[string]composeService `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(content, &compose); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(file), err)
+	}
+
+	// Compose service name -> DAAB service name
+	resolved := map[string]string{}
+	for name, svc := range compose.Services {
+		if target := matchComposeService(projectPath, name, svc, services); target != "" {
+			resolved[name] = target
+		}
+	}
+
+	source := filepath.Base(file)
+	for name, svc := range compose.Services {
+		from, ok := resolved[name]
+		if !ok {
+			continue
+		}
+		for _, dependency := range composeDependsOn(svc.DependsOn) {
+			if to, ok := resolved[dependency]; ok {
+				add(Dependency{From: from, To: to, Source: "depends_on in " + source})
+			}
+		}
+		for _, variable := range composeEnvironment(svc.Environment) {
+			for _, matcher := range matchers {
+				if matcher.service == from {
+					continue
+				}
+				if reference := matcher.find(variable); reference != "" {
+					add(Dependency{From: from, To: matcher.service, Source: fmt.Sprintf("%s in %s", reference, source)})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matchComposeService returns the service built from the context of a Compose service,
+// or named like it.
+func matchComposeService(projectPath, name string, compose composeService, services []Service) string {
+	context := compose.Build.Value
+	if compose.Build.Kind == yaml.MappingNode {
+		var build struct {
+			Context string `yaml:"context"`
+		}
+		if compose.Build.Decode(&build) == nil {
+			context = build.Context
+		}
+	}
+	if context != "" {
+		contextDir, err := filepath.Abs(filepath.Join(projectPath, context))
+		if err == nil {
+			for _, svc := range services {
+				if dir, err := filepath.Abs(svc.Dir); err == nil && dir == contextDir {
+					return svc.Name
+				}
+			}
+		}
+	}
+
+	for _, svc := range services {
+		for _, candidate := range append([]string{svc.Name}, svc.Aliases...) {
+			if candidate == strings.ToLower(name) {
+				return svc.Name
+			}
+		}
+	}
+	return ""
+}
+
+// composeDependsOn reads depends_on, a list of names or a mapping keyed by name.
+func composeDependsOn(node yaml.Node) []string {
+	var names []string
+	switch node.Kind {
+	case yaml.SequenceNode:
+		_ = node.Decode(&names)
+	case yaml.MappingNode:
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			names = append(names, node.Content[idx].Value)
+		}
+	}
+	return names
+}
+
+// composeEnvironment reads environment, a list of KEY=VALUE or a mapping, as KEY=VALUE.
+func composeEnvironment(node yaml.Node) []string {
+	var variables []string
+	switch node.Kind {
+	case yaml.SequenceNode:
+		_ = node.Decode(&variables)
+	case yaml.MappingNode:
+		for idx := 0; idx+1 < len(node.Content); idx += 2 {
+			variables = append(variables, node.Content[idx].Value+"="+node.Content[idx+1].Value)
+		}
+	}
+	return variables
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
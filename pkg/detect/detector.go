@@ -1,4 +1,5 @@
-package initcmd
+// Package detect identifies the language and framework of a project from the files in its folder.
+package detect
 
 import (
 	"encoding/json"
@@ -6,24 +7,31 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
+// DetectionResult is the language and framework found in a project folder.
 type DetectionResult struct {
-	Language      string
-	Framework     string
-	DetectedFiles []string
+	Language  string `json:"language" yaml:"language"`
+	Framework string `json:"framework" yaml:"framework"`
+	// Files that the detection is based on
+	DetectedFiles []string `json:"detected_files" yaml:"detected_files"`
 }
 
+// Detector identifies the language and framework of the project in one folder.
 type Detector struct {
 	projectPath string
 }
 
+// NewDetector returns a Detector for the project at projectPath.
 func NewDetector(projectPath string) *Detector {
 	return &Detector{
 		projectPath: projectPath,
 	}
 }
 
+// Detect runs the language checks, most specific first, and returns the first match or an
+// error when none matches.
 func (d *Detector) Detect() (*DetectionResult, error) {
 	result := &DetectionResult{
 		DetectedFiles: []string{},
@@ -39,6 +47,7 @@ func (d *Detector) Detect() (*DetectionResult, error) {
 		d.detectPHP,
 		d.detectDotNet,
 		d.detectRust,
+		d.detectStatic,
 	}
 
 	for _, detector := range detectors {
@@ -50,6 +59,60 @@ func (d *Detector) Detect() (*DetectionResult, error) {
 	return nil, fmt.Errorf("could not detect project language. Please ensure you're in a valid project directory")
 }
 
+// CandidateFolders lists the direct, non-hidden subfolders of root that may hold a microservice.
+func CandidateFolders(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var folders []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			folders = append(folders, filepath.Join(root, entry.Name()))
+		}
+	}
+	return folders, nil
+}
+
+// FolderDetection is the outcome of detecting one folder with DetectAll.
+type FolderDetection struct {
+	Path   string
+	Result *DetectionResult
+	Err    error
+}
+
+// DetectAll runs detection for every path with at most workers detections at a time.
+// Results are returned in the order of paths.
+func DetectAll(paths []string, workers int) []FolderDetection {
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]FolderDetection, len(paths))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(paths)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				result, err := NewDetector(paths[idx]).Detect()
+				results[idx] = FolderDetection{Path: paths[idx], Result: result, Err: err}
+			}
+		}()
+	}
+
+	for idx := range paths {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
 func (d *Detector) detectNodeJS(result *DetectionResult) bool {
 	packageJSON := filepath.Join(d.projectPath, "package.json")
 	if !d.fileExists(packageJSON) {
@@ -219,6 +282,38 @@ func (d *Detector) detectRust(result *DetectionResult) bool {
 	return false
 }
 
+// detectStatic matches plain static sites, checked last since most frameworks also ship
+// an index.html.
+func (d *Detector) detectStatic(result *DetectionResult) bool {
+	if d.fileExists(filepath.Join(d.projectPath, "index.html")) {
+		result.Language = "static"
+		result.DetectedFiles = append(result.DetectedFiles, "index.html")
+		return true
+	}
+	return false
+}
+
+// DefaultPort returns the port applications of language usually listen on.
+func DefaultPort(language string) int {
+	// Default ports based on language/framework
+	portMap := map[string]int{
+		"nodejs": 3000,
+		"go":     8080,
+		"python": 8000,
+		"java":   8080,
+		"ruby":   3000,
+		"php":    8080,
+		"dotnet": 5000,
+		"rust":   8080,
+		"static": 8080,
+	}
+
+	if port, ok := portMap[language]; ok {
+		return port
+	}
+	return 8080
+}
+
 // Helper functions
 func (d *Detector) fileExists(path string) bool {
 	_, err := os.Stat(path)
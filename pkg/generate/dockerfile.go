@@ -0,0 +1,288 @@
+package generate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	config "github.com/mouad4949/DAAB/pkg/config"
+)
+
+// Dockerfile generates a Dockerfile and a .dockerignore for the language of the application.
+type Dockerfile struct{}
+
+// Name returns "dockerfile".
+func (d *Dockerfile) Name() string {
+	return "dockerfile"
+}
+
+// Generate renders the Dockerfile template of the language of app.
+func (d *Dockerfile) Generate(app *App) ([]File, error) {
+	source, ok := dockerfileTemplates[app.Language]
+	if !ok {
+		return nil, fmt.Errorf("no Dockerfile template for language %q", app.Language)
+	}
+
+	tmpl, err := template.New(app.Language).Funcs(template.FuncMap{"exec": execForm}).Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, dockerfileData{App: app, Build: d.buildCommand(app), Start: d.startCommand(app)}); err != nil {
+		return nil, err
+	}
+	content := buf.Bytes()
+	if config.TargetOrDefault(app.Target) == config.TargetLambda {
+		content = withLambdaAdapter(content, app)
+	}
+
+	return []File{
+		{Path: DockerfilePath, Content: content},
+		{Path: DockerignorePath, Content: []byte(dockerignore)},
+	}, nil
+}
+
+type dockerfileData struct {
+	*App
+	Build string
+	Start string
+}
+
+// buildCommand returns the configured build command or the usual one for the language.
+func (d *Dockerfile) buildCommand(app *App) string {
+	if app.BuildCommand != "" {
+		return app.BuildCommand
+	}
+	switch app.Language {
+	case "nodejs":
+		if app.Framework == "nextjs" || app.Framework == "react" || app.Framework == "vue" {
+			return "npm run build"
+		}
+	case "go":
+		return "CGO_ENABLED=0 go build -o /out/app ."
+	case "java":
+		if app.Framework == "gradle" {
+			return "./gradlew build -x test"
+		}
+		return "mvn -B package -DskipTests"
+	case "dotnet":
+		return "dotnet publish -c Release -o /out"
+	case "rust":
+		return "cargo build --release"
+	}
+	return ""
+}
+
+// startCommand returns the configured start command or the usual one for the language.
+func (d *Dockerfile) startCommand(app *App) string {
+	if app.StartCommand != "" {
+		return app.StartCommand
+	}
+	switch app.Language {
+	case "nodejs":
+		return "npm start"
+	case "python":
+		switch app.Framework {
+		case "flask":
+			return fmt.Sprintf("gunicorn --bind 0.0.0.0:%d app:app", app.Port)
+		case "django":
+			return fmt.Sprintf("python manage.py runserver 0.0.0.0:%d", app.Port)
+		case "fastapi":
+			return fmt.Sprintf("uvicorn main:app --host 0.0.0.0 --port %d", app.Port)
+		}
+		return "python main.py"
+	case "ruby":
+		if app.Framework == "rails" {
+			return fmt.Sprintf("bundle exec rails server -b 0.0.0.0 -p %d", app.Port)
+		}
+		return "bundle exec ruby app.rb"
+	case "php":
+		return fmt.Sprintf("php -S 0.0.0.0:%d -t public", app.Port)
+	}
+	return ""
+}
+
+// withLambdaAdapter adds the Lambda Web Adapter to the final stage of a Dockerfile, before
+// its EXPOSE instruction: as a Lambda extension, it turns the invocations of the function
+// into HTTP requests to the port of the application.
+func withLambdaAdapter(dockerfile []byte, app *App) []byte {
+	adapter := fmt.Sprintf("COPY --from=%s /lambda-adapter /opt/extensions/lambda-adapter\nENV AWS_LWA_PORT=%d\n", LambdaAdapterImage, app.Port)
+	if app.HealthEndpoint != "" {
+		adapter += fmt.Sprintf("ENV AWS_LWA_READINESS_CHECK_PATH=%s\n", app.HealthEndpoint)
+	}
+
+	expose := bytes.LastIndex(dockerfile, []byte("\nEXPOSE "))
+	if expose == -1 {
+		return append(dockerfile, adapter...)
+	}
+	var buf bytes.Buffer
+	buf.Write(dockerfile[:expose+1])
+	buf.WriteString(adapter)
+	buf.Write(dockerfile[expose+1:])
+	return buf.Bytes()
+}
+
+// execForm renders a command in the JSON exec form of CMD, which does not need a shell
+// in the image.
+func execForm(command string) (string, error) {
+	data, err := json.Marshal(strings.Fields(command))
+	return string(data), err
+}
+
+const dockerignore = `.git
+.init
+k8s
+cloudrun
+containerapp
+ecs
+.aws-sam
+Dockerfile
+.dockerignore
+node_modules
+__pycache__
+*.pyc
+target
+bin
+obj
+.env
+`
+
+var dockerfileTemplates = map[string]string{
+	"nodejs": `FROM node:20-alpine
+WORKDIR /app
+COPY package*.json ./
+RUN npm ci --omit=dev || npm install --omit=dev
+COPY . .
+{{- if .Build }}
+RUN {{ .Build }}
+{{- end }}
+ENV PORT={{ .Port }}
+EXPOSE {{ .Port }}
+CMD {{ .Start | exec }}
+`,
+	"go": `FROM golang:1.22-alpine AS build
+WORKDIR /src
+COPY go.* ./
+RUN go mod download
+COPY . .
+RUN {{ .Build }}
+
+FROM gcr.io/distroless/static-debian12
+COPY --from=build /out/app /app
+ENV PORT={{ .Port }}
+EXPOSE {{ .Port }}
+{{- if .Start }}
+CMD {{ .Start | exec }}
+{{- else }}
+ENTRYPOINT ["/app"]
+{{- end }}
+`,
+	"python": `FROM python:3.12-slim
+WORKDIR /app
+COPY . .
+RUN if [ -f requirements.txt ]; then pip install --no-cache-dir -r requirements.txt; \
+    elif [ -f pyproject.toml ] || [ -f setup.py ]; then pip install --no-cache-dir .; \
+    elif [ -f Pipfile ]; then pip install --no-cache-dir pipenv && pipenv install --system --deploy; fi
+{{- if .Build }}
+RUN {{ .Build }}
+{{- end }}
+ENV PORT={{ .Port }}
+EXPOSE {{ .Port }}
+CMD {{ .Start | exec }}
+`,
+	"java": `{{- if eq .Framework "gradle" }}FROM gradle:8-jdk21 AS build
+WORKDIR /src
+COPY . .
+RUN {{ .Build }}
+RUN find build/libs -name '*.jar' ! -name '*-plain.jar' -exec cp {} /app.jar \;
+{{- else }}FROM maven:3-eclipse-temurin-21 AS build
+WORKDIR /src
+COPY . .
+RUN {{ .Build }}
+RUN cp target/*.jar /app.jar
+{{- end }}
+
+FROM eclipse-temurin:21-jre
+COPY --from=build /app.jar /app.jar
+ENV PORT={{ .Port }}
+EXPOSE {{ .Port }}
+{{- if .Start }}
+CMD {{ .Start | exec }}
+{{- else }}
+ENTRYPOINT ["java", "-jar", "/app.jar"]
+{{- end }}
+`,
+	"ruby": `FROM ruby:3.3-slim
+WORKDIR /app
+COPY Gemfile* ./
+RUN bundle install
+COPY . .
+{{- if .Build }}
+RUN {{ .Build }}
+{{- end }}
+ENV PORT={{ .Port }}
+EXPOSE {{ .Port }}
+CMD {{ .Start | exec }}
+`,
+	"php": `FROM composer:2 AS deps
+WORKDIR /app
+COPY composer.* ./
+RUN composer install --no-dev --no-scripts --prefer-dist
+
+FROM php:8.3-cli
+WORKDIR /app
+COPY --from=deps /app/vendor ./vendor
+COPY . .
+{{- if .Build }}
+RUN {{ .Build }}
+{{- end }}
+ENV PORT={{ .Port }}
+EXPOSE {{ .Port }}
+CMD {{ .Start | exec }}
+`,
+	"dotnet": `FROM mcr.microsoft.com/dotnet/sdk:8.0 AS build
+WORKDIR /src
+COPY . .
+RUN {{ .Build }}
+
+FROM mcr.microsoft.com/dotnet/aspnet:8.0
+WORKDIR /app
+COPY --from=build /out .
+ENV ASPNETCORE_URLS=http://+:{{ .Port }}
+EXPOSE {{ .Port }}
+{{- if .Start }}
+CMD {{ .Start | exec }}
+{{- else }}
+ENTRYPOINT ["sh", "-c", "dotnet $(ls *.dll | head -n 1)"]
+{{- end }}
+`,
+	"rust": `FROM rust:1-slim AS build
+WORKDIR /src
+COPY . .
+RUN {{ .Build }}
+RUN find target/release -maxdepth 1 -type f -perm -u+x -exec cp {} /app \;
+
+FROM debian:bookworm-slim
+COPY --from=build /app /app
+ENV PORT={{ .Port }}
+EXPOSE {{ .Port }}
+{{- if .Start }}
+CMD {{ .Start | exec }}
+{{- else }}
+ENTRYPOINT ["/app"]
+{{- end }}
+`,
+	"static": `FROM busybox:1.36
+COPY . /www
+ENV PORT={{ .Port }}
+EXPOSE {{ .Port }}
+{{- if .Start }}
+CMD {{ .Start | exec }}
+{{- else }}
+CMD ["httpd", "-f", "-v", "-p", "{{ .Port }}", "-h", "/www"]
+{{- end }}
+`,
+}
@@ -0,0 +1,191 @@
+// Package generate renders the deployment artifacts of an application (Dockerfile,
+// Kubernetes manifests or the descriptors of a serverless target) from its DAAB
+// configuration.
+package generate
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	config "github.com/mouad4949/DAAB/pkg/config"
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+)
+
+// Paths of the generated artifacts, relative to the application folder.
+const (
+	DockerfilePath      = "Dockerfile"
+	DockerignorePath    = ".dockerignore"
+	DeploymentPath      = "k8s/deployment.yaml"
+	ServiceManifestPath = "k8s/service.yaml"
+	// HPAPath is only written for applications with autoscaling
+	HPAPath = "k8s/hpa.yaml"
+)
+
+// Artifacts lists every file the default generators write for every application, see
+// ArtifactsFor for the other targets.
+var Artifacts = []string{
+	DockerfilePath,
+	DockerignorePath,
+	DeploymentPath,
+	ServiceManifestPath,
+}
+
+// Header is the first line of every generated file, it tells generated files apart
+// from files written by hand.
+const Header = "# Generated by daab, changes are overwritten by 'daab generate'.\n"
+
+// IsGenerated reports whether content was written by a generator.
+func IsGenerated(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(Header))
+}
+
+// App is everything the generators need to know about one deployable application:
+// a monolith project or one microservice with its inherited settings resolved.
+type App struct {
+	// Name is the DNS-safe name used for the image and the Kubernetes resources
+	Name string
+	// Path is the folder of the application, generated files are relative to it
+	Path string
+
+	Language       string
+	Framework      string
+	Port           int
+	BuildCommand   string
+	StartCommand   string
+	HealthEndpoint string
+
+	// Registry the image is pushed to, empty for a local image
+	Registry string
+	// Reference is the image run by the manifests, Image() with DefaultImageTag when empty
+	Reference string
+	Namespace string
+	Resources *config.Resources
+	Labels    map[string]string
+	Strategy  *config.Strategy
+	// Replicas is the number of pods, 1 when 0
+	Replicas    int
+	Autoscaling *config.Autoscaling
+	// PathPrefix routes the requests starting with it from the entry point of the
+	// project to the application, empty when it is not exposed
+	PathPrefix string
+
+	// Target is where the application runs, kubernetes when empty
+	Target string
+	// Region and AccountID locate the application on the serverless targets
+	Region    string
+	AccountID string
+}
+
+// DesiredReplicas returns the number of pods the Deployment starts with: the minimum of
+// the autoscaling when set, else Replicas, at least 1.
+func (a *App) DesiredReplicas() int {
+	if a.Autoscaling != nil {
+		return max(a.Autoscaling.MinReplicas, 1)
+	}
+	return max(a.Replicas, 1)
+}
+
+// Image returns the image reference of the application, without tag.
+func (a *App) Image() string {
+	if a.Registry == "" {
+		return a.Name
+	}
+	return strings.TrimSuffix(a.Registry, "/") + "/" + a.Name
+}
+
+// ImageReference returns the image run by the manifests of the application.
+func (a *App) ImageReference() string {
+	if a.Reference != "" {
+		return a.Reference
+	}
+	return a.Image() + ":" + DefaultImageTag
+}
+
+// File is a generated file. Path is relative to the application folder.
+type File struct {
+	Path    string
+	Content []byte
+}
+
+// Generator renders some of the artifacts of an application.
+type Generator interface {
+	// Name identifies the generator, e.g. "dockerfile"
+	Name() string
+	Generate(app *App) ([]File, error)
+}
+
+// Default returns the generators used by 'daab generate' for the kubernetes target.
+func Default() []Generator {
+	return []Generator{
+		&Dockerfile{},
+		&Kubernetes{},
+	}
+}
+
+// Generate runs every generator for app and prefixes the files with Header.
+// Without generators, the generators of the target of app are used.
+func Generate(app *App, generators ...Generator) ([]File, error) {
+	if len(generators) == 0 {
+		generators = ForTarget(app.Target)
+	}
+
+	var files []File
+	for _, generator := range generators {
+		generated, err := generator.Generate(app)
+		if err != nil {
+			return nil, fmt.Errorf("%s generator: %w", generator.Name(), err)
+		}
+		for _, file := range generated {
+			file.Content = append([]byte(Header), file.Content...)
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// AppsFromProject returns the applications of a loaded project: the project itself for
+// a monolith, or every service with the settings inherited from daab.root.yaml.
+func AppsFromProject(project *configProject.Project) []*App {
+	if !project.IsMicroservice() {
+		monolith := project.Monolith
+		app := appFromConfig(config.DNSName(monolith.ProjectName), project.Path, &monolith.BaseConfigApp)
+		app.Namespace = monolith.Namespace
+		app.Region = monolith.Region
+		if monolith.Ingress != nil {
+			app.PathPrefix = "/"
+		}
+		return []*App{app}
+	}
+
+	apps := make([]*App, 0, len(project.Services))
+	for _, svc := range project.Services {
+		app := appFromConfig(svc.Name, svc.Path, &svc.Effective.BaseConfigApp)
+		app.Namespace = svc.Effective.Namespace
+		app.Labels = svc.Effective.Labels
+		app.PathPrefix = svc.Effective.PathPrefix
+		app.Region = svc.Effective.Region
+		apps = append(apps, app)
+	}
+	return apps
+}
+
+func appFromConfig(name, path string, cfg *config.BaseConfigApp) *App {
+	return &App{
+		Name:           name,
+		Path:           path,
+		Language:       cfg.Language,
+		Framework:      cfg.Framework,
+		Port:           cfg.Port,
+		BuildCommand:   cfg.BuildCommand,
+		StartCommand:   cfg.StartCommand,
+		HealthEndpoint: cfg.HealthEndpoint,
+		Registry:       cfg.ContainerRegistry,
+		Strategy:       cfg.Strategy,
+		Resources:      config.MergeResources(config.DefaultResources(cfg.Language), cfg.Resources),
+		Replicas:       cfg.Replicas,
+		Autoscaling:    cfg.Autoscaling,
+		Target:         cfg.Target,
+		AccountID:      cfg.AccountID,
+	}
+}
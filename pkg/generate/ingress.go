@@ -0,0 +1,374 @@
+package generate
+
+import (
+	"bytes"
+	"fmt"
+
+	config "github.com/mouad4949/DAAB/pkg/config"
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+)
+
+// Paths of the entry point, relative to the project folder.
+const (
+	IngressPath   = "k8s/ingress.yaml"
+	HTTPRoutePath = "k8s/httproute.yaml"
+)
+
+// CertManagerIssuerAnnotation asks cert-manager to issue the certificate of an Ingress
+// or a Gateway with a ClusterIssuer.
+const CertManagerIssuerAnnotation = "cert-manager.io/cluster-issuer"
+
+// EntryPoint is the single way into the project from outside the cluster: it routes
+// the requests to the applications by path prefix.
+type EntryPoint struct {
+	// Name of the Ingress, HTTPRoute and Gateway objects, the project name
+	Name string
+	// Path is the project folder, the entry point files are relative to it
+	Path string
+	// Namespace is the namespace of the project, where a Gateway is created
+	Namespace string
+	Ingress   *config.Ingress
+	// Apps are the routed applications, in order, each with a PathPrefix
+	Apps []*App
+}
+
+// EntryPointFromProject returns the entry point of a loaded project, nil when it has no
+// ingress or no application to route to.
+func EntryPointFromProject(project *configProject.Project) *EntryPoint {
+	entry := &EntryPoint{Path: project.Path}
+	if project.IsMicroservice() {
+		entry.Name = config.DNSName(project.Root.ProjectName)
+		entry.Namespace = project.Root.Namespace
+		entry.Ingress = project.Root.Ingress
+	} else {
+		entry.Name = config.DNSName(project.Monolith.ProjectName)
+		entry.Namespace = project.Monolith.Namespace
+		entry.Ingress = project.Monolith.Ingress
+	}
+	if entry.Ingress == nil {
+		return nil
+	}
+
+	for _, app := range AppsFromProject(project) {
+		if app.PathPrefix != "" {
+			entry.Apps = append(entry.Apps, app)
+		}
+	}
+	if len(entry.Apps) == 0 {
+		return nil
+	}
+	return entry
+}
+
+// Files renders the entry point: k8s/ingress.yaml, or k8s/httproute.yaml for the
+// Gateway API.
+func (e *EntryPoint) Files() ([]File, error) {
+	objects := e.Objects()
+	var content bytes.Buffer
+	content.WriteString(Header)
+	for idx, object := range objects {
+		data, err := MarshalManifest(object)
+		if err != nil {
+			return nil, err
+		}
+		if idx > 0 {
+			content.WriteString("---\n")
+		}
+		content.Write(data)
+	}
+
+	path := IngressPath
+	if e.Ingress.Kind() == config.IngressTypeGateway {
+		path = HTTPRoutePath
+	}
+	return []File{{Path: path, Content: content.Bytes()}}, nil
+}
+
+// Objects returns the Kubernetes objects of the entry point. Routes only reach services
+// of their own namespace, so there is one Ingress or HTTPRoute per namespace.
+func (e *EntryPoint) Objects() []interface{} {
+	var namespaces []string
+	byNamespace := map[string][]*App{}
+	for _, app := range e.Apps {
+		if _, ok := byNamespace[app.Namespace]; !ok {
+			namespaces = append(namespaces, app.Namespace)
+		}
+		byNamespace[app.Namespace] = append(byNamespace[app.Namespace], app)
+	}
+
+	var objects []interface{}
+	if e.Ingress.Kind() == config.IngressTypeGateway {
+		if e.Ingress.Gateway == nil {
+			objects = append(objects, e.gateway(len(namespaces) > 1))
+		}
+		for _, namespace := range namespaces {
+			objects = append(objects, e.httpRoute(namespace, byNamespace[namespace]))
+		}
+		return objects
+	}
+	for _, namespace := range namespaces {
+		objects = append(objects, e.ingress(namespace, byNamespace[namespace]))
+	}
+	return objects
+}
+
+func (e *EntryPoint) labels() map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       e.Name,
+		"app.kubernetes.io/managed-by": "daab",
+	}
+}
+
+func (e *EntryPoint) annotations() map[string]string {
+	if tls := e.Ingress.TLS; tls != nil && tls.Issuer != "" {
+		return map[string]string{CertManagerIssuerAnnotation: tls.Issuer}
+	}
+	return nil
+}
+
+// Ingress is the subset of networking.k8s.io/v1 Ingress written by the generator.
+type Ingress struct {
+	APIVersion string        `yaml:"apiVersion"`
+	Kind       string        `yaml:"kind"`
+	Metadata   AnnotatedMeta `yaml:"metadata"`
+	Spec       IngressSpec   `yaml:"spec"`
+}
+
+// AnnotatedMeta is ObjectMeta with annotations.
+type AnnotatedMeta struct {
+	Name        string            `yaml:"name,omitempty"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// IngressSpec is the spec of an Ingress.
+type IngressSpec struct {
+	IngressClassName string        `yaml:"ingressClassName,omitempty"`
+	TLS              []IngressTLS  `yaml:"tls,omitempty"`
+	Rules            []IngressRule `yaml:"rules"`
+}
+
+// IngressTLS is the certificate secret of hosts of an Ingress.
+type IngressTLS struct {
+	Hosts      []string `yaml:"hosts"`
+	SecretName string   `yaml:"secretName"`
+}
+
+// IngressRule routes the requests of a host, every host when empty.
+type IngressRule struct {
+	Host string               `yaml:"host,omitempty"`
+	HTTP HTTPIngressRuleValue `yaml:"http"`
+}
+
+// HTTPIngressRuleValue lists the paths of a rule.
+type HTTPIngressRuleValue struct {
+	Paths []HTTPIngressPath `yaml:"paths"`
+}
+
+// HTTPIngressPath sends the requests matching a path to a backend.
+type HTTPIngressPath struct {
+	Path     string         `yaml:"path"`
+	PathType string         `yaml:"pathType"`
+	Backend  IngressBackend `yaml:"backend"`
+}
+
+// IngressBackend is the Service receiving the requests of a path.
+type IngressBackend struct {
+	Service IngressServiceBackend `yaml:"service"`
+}
+
+// IngressServiceBackend is a Service and its port.
+type IngressServiceBackend struct {
+	Name string             `yaml:"name"`
+	Port ServiceBackendPort `yaml:"port"`
+}
+
+// ServiceBackendPort is a port of a Service, by number.
+type ServiceBackendPort struct {
+	Name string `yaml:"name"`
+}
+
+func (e *EntryPoint) ingress(namespace string, apps []*App) *Ingress {
+	var paths []HTTPIngressPath
+	for _, app := range apps {
+		paths = append(paths, HTTPIngressPath{
+			Path:     app.PathPrefix,
+			PathType: "Prefix",
+			Backend: IngressBackend{Service: IngressServiceBackend{
+				Name: app.Name,
+				Port: ServiceBackendPort{Name: "http"},
+			}},
+		})
+	}
+
+	ingress := &Ingress{
+		APIVersion: "networking.k8s.io/v1",
+		Kind:       "Ingress",
+		Metadata:   AnnotatedMeta{Name: e.Name, Namespace: namespace, Labels: e.labels(), Annotations: e.annotations()},
+		Spec:       IngressSpec{IngressClassName: e.Ingress.Class},
+	}
+	if len(e.Ingress.Hosts) == 0 {
+		ingress.Spec.Rules = []IngressRule{{HTTP: HTTPIngressRuleValue{Paths: paths}}}
+	}
+	for _, host := range e.Ingress.Hosts {
+		ingress.Spec.Rules = append(ingress.Spec.Rules, IngressRule{Host: host, HTTP: HTTPIngressRuleValue{Paths: paths}})
+	}
+	if tls := e.Ingress.TLS; tls != nil {
+		ingress.Spec.TLS = []IngressTLS{{Hosts: e.Ingress.Hosts, SecretName: tls.SecretName(e.Name)}}
+	}
+	return ingress
+}
+
+// Gateway is the subset of gateway.networking.k8s.io/v1 Gateway written by the generator.
+type Gateway struct {
+	APIVersion string        `yaml:"apiVersion"`
+	Kind       string        `yaml:"kind"`
+	Metadata   AnnotatedMeta `yaml:"metadata"`
+	Spec       GatewaySpec   `yaml:"spec"`
+}
+
+// GatewaySpec is the spec of a Gateway.
+type GatewaySpec struct {
+	GatewayClassName string     `yaml:"gatewayClassName"`
+	Listeners        []Listener `yaml:"listeners"`
+}
+
+// Listener is a port, protocol and host the Gateway accepts requests on.
+type Listener struct {
+	Name          string         `yaml:"name"`
+	Hostname      string         `yaml:"hostname,omitempty"`
+	Port          int            `yaml:"port"`
+	Protocol      string         `yaml:"protocol"`
+	TLS           *GatewayTLS    `yaml:"tls,omitempty"`
+	AllowedRoutes *AllowedRoutes `yaml:"allowedRoutes,omitempty"`
+}
+
+// GatewayTLS terminates TLS on a listener with certificate secrets.
+type GatewayTLS struct {
+	Mode            string            `yaml:"mode"`
+	CertificateRefs []SecretReference `yaml:"certificateRefs"`
+}
+
+// SecretReference names a Secret of the namespace of the Gateway.
+type SecretReference struct {
+	Name string `yaml:"name"`
+}
+
+// AllowedRoutes selects the routes that may attach to a listener.
+type AllowedRoutes struct {
+	Namespaces RouteNamespaces `yaml:"namespaces"`
+}
+
+// RouteNamespaces selects the namespaces of the routes that may attach.
+type RouteNamespaces struct {
+	From string `yaml:"from"`
+}
+
+// gateway returns the Gateway created for the project: an HTTP listener, and an HTTPS
+// listener per host with TLS.
+func (e *EntryPoint) gateway(crossNamespace bool) *Gateway {
+	var allowed *AllowedRoutes
+	if crossNamespace {
+		allowed = &AllowedRoutes{Namespaces: RouteNamespaces{From: "All"}}
+	}
+
+	listeners := []Listener{{Name: "http", Port: 80, Protocol: "HTTP", AllowedRoutes: allowed}}
+	if tls := e.Ingress.TLS; tls != nil {
+		for idx, host := range e.Ingress.Hosts {
+			listeners = append(listeners, Listener{
+				Name:     fmt.Sprintf("https-%d", idx),
+				Hostname: host,
+				Port:     443,
+				Protocol: "HTTPS",
+				TLS: &GatewayTLS{
+					Mode:            "Terminate",
+					CertificateRefs: []SecretReference{{Name: tls.SecretName(e.Name)}},
+				},
+				AllowedRoutes: allowed,
+			})
+		}
+	}
+
+	return &Gateway{
+		APIVersion: "gateway.networking.k8s.io/v1",
+		Kind:       "Gateway",
+		Metadata:   AnnotatedMeta{Name: e.Name, Namespace: e.Namespace, Labels: e.labels(), Annotations: e.annotations()},
+		Spec:       GatewaySpec{GatewayClassName: e.Ingress.Class, Listeners: listeners},
+	}
+}
+
+// HTTPRoute is the subset of gateway.networking.k8s.io/v1 HTTPRoute written by the
+// generator.
+type HTTPRoute struct {
+	APIVersion string        `yaml:"apiVersion"`
+	Kind       string        `yaml:"kind"`
+	Metadata   AnnotatedMeta `yaml:"metadata"`
+	Spec       HTTPRouteSpec `yaml:"spec"`
+}
+
+// HTTPRouteSpec is the spec of an HTTPRoute.
+type HTTPRouteSpec struct {
+	ParentRefs []ParentReference `yaml:"parentRefs"`
+	Hostnames  []string          `yaml:"hostnames,omitempty"`
+	Rules      []HTTPRouteRule   `yaml:"rules"`
+}
+
+// ParentReference is the Gateway an HTTPRoute attaches to.
+type ParentReference struct {
+	Name        string `yaml:"name"`
+	Namespace   string `yaml:"namespace,omitempty"`
+	SectionName string `yaml:"sectionName,omitempty"`
+}
+
+// HTTPRouteRule sends the requests matching it to backends.
+type HTTPRouteRule struct {
+	Matches     []HTTPRouteMatch `yaml:"matches"`
+	BackendRefs []HTTPBackendRef `yaml:"backendRefs"`
+}
+
+// HTTPRouteMatch matches requests by path.
+type HTTPRouteMatch struct {
+	Path HTTPPathMatch `yaml:"path"`
+}
+
+// HTTPPathMatch is a path and how it is compared.
+type HTTPPathMatch struct {
+	Type  string `yaml:"type"`
+	Value string `yaml:"value"`
+}
+
+// HTTPBackendRef is a Service and its port receiving the requests of a rule.
+type HTTPBackendRef struct {
+	Name string `yaml:"name"`
+	Port int    `yaml:"port"`
+}
+
+func (e *EntryPoint) httpRoute(namespace string, apps []*App) *HTTPRoute {
+	parent := ParentReference{Name: e.Name}
+	if e.Namespace != namespace {
+		parent.Namespace = e.Namespace
+	}
+	if gateway := e.Ingress.Gateway; gateway != nil {
+		parent = ParentReference{Name: gateway.Name, Namespace: gateway.Namespace, SectionName: gateway.SectionName}
+	}
+
+	var rules []HTTPRouteRule
+	for _, app := range apps {
+		rules = append(rules, HTTPRouteRule{
+			Matches:     []HTTPRouteMatch{{Path: HTTPPathMatch{Type: "PathPrefix", Value: app.PathPrefix}}},
+			BackendRefs: []HTTPBackendRef{{Name: app.Name, Port: 80}},
+		})
+	}
+
+	return &HTTPRoute{
+		APIVersion: "gateway.networking.k8s.io/v1",
+		Kind:       "HTTPRoute",
+		Metadata:   AnnotatedMeta{Name: e.Name, Namespace: namespace, Labels: e.labels()},
+		Spec: HTTPRouteSpec{
+			ParentRefs: []ParentReference{parent},
+			Hostnames:  e.Ingress.Hosts,
+			Rules:      rules,
+		},
+	}
+}
@@ -0,0 +1,307 @@
+package generate
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	config "github.com/mouad4949/DAAB/pkg/config"
+	configMonolith "github.com/mouad4949/DAAB/pkg/config/monolith"
+	configProject "github.com/mouad4949/DAAB/pkg/config/project"
+	"gopkg.in/yaml.v3"
+)
+
+// decodeFile returns the documents of a generated YAML file.
+func decodeFile(t *testing.T, file File) []yaml.Node {
+	t.Helper()
+	if !IsGenerated(file.Content) {
+		t.Errorf("%s has no generated header", file.Path)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(file.Content))
+	var documents []yaml.Node
+	for {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return documents
+		}
+		if err != nil {
+			t.Fatalf("%s is not valid YAML: %v", file.Path, err)
+		}
+		documents = append(documents, document)
+	}
+}
+
+// decodeObjects decodes every document of the file into out, a pointer to a slice of
+// the objects of kind, and returns the kinds of all the documents in order.
+func decodeObjects(t *testing.T, file File, kind string, out interface{}) []string {
+	t.Helper()
+	var kinds []string
+	slice := reflect.ValueOf(out).Elem()
+	for _, document := range decodeFile(t, file) {
+		var meta struct {
+			Kind string `yaml:"kind"`
+		}
+		if err := document.Decode(&meta); err != nil {
+			t.Fatal(err)
+		}
+		kinds = append(kinds, meta.Kind)
+		if meta.Kind != kind {
+			continue
+		}
+		object := reflect.New(slice.Type().Elem())
+		if err := document.Decode(object.Interface()); err != nil {
+			t.Fatal(err)
+		}
+		slice.Set(reflect.Append(slice, object.Elem()))
+	}
+	return kinds
+}
+
+func routedApps() []*App {
+	return []*App{
+		{Name: "users", Namespace: "shop", PathPrefix: "/users", Port: 8080},
+		{Name: "orders", Namespace: "shop", PathPrefix: "/orders", Port: 3000},
+	}
+}
+
+func singleFile(t *testing.T, entry *EntryPoint, path string) File {
+	t.Helper()
+	files, err := entry.Files()
+	if err != nil {
+		t.Fatalf("Files(): %v", err)
+	}
+	if len(files) != 1 || files[0].Path != path {
+		t.Fatalf("Files() = %v, want only %s", files, path)
+	}
+	return files[0]
+}
+
+func TestIngress(t *testing.T) {
+	tests := []struct {
+		name        string
+		ingress     *config.Ingress
+		wantHosts   []string
+		wantTLS     []IngressTLS
+		annotations map[string]string
+	}{
+		{
+			name:      "every host",
+			ingress:   &config.Ingress{Class: "nginx"},
+			wantHosts: []string{""},
+		},
+		{
+			name:      "a rule per host",
+			ingress:   &config.Ingress{Class: "nginx", Hosts: []string{"shop.example.com", "www.shop.example.com"}},
+			wantHosts: []string{"shop.example.com", "www.shop.example.com"},
+		},
+		{
+			name:        "tls issued by cert-manager",
+			ingress:     &config.Ingress{Class: "nginx", Hosts: []string{"shop.example.com"}, TLS: &config.TLS{Issuer: "letsencrypt"}},
+			wantHosts:   []string{"shop.example.com"},
+			wantTLS:     []IngressTLS{{Hosts: []string{"shop.example.com"}, SecretName: "shop-tls"}},
+			annotations: map[string]string{CertManagerIssuerAnnotation: "letsencrypt"},
+		},
+		{
+			name:      "tls from an existing secret",
+			ingress:   &config.Ingress{Class: "nginx", Hosts: []string{"shop.example.com"}, TLS: &config.TLS{Secret: "wildcard-cert"}},
+			wantHosts: []string{"shop.example.com"},
+			wantTLS:   []IngressTLS{{Hosts: []string{"shop.example.com"}, SecretName: "wildcard-cert"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &EntryPoint{Name: "shop", Namespace: "shop", Ingress: tt.ingress, Apps: routedApps()}
+			var ingresses []Ingress
+			kinds := decodeObjects(t, singleFile(t, entry, IngressPath), "Ingress", &ingresses)
+			if !reflect.DeepEqual(kinds, []string{"Ingress"}) {
+				t.Fatalf("%s holds %v, want a single Ingress", IngressPath, kinds)
+			}
+
+			ingress := ingresses[0]
+			if ingress.APIVersion != "networking.k8s.io/v1" || ingress.Metadata.Name != "shop" || ingress.Metadata.Namespace != "shop" {
+				t.Errorf("Ingress = %s %s/%s", ingress.APIVersion, ingress.Metadata.Namespace, ingress.Metadata.Name)
+			}
+			if ingress.Spec.IngressClassName != "nginx" {
+				t.Errorf("ingressClassName = %q, want nginx", ingress.Spec.IngressClassName)
+			}
+			if !reflect.DeepEqual(ingress.Metadata.Annotations, tt.annotations) {
+				t.Errorf("annotations = %v, want %v", ingress.Metadata.Annotations, tt.annotations)
+			}
+			if !reflect.DeepEqual(ingress.Spec.TLS, tt.wantTLS) {
+				t.Errorf("tls = %+v, want %+v", ingress.Spec.TLS, tt.wantTLS)
+			}
+
+			var hosts []string
+			for _, rule := range ingress.Spec.Rules {
+				hosts = append(hosts, rule.Host)
+				// Every host routes every application by its path prefix
+				want := []HTTPIngressPath{
+					{Path: "/users", PathType: "Prefix", Backend: IngressBackend{Service: IngressServiceBackend{Name: "users", Port: ServiceBackendPort{Name: "http"}}}},
+					{Path: "/orders", PathType: "Prefix", Backend: IngressBackend{Service: IngressServiceBackend{Name: "orders", Port: ServiceBackendPort{Name: "http"}}}},
+				}
+				if !reflect.DeepEqual(rule.HTTP.Paths, want) {
+					t.Errorf("paths of %q = %+v, want %+v", rule.Host, rule.HTTP.Paths, want)
+				}
+			}
+			if !reflect.DeepEqual(hosts, tt.wantHosts) {
+				t.Errorf("rule hosts = %q, want %q", hosts, tt.wantHosts)
+			}
+		})
+	}
+}
+
+func TestIngressPerNamespace(t *testing.T) {
+	apps := append(routedApps(), &App{Name: "billing", Namespace: "billing", PathPrefix: "/billing"})
+	entry := &EntryPoint{Name: "shop", Namespace: "shop", Ingress: &config.Ingress{}, Apps: apps}
+
+	var ingresses []Ingress
+	decodeObjects(t, singleFile(t, entry, IngressPath), "Ingress", &ingresses)
+	if len(ingresses) != 2 {
+		t.Fatalf("%d Ingresses, want one per namespace", len(ingresses))
+	}
+	// An Ingress only reaches the services of its namespace
+	for idx, want := range []string{"shop", "billing"} {
+		ingress := ingresses[idx]
+		if ingress.Metadata.Namespace != want {
+			t.Errorf("Ingress %d in %s, want %s", idx, ingress.Metadata.Namespace, want)
+		}
+		for _, path := range ingress.Spec.Rules[0].HTTP.Paths {
+			for _, app := range apps {
+				if app.Name == path.Backend.Service.Name && app.Namespace != want {
+					t.Errorf("the Ingress of %s routes to %s of %s", want, app.Name, app.Namespace)
+				}
+			}
+		}
+	}
+}
+
+func TestGateway(t *testing.T) {
+	entry := &EntryPoint{
+		Name:      "shop",
+		Namespace: "shop",
+		Ingress: &config.Ingress{
+			Type:  config.IngressTypeGateway,
+			Class: "istio",
+			Hosts: []string{"shop.example.com", "api.example.com"},
+			TLS:   &config.TLS{Issuer: "letsencrypt"},
+		},
+		Apps: routedApps(),
+	}
+	file := singleFile(t, entry, HTTPRoutePath)
+
+	var gateways []Gateway
+	kinds := decodeObjects(t, file, "Gateway", &gateways)
+	if !reflect.DeepEqual(kinds, []string{"Gateway", "HTTPRoute"}) {
+		t.Fatalf("%s holds %v, want a Gateway and an HTTPRoute", HTTPRoutePath, kinds)
+	}
+	gateway := gateways[0]
+	if gateway.Spec.GatewayClassName != "istio" || gateway.Metadata.Annotations[CertManagerIssuerAnnotation] != "letsencrypt" {
+		t.Errorf("Gateway class %q, annotations %v", gateway.Spec.GatewayClassName, gateway.Metadata.Annotations)
+	}
+	// An HTTP listener, and an HTTPS listener per host with the certificate
+	wantListeners := []Listener{
+		{Name: "http", Port: 80, Protocol: "HTTP"},
+		{Name: "https-0", Hostname: "shop.example.com", Port: 443, Protocol: "HTTPS", TLS: &GatewayTLS{Mode: "Terminate", CertificateRefs: []SecretReference{{Name: "shop-tls"}}}},
+		{Name: "https-1", Hostname: "api.example.com", Port: 443, Protocol: "HTTPS", TLS: &GatewayTLS{Mode: "Terminate", CertificateRefs: []SecretReference{{Name: "shop-tls"}}}},
+	}
+	if !reflect.DeepEqual(gateway.Spec.Listeners, wantListeners) {
+		t.Errorf("listeners = %+v, want %+v", gateway.Spec.Listeners, wantListeners)
+	}
+
+	var routes []HTTPRoute
+	decodeObjects(t, file, "HTTPRoute", &routes)
+	route := routes[0]
+	if !reflect.DeepEqual(route.Spec.ParentRefs, []ParentReference{{Name: "shop"}}) {
+		t.Errorf("parentRefs = %+v, want the Gateway of the project", route.Spec.ParentRefs)
+	}
+	if !reflect.DeepEqual(route.Spec.Hostnames, entry.Ingress.Hosts) {
+		t.Errorf("hostnames = %v, want %v", route.Spec.Hostnames, entry.Ingress.Hosts)
+	}
+	wantRules := []HTTPRouteRule{
+		{Matches: []HTTPRouteMatch{{Path: HTTPPathMatch{Type: "PathPrefix", Value: "/users"}}}, BackendRefs: []HTTPBackendRef{{Name: "users", Port: 80}}},
+		{Matches: []HTTPRouteMatch{{Path: HTTPPathMatch{Type: "PathPrefix", Value: "/orders"}}}, BackendRefs: []HTTPBackendRef{{Name: "orders", Port: 80}}},
+	}
+	if !reflect.DeepEqual(route.Spec.Rules, wantRules) {
+		t.Errorf("rules = %+v, want %+v", route.Spec.Rules, wantRules)
+	}
+}
+
+func TestGatewayAcrossNamespaces(t *testing.T) {
+	apps := append(routedApps(), &App{Name: "billing", Namespace: "billing", PathPrefix: "/billing"})
+	entry := &EntryPoint{
+		Name:      "shop",
+		Namespace: "shop",
+		Ingress:   &config.Ingress{Type: config.IngressTypeGateway, Class: "istio"},
+		Apps:      apps,
+	}
+	file := singleFile(t, entry, HTTPRoutePath)
+
+	var gateways []Gateway
+	decodeObjects(t, file, "Gateway", &gateways)
+	if allowed := gateways[0].Spec.Listeners[0].AllowedRoutes; allowed == nil || allowed.Namespaces.From != "All" {
+		t.Errorf("allowedRoutes = %+v, want the routes of every namespace", allowed)
+	}
+
+	var routes []HTTPRoute
+	decodeObjects(t, file, "HTTPRoute", &routes)
+	if len(routes) != 2 {
+		t.Fatalf("%d HTTPRoutes, want one per namespace", len(routes))
+	}
+	// The route of another namespace names the namespace of the Gateway
+	want := map[string]ParentReference{"shop": {Name: "shop"}, "billing": {Name: "shop", Namespace: "shop"}}
+	for _, route := range routes {
+		if got := route.Spec.ParentRefs[0]; got != want[route.Metadata.Namespace] {
+			t.Errorf("parentRef of the route in %s = %+v, want %+v", route.Metadata.Namespace, got, want[route.Metadata.Namespace])
+		}
+	}
+}
+
+func TestGatewayExisting(t *testing.T) {
+	entry := &EntryPoint{
+		Name:      "shop",
+		Namespace: "shop",
+		Ingress: &config.Ingress{
+			Type:    config.IngressTypeGateway,
+			Gateway: &config.GatewayRef{Name: "public", Namespace: "infra", SectionName: "https"},
+		},
+		Apps: routedApps(),
+	}
+
+	var routes []HTTPRoute
+	kinds := decodeObjects(t, singleFile(t, entry, HTTPRoutePath), "HTTPRoute", &routes)
+	if !reflect.DeepEqual(kinds, []string{"HTTPRoute"}) {
+		t.Fatalf("%s holds %v, want only an HTTPRoute attached to the existing Gateway", HTTPRoutePath, kinds)
+	}
+	want := ParentReference{Name: "public", Namespace: "infra", SectionName: "https"}
+	if got := routes[0].Spec.ParentRefs; !reflect.DeepEqual(got, []ParentReference{want}) {
+		t.Errorf("parentRefs = %+v, want %+v", got, want)
+	}
+}
+
+func TestEntryPointFromProject(t *testing.T) {
+	monolith := configMonolith.NewConfigMonolith()
+	monolith.ProjectName = "My Shop"
+	monolith.Namespace = "shop"
+	project := &configProject.Project{Path: "/src/shop", Monolith: monolith}
+
+	if entry := EntryPointFromProject(project); entry != nil {
+		t.Errorf("EntryPointFromProject() without ingress = %+v, want nil", entry)
+	}
+
+	monolith.Ingress = &config.Ingress{Hosts: []string{"shop.example.com"}}
+	entry := EntryPointFromProject(project)
+	if entry == nil {
+		t.Fatal("EntryPointFromProject() = nil, want the entry point of the monolith")
+	}
+	if entry.Name != "my-shop" || entry.Namespace != "shop" || entry.Path != "/src/shop" {
+		t.Errorf("entry point %s in %s at %s", entry.Name, entry.Namespace, entry.Path)
+	}
+	// The monolith is served at the root
+	if len(entry.Apps) != 1 || entry.Apps[0].PathPrefix != "/" {
+		t.Errorf("routed apps = %+v, want the monolith at /", entry.Apps)
+	}
+}
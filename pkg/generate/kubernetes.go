@@ -0,0 +1,425 @@
+package generate
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	config "github.com/mouad4949/DAAB/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultImageTag is the tag of the image referenced by the generated manifests, unless
+// the application has a Reference.
+const DefaultImageTag = "latest"
+
+// Kubernetes generates a Deployment and a Service exposing the port of the application.
+type Kubernetes struct{}
+
+// Name returns "kubernetes".
+func (k *Kubernetes) Name() string {
+	return "kubernetes"
+}
+
+// Generate writes the Deployment and the Service of app, and its autoscaler when it
+// scales automatically.
+func (k *Kubernetes) Generate(app *App) ([]File, error) {
+	if app.Name == "" {
+		return nil, fmt.Errorf("application name cannot be empty")
+	}
+	if app.Port <= 0 {
+		return nil, fmt.Errorf("%s: port must be set", app.Name)
+	}
+
+	// Blue/green applications start on the blue slot, 'daab deploy' switches slots
+	deploymentManifest, serviceManifest := NewDeployment(app), NewService(app)
+	if app.Strategy.Kind() == config.StrategyBlueGreen {
+		deploymentManifest, serviceManifest = NewSlotDeployment(app, SlotBlue), NewSlotService(app, SlotBlue)
+	}
+
+	deployment, err := MarshalManifest(deploymentManifest)
+	if err != nil {
+		return nil, err
+	}
+	service, err := MarshalManifest(serviceManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []File{
+		{Path: DeploymentPath, Content: deployment},
+		{Path: ServiceManifestPath, Content: service},
+	}
+	if app.Autoscaling != nil {
+		hpa, err := MarshalManifest(NewHorizontalPodAutoscaler(app, deploymentManifest.Metadata.Name))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Path: HPAPath, Content: hpa})
+	}
+	return files, nil
+}
+
+// ObjectMeta is the metadata of a Kubernetes object.
+type ObjectMeta struct {
+	Name      string            `yaml:"name,omitempty"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+// Deployment is the subset of apps/v1 Deployment written by the generator.
+type Deployment struct {
+	APIVersion string         `yaml:"apiVersion"`
+	Kind       string         `yaml:"kind"`
+	Metadata   ObjectMeta     `yaml:"metadata"`
+	Spec       DeploymentSpec `yaml:"spec"`
+}
+
+// DeploymentSpec is the spec of a Deployment.
+type DeploymentSpec struct {
+	Replicas int                 `yaml:"replicas"`
+	Selector LabelSelector       `yaml:"selector"`
+	Strategy *DeploymentStrategy `yaml:"strategy,omitempty"`
+	Template PodTemplateSpec     `yaml:"template"`
+}
+
+// DeploymentStrategy replaces the pods of a Deployment on updates.
+type DeploymentStrategy struct {
+	Type          string                   `yaml:"type"`
+	RollingUpdate *RollingUpdateDeployment `yaml:"rollingUpdate,omitempty"`
+}
+
+// RollingUpdateDeployment bounds the pods added and removed at each step of a rollout.
+type RollingUpdateDeployment struct {
+	MaxSurge       IntOrString `yaml:"maxSurge,omitempty"`
+	MaxUnavailable IntOrString `yaml:"maxUnavailable,omitempty"`
+}
+
+// IntOrString is a Kubernetes value written as a number ("1") or a string ("25%").
+type IntOrString string
+
+// MarshalYAML writes numbers unquoted.
+func (v IntOrString) MarshalYAML() (interface{}, error) {
+	if n, err := strconv.Atoi(string(v)); err == nil {
+		return n, nil
+	}
+	return string(v), nil
+}
+
+// IsZero reports whether the value is unset, for omitempty.
+func (v IntOrString) IsZero() bool {
+	return v == ""
+}
+
+// LabelSelector selects the objects with all the labels.
+type LabelSelector struct {
+	MatchLabels map[string]string `yaml:"matchLabels"`
+}
+
+// PodTemplateSpec is the template of the pods of a Deployment.
+type PodTemplateSpec struct {
+	Metadata ObjectMeta `yaml:"metadata"`
+	Spec     PodSpec    `yaml:"spec"`
+}
+
+// PodSpec is the spec of a pod.
+type PodSpec struct {
+	Containers []Container `yaml:"containers"`
+}
+
+// Container is a container of a pod.
+type Container struct {
+	Name           string            `yaml:"name"`
+	Image          string            `yaml:"image"`
+	Ports          []ContainerPort   `yaml:"ports,omitempty"`
+	Env            []EnvVar          `yaml:"env,omitempty"`
+	Resources      *config.Resources `yaml:"resources,omitempty"`
+	ReadinessProbe *Probe            `yaml:"readinessProbe,omitempty"`
+	LivenessProbe  *Probe            `yaml:"livenessProbe,omitempty"`
+}
+
+// ContainerPort is a port opened by a container.
+type ContainerPort struct {
+	Name          string `yaml:"name,omitempty"`
+	ContainerPort int    `yaml:"containerPort"`
+}
+
+// EnvVar is an environment variable of a container.
+type EnvVar struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// Probe checks the health of a container.
+type Probe struct {
+	HTTPGet             HTTPGetAction `yaml:"httpGet"`
+	InitialDelaySeconds int           `yaml:"initialDelaySeconds,omitempty"`
+	PeriodSeconds       int           `yaml:"periodSeconds,omitempty"`
+}
+
+// HTTPGetAction is a probe requesting a path of the container.
+type HTTPGetAction struct {
+	Path string `yaml:"path"`
+	Port string `yaml:"port"`
+}
+
+// Service is the subset of v1 Service written by the generator.
+type Service struct {
+	APIVersion string      `yaml:"apiVersion"`
+	Kind       string      `yaml:"kind"`
+	Metadata   ObjectMeta  `yaml:"metadata"`
+	Spec       ServiceSpec `yaml:"spec"`
+}
+
+// ServiceSpec is the spec of a Service.
+type ServiceSpec struct {
+	Type     string            `yaml:"type"`
+	Selector map[string]string `yaml:"selector"`
+	Ports    []ServicePort     `yaml:"ports"`
+}
+
+// ServicePort is a port of a Service and the container port it forwards to.
+type ServicePort struct {
+	Name       string `yaml:"name"`
+	Port       int    `yaml:"port"`
+	TargetPort string `yaml:"targetPort"`
+}
+
+// Labels returns the labels of the resources of app: the configured labels plus the
+// standard app.kubernetes.io labels.
+func Labels(app *App) map[string]string {
+	return config.MergeLabels(app.Labels, SelectorLabels(app))
+}
+
+// SelectorLabels returns the labels used to select the pods of app.
+func SelectorLabels(app *App) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       app.Name,
+		"app.kubernetes.io/managed-by": "daab",
+	}
+}
+
+// Labels telling apart the Deployments of the same application during blue/green and
+// canary deployments.
+const (
+	SlotLabel  = "daab.io/slot"
+	TrackLabel = "daab.io/track"
+)
+
+// Slots of blue/green deployments.
+const (
+	SlotBlue  = "blue"
+	SlotGreen = "green"
+)
+
+// CanaryTrack is the track of the canary Deployment.
+const CanaryTrack = "canary"
+
+// NewDeployment returns the Deployment of app, with the rolling update parameters of
+// its strategy.
+func NewDeployment(app *App) *Deployment {
+	container := Container{
+		Name:      app.Name,
+		Image:     app.ImageReference(),
+		Ports:     []ContainerPort{{Name: "http", ContainerPort: app.Port}},
+		Env:       []EnvVar{{Name: "PORT", Value: fmt.Sprint(app.Port)}},
+		Resources: app.Resources,
+	}
+	if app.HealthEndpoint != "" {
+		container.ReadinessProbe = &Probe{
+			HTTPGet:       HTTPGetAction{Path: app.HealthEndpoint, Port: "http"},
+			PeriodSeconds: 10,
+		}
+		container.LivenessProbe = &Probe{
+			HTTPGet:             HTTPGetAction{Path: app.HealthEndpoint, Port: "http"},
+			InitialDelaySeconds: 15,
+			PeriodSeconds:       20,
+		}
+	}
+
+	deployment := &Deployment{
+		APIVersion: "apps/v1",
+		Kind:       "Deployment",
+		Metadata:   ObjectMeta{Name: app.Name, Namespace: app.Namespace, Labels: Labels(app)},
+		Spec: DeploymentSpec{
+			Replicas: app.DesiredReplicas(),
+			Selector: LabelSelector{MatchLabels: SelectorLabels(app)},
+			Template: PodTemplateSpec{
+				Metadata: ObjectMeta{Labels: Labels(app)},
+				Spec:     PodSpec{Containers: []Container{container}},
+			},
+		},
+	}
+	if app.Strategy != nil && app.Strategy.Rolling != nil && app.Strategy.Kind() == config.StrategyRolling {
+		deployment.Spec.Strategy = &DeploymentStrategy{
+			Type: "RollingUpdate",
+			RollingUpdate: &RollingUpdateDeployment{
+				MaxSurge:       IntOrString(app.Strategy.Rolling.MaxSurge),
+				MaxUnavailable: IntOrString(app.Strategy.Rolling.MaxUnavailable),
+			},
+		}
+	}
+	return deployment
+}
+
+// NewSlotDeployment returns the Deployment of app for a blue/green slot, named
+// <name>-<slot> with the slot in its selector.
+func NewSlotDeployment(app *App, slot string) *Deployment {
+	return withExtraLabel(NewDeployment(app), app.Name+"-"+slot, SlotLabel, slot)
+}
+
+// NewSlotService returns the Service of app sending the traffic to a blue/green slot.
+func NewSlotService(app *App, slot string) *Service {
+	service := NewService(app)
+	service.Spec.Selector = config.MergeLabels(service.Spec.Selector, map[string]string{SlotLabel: slot})
+	return service
+}
+
+// NewCanaryDeployment returns the canary Deployment of app, <name>-canary, with replicas
+// pods. Its pods match the Service of app, so they receive a share of the traffic.
+func NewCanaryDeployment(app *App, replicas int) *Deployment {
+	deployment := withExtraLabel(NewDeployment(app), app.Name+"-"+CanaryTrack, TrackLabel, CanaryTrack)
+	deployment.Spec.Replicas = replicas
+	return deployment
+}
+
+// withExtraLabel renames deployment and adds a label to its pods and selector.
+func withExtraLabel(deployment *Deployment, name, key, value string) *Deployment {
+	extra := map[string]string{key: value}
+	deployment.Metadata.Name = name
+	deployment.Metadata.Labels = config.MergeLabels(deployment.Metadata.Labels, extra)
+	deployment.Spec.Selector.MatchLabels = config.MergeLabels(deployment.Spec.Selector.MatchLabels, extra)
+	deployment.Spec.Template.Metadata.Labels = config.MergeLabels(deployment.Spec.Template.Metadata.Labels, extra)
+	return deployment
+}
+
+// ServiceHTTPPort is the port of the Services, forwarded to the port of the application.
+const ServiceHTTPPort = 80
+
+// NewService returns the ClusterIP Service in front of the pods of app.
+func NewService(app *App) *Service {
+	return &Service{
+		APIVersion: "v1",
+		Kind:       "Service",
+		Metadata:   ObjectMeta{Name: app.Name, Namespace: app.Namespace, Labels: Labels(app)},
+		Spec: ServiceSpec{
+			Type:     "ClusterIP",
+			Selector: SelectorLabels(app),
+			Ports:    []ServicePort{{Name: "http", Port: ServiceHTTPPort, TargetPort: "http"}},
+		},
+	}
+}
+
+// HorizontalPodAutoscaler is the subset of autoscaling/v2 HorizontalPodAutoscaler
+// written by the generator.
+type HorizontalPodAutoscaler struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Metadata   ObjectMeta `yaml:"metadata"`
+	Spec       HPASpec    `yaml:"spec"`
+}
+
+// HPASpec is the spec of a HorizontalPodAutoscaler.
+type HPASpec struct {
+	ScaleTargetRef CrossVersionObjectReference `yaml:"scaleTargetRef"`
+	MinReplicas    int                         `yaml:"minReplicas"`
+	MaxReplicas    int                         `yaml:"maxReplicas"`
+	Metrics        []MetricSpec                `yaml:"metrics"`
+}
+
+// CrossVersionObjectReference names the object scaled by an autoscaler.
+type CrossVersionObjectReference struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Name       string `yaml:"name"`
+}
+
+// MetricSpec is a metric the autoscaler scales on.
+type MetricSpec struct {
+	Type     string                `yaml:"type"`
+	Resource *ResourceMetricSource `yaml:"resource,omitempty"`
+	Pods     *CustomMetricSource   `yaml:"pods,omitempty"`
+	External *CustomMetricSource   `yaml:"external,omitempty"`
+}
+
+// ResourceMetricSource is the CPU or memory usage of the pods.
+type ResourceMetricSource struct {
+	Name   string       `yaml:"name"`
+	Target MetricTarget `yaml:"target"`
+}
+
+// CustomMetricSource is a metric of the pods served by a metrics adapter.
+type CustomMetricSource struct {
+	Metric MetricIdentifier `yaml:"metric"`
+	Target MetricTarget     `yaml:"target"`
+}
+
+// MetricIdentifier names a custom metric.
+type MetricIdentifier struct {
+	Name string `yaml:"name"`
+}
+
+// MetricTarget is the value of a metric the autoscaler aims for.
+type MetricTarget struct {
+	Type               string `yaml:"type"`
+	AverageUtilization int    `yaml:"averageUtilization,omitempty"`
+	AverageValue       string `yaml:"averageValue,omitempty"`
+}
+
+// NewHorizontalPodAutoscaler returns the autoscaler of app, scaling the Deployment named
+// deployment.
+func NewHorizontalPodAutoscaler(app *App, deployment string) *HorizontalPodAutoscaler {
+	autoscaling := app.Autoscaling
+	var metrics []MetricSpec
+	for _, resource := range []struct {
+		name   string
+		target int
+	}{{"cpu", autoscaling.TargetCPU}, {"memory", autoscaling.TargetMemory}} {
+		if resource.target > 0 {
+			metrics = append(metrics, MetricSpec{
+				Type: "Resource",
+				Resource: &ResourceMetricSource{
+					Name:   resource.name,
+					Target: MetricTarget{Type: "Utilization", AverageUtilization: resource.target},
+				},
+			})
+		}
+	}
+	for _, metric := range autoscaling.Metrics {
+		source := &CustomMetricSource{
+			Metric: MetricIdentifier{Name: metric.Name},
+			Target: MetricTarget{Type: "AverageValue", AverageValue: metric.Target},
+		}
+		if metric.MetricType() == config.MetricExternal {
+			metrics = append(metrics, MetricSpec{Type: "External", External: source})
+		} else {
+			metrics = append(metrics, MetricSpec{Type: "Pods", Pods: source})
+		}
+	}
+
+	return &HorizontalPodAutoscaler{
+		APIVersion: "autoscaling/v2",
+		Kind:       "HorizontalPodAutoscaler",
+		Metadata:   ObjectMeta{Name: app.Name, Namespace: app.Namespace, Labels: Labels(app)},
+		Spec: HPASpec{
+			ScaleTargetRef: CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: deployment},
+			MinReplicas:    autoscaling.MinReplicas,
+			MaxReplicas:    autoscaling.MaxReplicas,
+			Metrics:        metrics,
+		},
+	}
+}
+
+// MarshalManifest renders a manifest the way the generator writes it.
+func MarshalManifest(manifest interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(manifest); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,617 @@
+package generate
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+
+	config "github.com/mouad4949/DAAB/pkg/config"
+)
+
+// Paths of the descriptors of the serverless targets, relative to the application folder.
+const (
+	CloudRunServicePath = "cloudrun/service.yaml"
+	SAMTemplatePath     = "template.yaml"
+	ContainerAppPath    = "containerapp/containerapp.yaml"
+	TaskDefinitionPath  = "ecs/task-definition.yaml"
+)
+
+// LambdaAdapterImage serves the HTTP server of the image as a Lambda function.
+const LambdaAdapterImage = "public.ecr.aws/awsguru/aws-lambda-adapter:0.8.4"
+
+// ForTarget returns the generators of the artifacts of an application deployed to
+// target: the Dockerfile and the descriptors of the target.
+func ForTarget(target string) []Generator {
+	switch config.TargetOrDefault(target) {
+	case config.TargetCloudRun:
+		return []Generator{&Dockerfile{}, &CloudRun{}}
+	case config.TargetLambda:
+		return []Generator{&Dockerfile{}, &Lambda{}}
+	case config.TargetContainerApps:
+		return []Generator{&Dockerfile{}, &ContainerApps{}}
+	case config.TargetECSFargate:
+		return []Generator{&Dockerfile{}, &ECSFargate{}}
+	}
+	return Default()
+}
+
+// ArtifactsFor lists the files the generators of target write for every application.
+func ArtifactsFor(target string) []string {
+	descriptors := map[string]string{
+		config.TargetCloudRun:      CloudRunServicePath,
+		config.TargetLambda:        SAMTemplatePath,
+		config.TargetContainerApps: ContainerAppPath,
+		config.TargetECSFargate:    TaskDefinitionPath,
+	}
+	if descriptor, ok := descriptors[config.TargetOrDefault(target)]; ok {
+		return []string{DockerfilePath, DockerignorePath, descriptor}
+	}
+	return Artifacts
+}
+
+// limits returns the CPU (cores) and memory (MiB) limits of app, its requests when a
+// limit is not set.
+func limits(app *App) (cpu float64, memory int) {
+	if app.Resources == nil {
+		return 0, 0
+	}
+	parse := func(limit, request string) float64 {
+		if limit == "" {
+			limit = request
+		}
+		value, _ := config.ParseQuantity(limit)
+		return value
+	}
+	cpu = parse(app.Resources.Limits.CPU, app.Resources.Requests.CPU)
+	memory = int(math.Ceil(parse(app.Resources.Limits.Memory, app.Resources.Requests.Memory) / (1 << 20)))
+	return cpu, memory
+}
+
+// scale returns the minimum and maximum number of instances of a serverless application:
+// the bounds of its autoscaling, else its replicas as the minimum. 0 means unbounded.
+func scale(app *App) (minimum, maximum int) {
+	if app.Autoscaling != nil {
+		return app.Autoscaling.MinReplicas, app.Autoscaling.MaxReplicas
+	}
+	if app.Replicas > 1 {
+		return app.Replicas, 0
+	}
+	return 0, 0
+}
+
+func checkApp(app *App) error {
+	if app.Name == "" {
+		return fmt.Errorf("application name cannot be empty")
+	}
+	if app.Port <= 0 {
+		return fmt.Errorf("%s: port must be set", app.Name)
+	}
+	return nil
+}
+
+/******************************************************/
+/************Cloud Run*********************************/
+/****************************************************/
+
+// CloudRun generates the Knative service of a Cloud Run service, deployed with
+// 'gcloud run services replace cloudrun/service.yaml'.
+type CloudRun struct{}
+
+// Name returns "cloud-run".
+func (c *CloudRun) Name() string {
+	return "cloud-run"
+}
+
+// CloudRunService is the subset of serving.knative.dev/v1 Service read by Cloud Run.
+type CloudRunService struct {
+	APIVersion string        `yaml:"apiVersion"`
+	Kind       string        `yaml:"kind"`
+	Metadata   AnnotatedMeta `yaml:"metadata"`
+	Spec       CloudRunSpec  `yaml:"spec"`
+}
+
+// CloudRunSpec is the spec of a Cloud Run service.
+type CloudRunSpec struct {
+	Template CloudRunTemplate `yaml:"template"`
+}
+
+// CloudRunTemplate is the template of the revisions of a Cloud Run service.
+type CloudRunTemplate struct {
+	Metadata AnnotatedMeta        `yaml:"metadata,omitempty"`
+	Spec     CloudRunRevisionSpec `yaml:"spec"`
+}
+
+// CloudRunRevisionSpec is the spec of a revision.
+type CloudRunRevisionSpec struct {
+	Containers []CloudRunContainer `yaml:"containers"`
+}
+
+// CloudRunContainer is the container of a revision.
+type CloudRunContainer struct {
+	Image         string                         `yaml:"image"`
+	Ports         []ContainerPort                `yaml:"ports"`
+	Resources     map[string]config.ResourceList `yaml:"resources,omitempty"`
+	StartupProbe  *CloudRunProbe                 `yaml:"startupProbe,omitempty"`
+	LivenessProbe *CloudRunProbe                 `yaml:"livenessProbe,omitempty"`
+}
+
+// CloudRunProbe is an HTTP probe on the container port, the only port of Cloud Run.
+type CloudRunProbe struct {
+	HTTPGet       CloudRunHTTPGet `yaml:"httpGet"`
+	PeriodSeconds int             `yaml:"periodSeconds,omitempty"`
+}
+
+// CloudRunHTTPGet is the path requested by a probe.
+type CloudRunHTTPGet struct {
+	Path string `yaml:"path"`
+}
+
+// Generate writes the Knative service of app.
+func (c *CloudRun) Generate(app *App) ([]File, error) {
+	if err := checkApp(app); err != nil {
+		return nil, err
+	}
+
+	container := CloudRunContainer{
+		Image: app.ImageReference(),
+		// Cloud Run sets PORT to the container port itself
+		Ports: []ContainerPort{{Name: "http1", ContainerPort: app.Port}},
+	}
+	if cpu, memory := limits(app); cpu > 0 && memory > 0 {
+		container.Resources = map[string]config.ResourceList{
+			"limits": {CPU: strconv.Itoa(cloudRunCPU(cpu, memory)), Memory: fmt.Sprintf("%dMi", max(memory, 128))},
+		}
+	}
+	if app.HealthEndpoint != "" {
+		container.StartupProbe = &CloudRunProbe{HTTPGet: CloudRunHTTPGet{Path: app.HealthEndpoint}, PeriodSeconds: 5}
+		container.LivenessProbe = &CloudRunProbe{HTTPGet: CloudRunHTTPGet{Path: app.HealthEndpoint}, PeriodSeconds: 20}
+	}
+
+	annotations := map[string]string{}
+	minimum, maximum := scale(app)
+	if minimum > 0 {
+		annotations["autoscaling.knative.dev/minScale"] = strconv.Itoa(minimum)
+	}
+	if maximum > 0 {
+		annotations["autoscaling.knative.dev/maxScale"] = strconv.Itoa(maximum)
+	}
+
+	service := &CloudRunService{
+		APIVersion: "serving.knative.dev/v1",
+		Kind:       "Service",
+		Metadata: AnnotatedMeta{
+			Name:        app.Name,
+			Labels:      cloudLabels(app),
+			Annotations: map[string]string{"run.googleapis.com/ingress": "all"},
+		},
+		Spec: CloudRunSpec{Template: CloudRunTemplate{
+			Metadata: AnnotatedMeta{Annotations: annotations},
+			Spec:     CloudRunRevisionSpec{Containers: []CloudRunContainer{container}},
+		}},
+	}
+	if len(annotations) == 0 {
+		service.Spec.Template.Metadata.Annotations = nil
+	}
+
+	content, err := MarshalManifest(service)
+	if err != nil {
+		return nil, err
+	}
+	return []File{{Path: CloudRunServicePath, Content: content}}, nil
+}
+
+// cloudRunCPU returns the whole number of CPUs of a Cloud Run container: fractional CPUs
+// limit the container to one request at a time, and memory above 4Gi needs more CPUs.
+func cloudRunCPU(cpu float64, memory int) int {
+	for _, step := range []struct{ cpu, memory int }{{1, 4096}, {2, 8192}, {4, 16384}, {8, 32768}} {
+		if cpu <= float64(step.cpu) && memory <= step.memory {
+			return step.cpu
+		}
+	}
+	return 8
+}
+
+/******************************************************/
+/************Lambda************************************/
+/****************************************************/
+
+// Lambda generates the AWS SAM template of a function running the image, behind an
+// HTTP API; deployed with 'sam deploy'. The Dockerfile adds the Lambda Web Adapter,
+// which forwards the invocations to the HTTP server of the application.
+type Lambda struct{}
+
+// Name returns "lambda".
+func (l *Lambda) Name() string {
+	return "lambda"
+}
+
+// SAMTemplate is the subset of an AWS SAM template written by the generator.
+type SAMTemplate struct {
+	AWSTemplateFormatVersion string                 `yaml:"AWSTemplateFormatVersion"`
+	Transform                string                 `yaml:"Transform"`
+	Description              string                 `yaml:"Description"`
+	Resources                map[string]SAMResource `yaml:"Resources"`
+	Outputs                  map[string]SAMOutput   `yaml:"Outputs"`
+}
+
+// SAMResource is a resource of a SAM template.
+type SAMResource struct {
+	Type       string            `yaml:"Type"`
+	Properties SAMFunction       `yaml:"Properties"`
+	Metadata   map[string]string `yaml:"Metadata,omitempty"`
+}
+
+// SAMFunction is the properties of an AWS::Serverless::Function running an image.
+type SAMFunction struct {
+	FunctionName                 string                       `yaml:"FunctionName"`
+	PackageType                  string                       `yaml:"PackageType"`
+	ImageUri                     string                       `yaml:"ImageUri"`
+	MemorySize                   int                          `yaml:"MemorySize"`
+	Timeout                      int                          `yaml:"Timeout"`
+	Environment                  map[string]map[string]string `yaml:"Environment"`
+	AutoPublishAlias             string                       `yaml:"AutoPublishAlias,omitempty"`
+	ProvisionedConcurrencyConfig *SAMProvisionedConcurrency   `yaml:"ProvisionedConcurrencyConfig,omitempty"`
+	ReservedConcurrentExecutions int                          `yaml:"ReservedConcurrentExecutions,omitempty"`
+	Events                       map[string]SAMEvent          `yaml:"Events"`
+	Tags                         map[string]string            `yaml:"Tags,omitempty"`
+}
+
+// SAMProvisionedConcurrency is the number of instances kept warm.
+type SAMProvisionedConcurrency struct {
+	ProvisionedConcurrentExecutions int `yaml:"ProvisionedConcurrentExecutions"`
+}
+
+// SAMEvent is an event source invoking the function.
+type SAMEvent struct {
+	Type string `yaml:"Type"`
+}
+
+// SAMOutput is an output of the stack.
+type SAMOutput struct {
+	Description string            `yaml:"Description"`
+	Value       map[string]string `yaml:"Value"`
+}
+
+// lambdaFunction is the logical id of the function in the SAM template.
+const lambdaFunction = "Function"
+
+// Generate writes the SAM template of app.
+func (l *Lambda) Generate(app *App) ([]File, error) {
+	if err := checkApp(app); err != nil {
+		return nil, err
+	}
+
+	_, memory := limits(app)
+	function := SAMFunction{
+		FunctionName: app.Name,
+		PackageType:  "Image",
+		ImageUri:     app.ImageReference(),
+		// Lambda allocates CPU in proportion to the memory
+		MemorySize:  min(max(memory, 128), 10240),
+		Timeout:     30,
+		Environment: map[string]map[string]string{"Variables": {"PORT": strconv.Itoa(app.Port)}},
+		Events:      map[string]SAMEvent{"Http": {Type: "HttpApi"}},
+		Tags:        cloudLabels(app),
+	}
+	// Instances kept warm, and the concurrency the function is limited to
+	minimum, maximum := scale(app)
+	if minimum > 0 {
+		function.AutoPublishAlias = "live"
+		function.ProvisionedConcurrencyConfig = &SAMProvisionedConcurrency{ProvisionedConcurrentExecutions: minimum}
+	}
+	function.ReservedConcurrentExecutions = maximum
+
+	template := &SAMTemplate{
+		AWSTemplateFormatVersion: "2010-09-09",
+		Transform:                "AWS::Serverless-2016-10-31",
+		Description:              app.Name + ", generated by daab",
+		Resources: map[string]SAMResource{
+			lambdaFunction: {
+				Type:       "AWS::Serverless::Function",
+				Properties: function,
+				// Lets 'sam build' build the image from the Dockerfile
+				Metadata: map[string]string{"Dockerfile": DockerfilePath, "DockerContext": ".", "DockerTag": DefaultImageTag},
+			},
+		},
+		Outputs: map[string]SAMOutput{
+			"Url": {
+				Description: "URL of the HTTP API of " + app.Name,
+				Value:       map[string]string{"Fn::Sub": "https://${ServerlessHttpApi}.execute-api.${AWS::Region}.amazonaws.com/"},
+			},
+		},
+	}
+
+	content, err := MarshalManifest(template)
+	if err != nil {
+		return nil, err
+	}
+	return []File{{Path: SAMTemplatePath, Content: content}}, nil
+}
+
+/******************************************************/
+/************Container Apps****************************/
+/****************************************************/
+
+// ContainerApps generates the YAML definition of an Azure Container App, deployed with
+// 'az containerapp create --yaml containerapp/containerapp.yaml'.
+type ContainerApps struct{}
+
+// Name returns "container-apps".
+func (c *ContainerApps) Name() string {
+	return "container-apps"
+}
+
+// ContainerApp is the subset of a Microsoft.App/containerApps definition written by
+// the generator. The environment is given to az with --environment.
+type ContainerApp struct {
+	Name       string                 `yaml:"name"`
+	Type       string                 `yaml:"type"`
+	Location   string                 `yaml:"location,omitempty"`
+	Tags       map[string]string      `yaml:"tags,omitempty"`
+	Properties ContainerAppProperties `yaml:"properties"`
+}
+
+// ContainerAppProperties are the properties of a Container App.
+type ContainerAppProperties struct {
+	Configuration ContainerAppConfiguration `yaml:"configuration"`
+	Template      ContainerAppTemplate      `yaml:"template"`
+}
+
+// ContainerAppConfiguration is the revision mode and the ingress of a Container App.
+type ContainerAppConfiguration struct {
+	ActiveRevisionsMode string              `yaml:"activeRevisionsMode"`
+	Ingress             ContainerAppIngress `yaml:"ingress"`
+}
+
+// ContainerAppIngress exposes the port of a Container App.
+type ContainerAppIngress struct {
+	External   bool   `yaml:"external"`
+	TargetPort int    `yaml:"targetPort"`
+	Transport  string `yaml:"transport"`
+}
+
+// ContainerAppTemplate is the template of the revisions of a Container App.
+type ContainerAppTemplate struct {
+	Containers []ContainerAppContainer `yaml:"containers"`
+	Scale      ContainerAppScale       `yaml:"scale"`
+}
+
+// ContainerAppContainer is the container of a revision.
+type ContainerAppContainer struct {
+	Name      string                `yaml:"name"`
+	Image     string                `yaml:"image"`
+	Env       []EnvVar              `yaml:"env,omitempty"`
+	Resources ContainerAppResources `yaml:"resources"`
+	Probes    []ContainerAppProbe   `yaml:"probes,omitempty"`
+}
+
+// ContainerAppResources are the CPU and memory of a container.
+type ContainerAppResources struct {
+	CPU    float64 `yaml:"cpu"`
+	Memory string  `yaml:"memory"`
+}
+
+// ContainerAppProbe checks the health of a container.
+type ContainerAppProbe struct {
+	Type          string              `yaml:"type"`
+	HTTPGet       ContainerAppHTTPGet `yaml:"httpGet"`
+	PeriodSeconds int                 `yaml:"periodSeconds,omitempty"`
+}
+
+// ContainerAppHTTPGet is the path and port requested by a probe.
+type ContainerAppHTTPGet struct {
+	Path string `yaml:"path"`
+	Port int    `yaml:"port"`
+}
+
+// ContainerAppScale bounds the number of replicas.
+type ContainerAppScale struct {
+	MinReplicas int `yaml:"minReplicas"`
+	MaxReplicas int `yaml:"maxReplicas,omitempty"`
+}
+
+// Generate writes the Container App definition of app.
+func (c *ContainerApps) Generate(app *App) ([]File, error) {
+	if err := checkApp(app); err != nil {
+		return nil, err
+	}
+
+	cpu, memory := containerAppResources(limits(app))
+	container := ContainerAppContainer{
+		Name:      app.Name,
+		Image:     app.ImageReference(),
+		Env:       []EnvVar{{Name: "PORT", Value: strconv.Itoa(app.Port)}},
+		Resources: ContainerAppResources{CPU: cpu, Memory: memory},
+	}
+	if app.HealthEndpoint != "" {
+		for _, probe := range []struct {
+			kind   string
+			period int
+		}{{"Readiness", 10}, {"Liveness", 20}} {
+			container.Probes = append(container.Probes, ContainerAppProbe{
+				Type:          probe.kind,
+				HTTPGet:       ContainerAppHTTPGet{Path: app.HealthEndpoint, Port: app.Port},
+				PeriodSeconds: probe.period,
+			})
+		}
+	}
+
+	minimum, maximum := scale(app)
+	definition := &ContainerApp{
+		Name:     app.Name,
+		Type:     "Microsoft.App/containerApps",
+		Location: app.Region,
+		Tags:     cloudLabels(app),
+		Properties: ContainerAppProperties{
+			Configuration: ContainerAppConfiguration{
+				ActiveRevisionsMode: "Single",
+				Ingress:             ContainerAppIngress{External: true, TargetPort: app.Port, Transport: "auto"},
+			},
+			Template: ContainerAppTemplate{
+				Containers: []ContainerAppContainer{container},
+				Scale:      ContainerAppScale{MinReplicas: minimum, MaxReplicas: maximum},
+			},
+		},
+	}
+
+	content, err := MarshalManifest(definition)
+	if err != nil {
+		return nil, err
+	}
+	return []File{{Path: ContainerAppPath, Content: content}}, nil
+}
+
+// containerAppResources returns the smallest CPU and memory pair of the consumption
+// plan covering the limits: 0.25 to 4 CPUs by 0.25, with 2Gi of memory per CPU.
+func containerAppResources(cpu float64, memory int) (float64, string) {
+	cores := math.Max(cpu, float64(memory)/2048)
+	cores = math.Min(math.Max(math.Ceil(cores*4)/4, 0.25), 4)
+	return cores, strconv.FormatFloat(cores*2, 'f', -1, 64) + "Gi"
+}
+
+/******************************************************/
+/************ECS Fargate*******************************/
+/****************************************************/
+
+// ECSFargate generates the task definition of an Amazon ECS task on Fargate, registered
+// with 'aws ecs register-task-definition --cli-input-yaml file://ecs/task-definition.yaml'.
+type ECSFargate struct{}
+
+// Name returns "ecs-fargate".
+func (e *ECSFargate) Name() string {
+	return "ecs-fargate"
+}
+
+// TaskDefinition is the input of 'aws ecs register-task-definition'.
+type TaskDefinition struct {
+	Family                  string                `yaml:"family"`
+	NetworkMode             string                `yaml:"networkMode"`
+	RequiresCompatibilities []string              `yaml:"requiresCompatibilities"`
+	CPU                     string                `yaml:"cpu"`
+	Memory                  string                `yaml:"memory"`
+	ExecutionRoleArn        string                `yaml:"executionRoleArn"`
+	RuntimePlatform         map[string]string     `yaml:"runtimePlatform"`
+	ContainerDefinitions    []ContainerDefinition `yaml:"containerDefinitions"`
+	Tags                    []TaskTag             `yaml:"tags,omitempty"`
+}
+
+// ContainerDefinition is the container of a task.
+type ContainerDefinition struct {
+	Name             string           `yaml:"name"`
+	Image            string           `yaml:"image"`
+	Essential        bool             `yaml:"essential"`
+	PortMappings     []PortMapping    `yaml:"portMappings"`
+	Environment      []EnvVar         `yaml:"environment"`
+	LogConfiguration LogConfiguration `yaml:"logConfiguration"`
+}
+
+// PortMapping is a port opened by a container.
+type PortMapping struct {
+	ContainerPort int    `yaml:"containerPort"`
+	Protocol      string `yaml:"protocol"`
+}
+
+// LogConfiguration sends the logs of a container to a log driver.
+type LogConfiguration struct {
+	LogDriver string            `yaml:"logDriver"`
+	Options   map[string]string `yaml:"options"`
+}
+
+// TaskTag is a tag of a task definition.
+type TaskTag struct {
+	Key   string `yaml:"key"`
+	Value string `yaml:"value"`
+}
+
+// ecrAccount extracts the account id from an ECR registry.
+var ecrAccount = regexp.MustCompile(`^(\d{12})\.dkr\.ecr\.`)
+
+// Generate writes the task definition of app.
+func (e *ECSFargate) Generate(app *App) ([]File, error) {
+	if err := checkApp(app); err != nil {
+		return nil, err
+	}
+
+	account := app.AccountID
+	if match := ecrAccount.FindStringSubmatch(app.Registry); account == "" && match != nil {
+		account = match[1]
+	}
+	if account == "" {
+		return nil, fmt.Errorf("%s: account_id must be set for the task execution role", app.Name)
+	}
+
+	cpu, memory := fargateSize(limits(app))
+	definition := &TaskDefinition{
+		Family:                  app.Name,
+		NetworkMode:             "awsvpc",
+		RequiresCompatibilities: []string{"FARGATE"},
+		CPU:                     strconv.Itoa(cpu),
+		Memory:                  strconv.Itoa(memory),
+		ExecutionRoleArn:        fmt.Sprintf("arn:aws:iam::%s:role/ecsTaskExecutionRole", account),
+		RuntimePlatform:         map[string]string{"cpuArchitecture": "X86_64", "operatingSystemFamily": "LINUX"},
+		ContainerDefinitions: []ContainerDefinition{{
+			Name:         app.Name,
+			Image:        app.ImageReference(),
+			Essential:    true,
+			PortMappings: []PortMapping{{ContainerPort: app.Port, Protocol: "tcp"}},
+			Environment:  []EnvVar{{Name: "PORT", Value: strconv.Itoa(app.Port)}},
+			LogConfiguration: LogConfiguration{
+				LogDriver: "awslogs",
+				Options: map[string]string{
+					"awslogs-group":         "/ecs/" + app.Name,
+					"awslogs-region":        app.Region,
+					"awslogs-stream-prefix": app.Name,
+					"awslogs-create-group":  "true",
+				},
+			},
+		}},
+	}
+	labels := cloudLabels(app)
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		definition.Tags = append(definition.Tags, TaskTag{Key: key, Value: labels[key]})
+	}
+
+	content, err := MarshalManifest(definition)
+	if err != nil {
+		return nil, err
+	}
+	return []File{{Path: TaskDefinitionPath, Content: content}}, nil
+}
+
+// fargateSizes are the CPU units of Fargate tasks and the memory (MiB) they accept.
+var fargateSizes = []struct{ cpu, minMemory, maxMemory, step int }{
+	{256, 512, 2048, 512},
+	{512, 1024, 4096, 1024},
+	{1024, 2048, 8192, 1024},
+	{2048, 4096, 16384, 1024},
+	{4096, 8192, 30720, 1024},
+}
+
+// fargateSize returns the smallest Fargate CPU and memory pair covering the limits.
+func fargateSize(cpu float64, memory int) (int, int) {
+	units := int(math.Ceil(cpu * 1024))
+	for _, size := range fargateSizes {
+		if units > size.cpu || memory > size.maxMemory {
+			continue
+		}
+		if memory <= size.minMemory {
+			return size.cpu, size.minMemory
+		}
+		return size.cpu, (memory + size.step - 1) / size.step * size.step
+	}
+	last := fargateSizes[len(fargateSizes)-1]
+	return last.cpu, last.maxMemory
+}
+
+// cloudLabels returns the labels of app as cloud tags, with the managed-by label of
+// the Kubernetes resources.
+func cloudLabels(app *App) map[string]string {
+	return config.MergeLabels(app.Labels, map[string]string{"managed-by": "daab"})
+}
@@ -0,0 +1,463 @@
+package generate
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	config "github.com/mouad4949/DAAB/pkg/config"
+	"gopkg.in/yaml.v3"
+)
+
+func serverlessApp(target string) *App {
+	return &App{
+		Name:           "users",
+		Language:       "go",
+		Port:           8080,
+		HealthEndpoint: "/health",
+		Registry:       "123456789012.dkr.ecr.eu-west-1.amazonaws.com",
+		Target:         target,
+		Region:         "eu-west-1",
+		Labels:         map[string]string{"team": "identity"},
+		Resources: &config.Resources{
+			Requests: config.ResourceList{CPU: "250m", Memory: "256Mi"},
+			Limits:   config.ResourceList{CPU: "500m", Memory: "512Mi"},
+		},
+	}
+}
+
+// decodeDescriptor finds the file at path among files and decodes it into out.
+func decodeDescriptor(t *testing.T, files []File, path string, out interface{}) {
+	t.Helper()
+	for _, file := range files {
+		if file.Path != path {
+			continue
+		}
+		if !IsGenerated(file.Content) {
+			t.Errorf("%s has no generated header", path)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(file.Content))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(out); err != nil {
+			t.Fatalf("%s is not a valid descriptor: %v", path, err)
+		}
+		return
+	}
+	t.Fatalf("no %s generated", path)
+}
+
+func generateFor(t *testing.T, app *App) []File {
+	t.Helper()
+	files, err := Generate(app)
+	if err != nil {
+		t.Fatalf("Generate(%s): %v", config.TargetOrDefault(app.Target), err)
+	}
+	return files
+}
+
+func TestForTarget(t *testing.T) {
+	tests := []struct {
+		target     string
+		descriptor string
+	}{
+		{target: "", descriptor: "k8s/deployment.yaml"},
+		{target: config.TargetKubernetes, descriptor: "k8s/deployment.yaml"},
+		{target: config.TargetCloudRun, descriptor: CloudRunServicePath},
+		{target: config.TargetLambda, descriptor: SAMTemplatePath},
+		{target: config.TargetContainerApps, descriptor: ContainerAppPath},
+		{target: config.TargetECSFargate, descriptor: TaskDefinitionPath},
+	}
+
+	for _, tt := range tests {
+		t.Run(config.TargetOrDefault(tt.target), func(t *testing.T) {
+			files := generateFor(t, serverlessApp(tt.target))
+
+			var paths []string
+			var dockerfile []byte
+			for _, file := range files {
+				paths = append(paths, file.Path)
+				if file.Path == DockerfilePath {
+					dockerfile = file.Content
+				}
+			}
+			sort.Strings(paths)
+			want := append([]string(nil), ArtifactsFor(tt.target)...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(paths, want) {
+				t.Errorf("Generate() wrote %v, ArtifactsFor() lists %v", paths, want)
+			}
+			if !strings.Contains(strings.Join(paths, " "), tt.descriptor) {
+				t.Errorf("Generate() wrote %v, want %s", paths, tt.descriptor)
+			}
+
+			// Only the Lambda image embeds the Lambda Web Adapter, listening on the app port
+			hasAdapter := bytes.Contains(dockerfile, []byte(LambdaAdapterImage))
+			if wantAdapter := tt.target == config.TargetLambda; hasAdapter != wantAdapter {
+				t.Errorf("Dockerfile has the Lambda adapter: %v, want %v", hasAdapter, wantAdapter)
+			}
+			if hasAdapter && !bytes.Contains(dockerfile, []byte("ENV AWS_LWA_PORT=8080")) {
+				t.Error("the Lambda adapter does not forward to port 8080")
+			}
+		})
+	}
+}
+
+func TestServerlessRejectsInvalidApps(t *testing.T) {
+	generators := []Generator{&CloudRun{}, &Lambda{}, &ContainerApps{}, &ECSFargate{}}
+	tests := []struct {
+		name    string
+		change  func(app *App)
+		wantErr string
+	}{
+		{name: "no name", change: func(app *App) { app.Name = "" }, wantErr: "name cannot be empty"},
+		{name: "no port", change: func(app *App) { app.Port = 0 }, wantErr: "port must be set"},
+	}
+
+	for _, tt := range tests {
+		for _, generator := range generators {
+			t.Run(tt.name+"/"+generator.Name(), func(t *testing.T) {
+				app := serverlessApp(generator.Name())
+				tt.change(app)
+				if _, err := generator.Generate(app); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Generate() error = %v, want %q", err, tt.wantErr)
+				}
+			})
+		}
+	}
+}
+
+func TestCloudRun(t *testing.T) {
+	tests := []struct {
+		name            string
+		change          func(app *App)
+		wantLimits      map[string]config.ResourceList
+		wantAnnotations map[string]string
+		wantProbes      bool
+	}{
+		{
+			name:       "fractional cpu rounded up to one cpu",
+			change:     func(app *App) {},
+			wantLimits: map[string]config.ResourceList{"limits": {CPU: "1", Memory: "512Mi"}},
+			wantProbes: true,
+		},
+		{
+			name: "memory above 4Gi needs 2 cpus",
+			change: func(app *App) {
+				app.Resources = &config.Resources{Limits: config.ResourceList{CPU: "1", Memory: "6Gi"}}
+			},
+			wantLimits: map[string]config.ResourceList{"limits": {CPU: "2", Memory: "6144Mi"}},
+			wantProbes: true,
+		},
+		{
+			name: "requests when no limit, at least 128Mi",
+			change: func(app *App) {
+				app.Resources = &config.Resources{Requests: config.ResourceList{CPU: "100m", Memory: "64Mi"}}
+				app.HealthEndpoint = ""
+			},
+			wantLimits: map[string]config.ResourceList{"limits": {CPU: "1", Memory: "128Mi"}},
+		},
+		{
+			name:   "no resources",
+			change: func(app *App) { app.Resources = nil },
+			// Cloud Run applies its own defaults
+			wantProbes: true,
+		},
+		{
+			name: "autoscaling bounds",
+			change: func(app *App) {
+				app.Autoscaling = &config.Autoscaling{MinReplicas: 2, MaxReplicas: 10}
+			},
+			wantLimits: map[string]config.ResourceList{"limits": {CPU: "1", Memory: "512Mi"}},
+			wantAnnotations: map[string]string{
+				"autoscaling.knative.dev/minScale": "2",
+				"autoscaling.knative.dev/maxScale": "10",
+			},
+			wantProbes: true,
+		},
+		{
+			name:            "replicas kept warm",
+			change:          func(app *App) { app.Replicas = 3 },
+			wantLimits:      map[string]config.ResourceList{"limits": {CPU: "1", Memory: "512Mi"}},
+			wantAnnotations: map[string]string{"autoscaling.knative.dev/minScale": "3"},
+			wantProbes:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := serverlessApp(config.TargetCloudRun)
+			tt.change(app)
+			var service CloudRunService
+			decodeDescriptor(t, generateFor(t, app), CloudRunServicePath, &service)
+
+			if service.APIVersion != "serving.knative.dev/v1" || service.Kind != "Service" || service.Metadata.Name != "users" {
+				t.Errorf("service = %s %s %s", service.APIVersion, service.Kind, service.Metadata.Name)
+			}
+			if want := map[string]string{"team": "identity", "managed-by": "daab"}; !reflect.DeepEqual(service.Metadata.Labels, want) {
+				t.Errorf("labels = %v, want %v", service.Metadata.Labels, want)
+			}
+			if !reflect.DeepEqual(service.Spec.Template.Metadata.Annotations, tt.wantAnnotations) {
+				t.Errorf("revision annotations = %v, want %v", service.Spec.Template.Metadata.Annotations, tt.wantAnnotations)
+			}
+
+			containers := service.Spec.Template.Spec.Containers
+			if len(containers) != 1 {
+				t.Fatalf("%d containers, Cloud Run runs one", len(containers))
+			}
+			container := containers[0]
+			if container.Image != "123456789012.dkr.ecr.eu-west-1.amazonaws.com/users:latest" {
+				t.Errorf("image = %s", container.Image)
+			}
+			if want := []ContainerPort{{Name: "http1", ContainerPort: 8080}}; !reflect.DeepEqual(container.Ports, want) {
+				t.Errorf("ports = %+v, want %+v", container.Ports, want)
+			}
+			if !reflect.DeepEqual(container.Resources, tt.wantLimits) {
+				t.Errorf("resources = %v, want %v", container.Resources, tt.wantLimits)
+			}
+			if hasProbes := container.StartupProbe != nil && container.LivenessProbe != nil; hasProbes != tt.wantProbes {
+				t.Errorf("probes set: %v, want %v", hasProbes, tt.wantProbes)
+			} else if hasProbes && container.StartupProbe.HTTPGet.Path != "/health" {
+				t.Errorf("startup probe path = %s, want /health", container.StartupProbe.HTTPGet.Path)
+			}
+		})
+	}
+}
+
+func TestLambda(t *testing.T) {
+	tests := []struct {
+		name            string
+		change          func(app *App)
+		wantMemory      int
+		wantProvisioned int
+		wantReserved    int
+	}{
+		{name: "memory limit", change: func(app *App) {}, wantMemory: 512},
+		{
+			name:       "at least 128MB",
+			change:     func(app *App) { app.Resources = nil },
+			wantMemory: 128,
+		},
+		{
+			name: "at most 10GB",
+			change: func(app *App) {
+				app.Resources = &config.Resources{Limits: config.ResourceList{Memory: "16Gi"}}
+			},
+			wantMemory: 10240,
+		},
+		{
+			name: "autoscaling as concurrency",
+			change: func(app *App) {
+				app.Autoscaling = &config.Autoscaling{MinReplicas: 2, MaxReplicas: 50}
+			},
+			wantMemory:      512,
+			wantProvisioned: 2,
+			wantReserved:    50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := serverlessApp(config.TargetLambda)
+			tt.change(app)
+			var template SAMTemplate
+			decodeDescriptor(t, generateFor(t, app), SAMTemplatePath, &template)
+
+			if template.Transform != "AWS::Serverless-2016-10-31" {
+				t.Errorf("Transform = %s, want the SAM transform", template.Transform)
+			}
+			resource, ok := template.Resources[lambdaFunction]
+			if !ok || resource.Type != "AWS::Serverless::Function" {
+				t.Fatalf("resources = %+v, want a %s function", template.Resources, lambdaFunction)
+			}
+			if resource.Metadata["Dockerfile"] != DockerfilePath {
+				t.Errorf("metadata = %v, want sam build to use the Dockerfile", resource.Metadata)
+			}
+
+			function := resource.Properties
+			if function.FunctionName != "users" || function.PackageType != "Image" || function.ImageUri != app.ImageReference() {
+				t.Errorf("function %s runs %s %s", function.FunctionName, function.PackageType, function.ImageUri)
+			}
+			if function.MemorySize != tt.wantMemory {
+				t.Errorf("MemorySize = %d, want %d", function.MemorySize, tt.wantMemory)
+			}
+			if port := function.Environment["Variables"]["PORT"]; port != "8080" {
+				t.Errorf("PORT = %q, want 8080", port)
+			}
+			if function.Events["Http"].Type != "HttpApi" {
+				t.Errorf("events = %+v, want an HTTP API", function.Events)
+			}
+
+			provisioned := 0
+			if function.ProvisionedConcurrencyConfig != nil {
+				provisioned = function.ProvisionedConcurrencyConfig.ProvisionedConcurrentExecutions
+				// Provisioned concurrency is only set on an alias
+				if function.AutoPublishAlias == "" {
+					t.Error("provisioned concurrency without an alias")
+				}
+			}
+			if provisioned != tt.wantProvisioned || function.ReservedConcurrentExecutions != tt.wantReserved {
+				t.Errorf("concurrency = %d provisioned, %d reserved, want %d and %d", provisioned, function.ReservedConcurrentExecutions, tt.wantProvisioned, tt.wantReserved)
+			}
+		})
+	}
+}
+
+func TestContainerApps(t *testing.T) {
+	app := serverlessApp(config.TargetContainerApps)
+	app.Autoscaling = &config.Autoscaling{MinReplicas: 1, MaxReplicas: 5}
+	var definition ContainerApp
+	decodeDescriptor(t, generateFor(t, app), ContainerAppPath, &definition)
+
+	if definition.Name != "users" || definition.Type != "Microsoft.App/containerApps" || definition.Location != "eu-west-1" {
+		t.Errorf("container app %s %s in %s", definition.Name, definition.Type, definition.Location)
+	}
+	ingress := definition.Properties.Configuration.Ingress
+	if !ingress.External || ingress.TargetPort != 8080 {
+		t.Errorf("ingress = %+v, want external on 8080", ingress)
+	}
+
+	template := definition.Properties.Template
+	if want := (ContainerAppScale{MinReplicas: 1, MaxReplicas: 5}); template.Scale != want {
+		t.Errorf("scale = %+v, want %+v", template.Scale, want)
+	}
+	container := template.Containers[0]
+	if want := []EnvVar{{Name: "PORT", Value: "8080"}}; !reflect.DeepEqual(container.Env, want) {
+		t.Errorf("env = %+v, want %+v", container.Env, want)
+	}
+	if want := (ContainerAppResources{CPU: 0.5, Memory: "1Gi"}); container.Resources != want {
+		t.Errorf("resources = %+v, want %+v", container.Resources, want)
+	}
+	var probes []string
+	for _, probe := range container.Probes {
+		probes = append(probes, probe.Type)
+		if probe.HTTPGet != (ContainerAppHTTPGet{Path: "/health", Port: 8080}) {
+			t.Errorf("%s probe requests %+v", probe.Type, probe.HTTPGet)
+		}
+	}
+	if want := []string{"Readiness", "Liveness"}; !reflect.DeepEqual(probes, want) {
+		t.Errorf("probes = %v, want %v", probes, want)
+	}
+}
+
+func TestContainerAppResources(t *testing.T) {
+	tests := []struct {
+		cpu        float64
+		memory     int
+		wantCPU    float64
+		wantMemory string
+	}{
+		{cpu: 0, memory: 0, wantCPU: 0.25, wantMemory: "0.5Gi"},
+		{cpu: 0.1, memory: 128, wantCPU: 0.25, wantMemory: "0.5Gi"},
+		{cpu: 0.6, memory: 512, wantCPU: 0.75, wantMemory: "1.5Gi"},
+		// Memory drives the CPU: 2Gi per CPU
+		{cpu: 0.25, memory: 3072, wantCPU: 1.5, wantMemory: "3Gi"},
+		{cpu: 16, memory: 0, wantCPU: 4, wantMemory: "8Gi"},
+	}
+
+	for _, tt := range tests {
+		cpu, memory := containerAppResources(tt.cpu, tt.memory)
+		if cpu != tt.wantCPU || memory != tt.wantMemory {
+			t.Errorf("containerAppResources(%v, %d) = %v, %s, want %v, %s", tt.cpu, tt.memory, cpu, memory, tt.wantCPU, tt.wantMemory)
+		}
+	}
+}
+
+func TestECSFargate(t *testing.T) {
+	app := serverlessApp(config.TargetECSFargate)
+	var definition TaskDefinition
+	decodeDescriptor(t, generateFor(t, app), TaskDefinitionPath, &definition)
+
+	if definition.Family != "users" || definition.NetworkMode != "awsvpc" || !reflect.DeepEqual(definition.RequiresCompatibilities, []string{"FARGATE"}) {
+		t.Errorf("task %s in %s mode, compatibilities %v", definition.Family, definition.NetworkMode, definition.RequiresCompatibilities)
+	}
+	// 0.5 CPU and 512Mi fit the 512 units with their minimum memory
+	if definition.CPU != "512" || definition.Memory != "1024" {
+		t.Errorf("size = %s cpu %s memory, want 512 and 1024", definition.CPU, definition.Memory)
+	}
+	// The account comes from the ECR registry when account_id is not set
+	if definition.ExecutionRoleArn != "arn:aws:iam::123456789012:role/ecsTaskExecutionRole" {
+		t.Errorf("executionRoleArn = %s", definition.ExecutionRoleArn)
+	}
+	if want := []TaskTag{{Key: "managed-by", Value: "daab"}, {Key: "team", Value: "identity"}}; !reflect.DeepEqual(definition.Tags, want) {
+		t.Errorf("tags = %+v, want %+v", definition.Tags, want)
+	}
+
+	container := definition.ContainerDefinitions[0]
+	if container.Image != app.ImageReference() || !container.Essential {
+		t.Errorf("container runs %s, essential %v", container.Image, container.Essential)
+	}
+	if want := []PortMapping{{ContainerPort: 8080, Protocol: "tcp"}}; !reflect.DeepEqual(container.PortMappings, want) {
+		t.Errorf("portMappings = %+v, want %+v", container.PortMappings, want)
+	}
+	if want := []EnvVar{{Name: "PORT", Value: "8080"}}; !reflect.DeepEqual(container.Environment, want) {
+		t.Errorf("environment = %+v, want %+v", container.Environment, want)
+	}
+	if options := container.LogConfiguration.Options; options["awslogs-region"] != "eu-west-1" || options["awslogs-group"] != "/ecs/users" {
+		t.Errorf("log options = %v", options)
+	}
+}
+
+func TestECSFargateAccount(t *testing.T) {
+	tests := []struct {
+		name      string
+		accountID string
+		registry  string
+		wantRole  string
+		wantErr   bool
+	}{
+		{name: "account_id wins", accountID: "210987654321", registry: "123456789012.dkr.ecr.eu-west-1.amazonaws.com", wantRole: "arn:aws:iam::210987654321:role/ecsTaskExecutionRole"},
+		{name: "from the ECR registry", registry: "123456789012.dkr.ecr.eu-west-1.amazonaws.com", wantRole: "arn:aws:iam::123456789012:role/ecsTaskExecutionRole"},
+		{name: "another registry", registry: "ghcr.io/acme", wantErr: true},
+		{name: "local image", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := serverlessApp(config.TargetECSFargate)
+			app.AccountID, app.Registry = tt.accountID, tt.registry
+			files, err := (&ECSFargate{}).Generate(app)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "account_id") {
+					t.Fatalf("Generate() error = %v, want account_id to be required", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Generate(): %v", err)
+			}
+			var definition TaskDefinition
+			if err := yaml.Unmarshal(files[0].Content, &definition); err != nil {
+				t.Fatal(err)
+			}
+			if definition.ExecutionRoleArn != tt.wantRole {
+				t.Errorf("executionRoleArn = %s, want %s", definition.ExecutionRoleArn, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestFargateSize(t *testing.T) {
+	tests := []struct {
+		cpu        float64
+		memory     int
+		wantCPU    int
+		wantMemory int
+	}{
+		{cpu: 0, memory: 0, wantCPU: 256, wantMemory: 512},
+		{cpu: 0.25, memory: 1000, wantCPU: 256, wantMemory: 1024},
+		// 256 units accept at most 2GB
+		{cpu: 0.25, memory: 3000, wantCPU: 512, wantMemory: 3072},
+		{cpu: 1, memory: 512, wantCPU: 1024, wantMemory: 2048},
+		{cpu: 1.5, memory: 5000, wantCPU: 2048, wantMemory: 5120},
+		{cpu: 16, memory: 65536, wantCPU: 4096, wantMemory: 30720},
+	}
+
+	for _, tt := range tests {
+		cpu, memory := fargateSize(tt.cpu, tt.memory)
+		if cpu != tt.wantCPU || memory != tt.wantMemory {
+			t.Errorf("fargateSize(%v, %d) = %d, %d, want %d, %d", tt.cpu, tt.memory, cpu, memory, tt.wantCPU, tt.wantMemory)
+		}
+	}
+}
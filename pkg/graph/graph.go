@@ -0,0 +1,260 @@
+// Package graph models the dependencies between the services of a project: which service
+// calls which, the order they are deployed in, and their rendering as DOT or Mermaid.
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Graph is a directed graph of services, an edge going from a service to a service it
+// depends on. Nodes keep the order they were added in.
+type Graph struct {
+	nodes []string
+	index map[string]int
+	// edges of every node, by node index
+	edges map[int][]Edge
+}
+
+// Edge is a dependency of From on To.
+type Edge struct {
+	From string
+	To   string
+	// Source tells why the dependency was inferred, empty for configured dependencies
+	Source string
+}
+
+// Inferred reports whether the dependency was inferred rather than configured.
+func (e Edge) Inferred() bool {
+	return e.Source != ""
+}
+
+// CycleError is returned when dependencies form a cycle, so no service can be
+// deployed first.
+type CycleError struct {
+	// Cycle lists the services of the cycle, the first one repeated at the end
+	Cycle []string
+}
+
+// Error lists the services of the cycle.
+func (e *CycleError) Error() string {
+	return "dependency cycle: " + strings.Join(e.Cycle, " -> ")
+}
+
+// New returns a graph of nodes without edges.
+func New(nodes ...string) *Graph {
+	g := &Graph{index: map[string]int{}, edges: map[int][]Edge{}}
+	for _, node := range nodes {
+		if _, ok := g.index[node]; !ok {
+			g.index[node] = len(g.nodes)
+			g.nodes = append(g.nodes, node)
+		}
+	}
+	return g
+}
+
+// Nodes returns the nodes in the order they were added.
+func (g *Graph) Nodes() []string {
+	return append([]string(nil), g.nodes...)
+}
+
+// AddEdge adds a dependency. Adding an existing dependency again keeps the first one.
+func (g *Graph) AddEdge(edge Edge) error {
+	from, ok := g.index[edge.From]
+	if !ok {
+		return fmt.Errorf("unknown service %q", edge.From)
+	}
+	if _, ok := g.index[edge.To]; !ok {
+		return fmt.Errorf("%s depends on unknown service %q", edge.From, edge.To)
+	}
+	if edge.From == edge.To {
+		return fmt.Errorf("%s cannot depend on itself", edge.From)
+	}
+	if g.HasEdge(edge.From, edge.To) {
+		return nil
+	}
+	g.edges[from] = append(g.edges[from], edge)
+	return nil
+}
+
+// HasEdge reports whether from depends on to directly.
+func (g *Graph) HasEdge(from, to string) bool {
+	for _, edge := range g.edges[g.index[from]] {
+		if edge.To == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Edges returns every dependency, by node order then target order.
+func (g *Graph) Edges() []Edge {
+	var edges []Edge
+	for idx := range g.nodes {
+		sorted := append([]Edge(nil), g.edges[idx]...)
+		sort.SliceStable(sorted, func(a, b int) bool {
+			return g.index[sorted[a].To] < g.index[sorted[b].To]
+		})
+		edges = append(edges, sorted...)
+	}
+	return edges
+}
+
+// DependsOn returns the direct dependencies of node, in node order.
+func (g *Graph) DependsOn(node string) []string {
+	var targets []string
+	for _, edge := range g.Edges() {
+		if edge.From == node {
+			targets = append(targets, edge.To)
+		}
+	}
+	return targets
+}
+
+// WouldCycle reports whether adding a dependency of from on to would close a cycle.
+func (g *Graph) WouldCycle(from, to string) bool {
+	if from == to {
+		return true
+	}
+	// A cycle exists when from is reachable from to
+	seen := map[string]bool{}
+	stack := []string{to}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if node == from {
+			return true
+		}
+		if seen[node] {
+			continue
+		}
+		seen[node] = true
+		for _, edge := range g.edges[g.index[node]] {
+			stack = append(stack, edge.To)
+		}
+	}
+	return false
+}
+
+// Merge adds the inferred dependencies missing from g, and returns the ones skipped
+// because they would close a cycle.
+func (g *Graph) Merge(inferred []Edge) []Edge {
+	var skipped []Edge
+	for _, edge := range inferred {
+		if g.HasEdge(edge.From, edge.To) {
+			continue
+		}
+		if g.WouldCycle(edge.From, edge.To) {
+			skipped = append(skipped, edge)
+			continue
+		}
+		_ = g.AddEdge(edge)
+	}
+	return skipped
+}
+
+// Order returns the nodes with every node after its dependencies, keeping the order the
+// nodes were added in otherwise. It returns a *CycleError when there is no such order.
+func (g *Graph) Order() ([]string, error) {
+	remaining := make([]int, len(g.nodes))
+	for idx := range g.nodes {
+		remaining[idx] = len(g.edges[idx])
+	}
+
+	order := make([]string, 0, len(g.nodes))
+	done := make([]bool, len(g.nodes))
+	for len(order) < len(g.nodes) {
+		next := -1
+		for idx := range g.nodes {
+			if !done[idx] && remaining[idx] == 0 {
+				next = idx
+				break
+			}
+		}
+		if next == -1 {
+			return nil, &CycleError{Cycle: g.findCycle(done)}
+		}
+
+		done[next] = true
+		order = append(order, g.nodes[next])
+		for idx := range g.nodes {
+			for _, edge := range g.edges[idx] {
+				if edge.To == g.nodes[next] {
+					remaining[idx]--
+				}
+			}
+		}
+	}
+	return order, nil
+}
+
+// findCycle returns a cycle among the nodes that are not done, which all have a
+// dependency left.
+func (g *Graph) findCycle(done []bool) []string {
+	start := 0
+	for done[start] {
+		start++
+	}
+
+	// Walk the dependencies until a node is visited twice
+	position := map[int]int{}
+	var path []string
+	for node := start; ; {
+		if at, ok := position[node]; ok {
+			return append(path[at:], g.nodes[node])
+		}
+		position[node] = len(path)
+		path = append(path, g.nodes[node])
+		for _, edge := range g.edges[node] {
+			if next := g.index[edge.To]; !done[next] {
+				node = next
+				break
+			}
+		}
+	}
+}
+
+// DOT renders the graph in the Graphviz format. Inferred dependencies are dashed and
+// labelled with their source.
+func (g *Graph) DOT(name string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", name)
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, node := range g.nodes {
+		fmt.Fprintf(&b, "  %q;\n", node)
+	}
+	for _, edge := range g.Edges() {
+		if edge.Inferred() {
+			fmt.Fprintf(&b, "  %q -> %q [style=dashed, label=%q];\n", edge.From, edge.To, edge.Source)
+			continue
+		}
+		fmt.Fprintf(&b, "  %q -> %q;\n", edge.From, edge.To)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart. Inferred dependencies are dotted and
+// labelled with their source.
+func (g *Graph) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, node := range g.nodes {
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", mermaidID(node), node)
+	}
+	for _, edge := range g.Edges() {
+		if edge.Inferred() {
+			fmt.Fprintf(&b, "  %s -.->|\"%s\"| %s\n", mermaidID(edge.From), strings.ReplaceAll(edge.Source, `"`, "'"), mermaidID(edge.To))
+			continue
+		}
+		fmt.Fprintf(&b, "  %s --> %s\n", mermaidID(edge.From), mermaidID(edge.To))
+	}
+	return b.String()
+}
+
+// mermaidID turns a DNS name into a Mermaid node id, which cannot contain '-'.
+func mermaidID(node string) string {
+	return strings.ReplaceAll(node, "-", "_")
+}
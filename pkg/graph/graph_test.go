@@ -0,0 +1,33 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	g := New("users", "billing", "web")
+	if err := g.AddEdge(Edge{From: "billing", To: "users"}); err != nil {
+		t.Fatal(err)
+	}
+
+	skipped := g.Merge([]Edge{
+		{From: "billing", To: "users", Source: "USERS_URL in .env"},
+		{From: "web", To: "billing", Source: "http://billing:8080 in app.js"},
+		{From: "users", To: "web", Source: "WEB_HOST in config.yaml"},
+	})
+
+	if want := []Edge{{From: "users", To: "web", Source: "WEB_HOST in config.yaml"}}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("Merge() skipped %v, want %v", skipped, want)
+	}
+	want := []Edge{
+		{From: "billing", To: "users"},
+		{From: "web", To: "billing", Source: "http://billing:8080 in app.js"},
+	}
+	if got := g.Edges(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Edges() = %v, want %v", got, want)
+	}
+	if order, err := g.Order(); err != nil || !reflect.DeepEqual(order, []string{"users", "billing", "web"}) {
+		t.Errorf("Order() = %v, %v", order, err)
+	}
+}
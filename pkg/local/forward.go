@@ -0,0 +1,93 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mouad4949/DAAB/pkg/generate"
+)
+
+// Forward sends the connections to a port of localhost to the Service of an application.
+type Forward struct {
+	App       string
+	Namespace string
+	LocalPort int
+}
+
+// URL returns the address of the application on localhost.
+func (f Forward) URL() string {
+	return fmt.Sprintf("http://localhost:%d", f.LocalPort)
+}
+
+// PlanForwards picks a local port for every app: the port of the app when it is free,
+// else the next free one. Ports below 1024 need root, 1024 is tried instead.
+func PlanForwards(apps []*generate.App) []Forward {
+	taken := map[int]bool{}
+	forwards := make([]Forward, 0, len(apps))
+	for _, app := range apps {
+		port := max(app.Port, 1024)
+		for taken[port] || !portFree(port) {
+			port++
+		}
+		taken[port] = true
+		forwards = append(forwards, Forward{App: app.Name, Namespace: app.Namespace, LocalPort: port})
+	}
+	return forwards
+}
+
+func portFree(port int) bool {
+	listener, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(port))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
+
+// restartDelay is the wait before a port-forward that stopped is started again, e.g.
+// after its pod was replaced.
+const restartDelay = 2 * time.Second
+
+// RunForwards runs a kubectl port-forward for every forward, restarting the ones that
+// stop, until ctx is done.
+func RunForwards(ctx context.Context, kubeContext string, forwards []Forward, out io.Writer) error {
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return fmt.Errorf("port-forwarding needs kubectl in PATH")
+	}
+
+	var wg sync.WaitGroup
+	for _, forward := range forwards {
+		wg.Add(1)
+		go func(forward Forward) {
+			defer wg.Done()
+			for {
+				args := []string{"--context", kubeContext, "port-forward", "service/" + forward.App,
+					fmt.Sprintf("%d:%d", forward.LocalPort, generate.ServiceHTTPPort)}
+				if forward.Namespace != "" {
+					args = append(args, "--namespace", forward.Namespace)
+				}
+				cmd := exec.CommandContext(ctx, "kubectl", args...)
+				// kubectl prints every connection, only its errors are shown
+				cmd.Stderr = out
+				err := cmd.Run()
+				if ctx.Err() != nil {
+					return
+				}
+				fmt.Fprintf(out, "⚠️  Port-forward of %s stopped (%v), restarting\n", forward.App, err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(restartDelay):
+				}
+			}
+		}(forward)
+	}
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,323 @@
+// Package local runs the Kubernetes manifests of a project on a cluster on the developer
+// machine: it creates kind or k3d clusters, loads the images into them and forwards the
+// ports of the services to localhost.
+package local
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mouad4949/DAAB/pkg/build"
+	"gopkg.in/yaml.v3"
+)
+
+// Names of the local cluster tools.
+const (
+	ProviderKind = "kind"
+	ProviderK3d  = "k3d"
+)
+
+// Provider creates and deletes local clusters with a tool such as kind or k3d.
+type Provider interface {
+	// Name identifies the provider, e.g. "kind"
+	Name() string
+	// Available returns an error when the tool is not installed
+	Available() error
+	// ConfigFile is the name of the file Config is written to
+	ConfigFile() string
+	// Config renders the config of the cluster named cluster
+	Config(cluster string) ([]byte, error)
+	// Context returns the kubeconfig context of the cluster
+	Context(cluster string) string
+	Exists(ctx context.Context, cluster string) (bool, error)
+	Create(ctx context.Context, cluster, configPath string, out io.Writer) error
+	Delete(ctx context.Context, cluster string, out io.Writer) error
+	// LoadImage copies an image of the local docker daemon into the nodes of the cluster
+	LoadImage(ctx context.Context, cluster, image string, out io.Writer) error
+}
+
+var providers = map[string]func() Provider{
+	ProviderKind: func() Provider { return &Kind{} },
+	ProviderK3d:  func() Provider { return &K3d{} },
+}
+
+// autoOrder is the order in which NewProvider picks a provider when none is requested.
+var autoOrder = []string{ProviderKind, ProviderK3d}
+
+// Providers lists the names of the providers.
+func Providers() []string {
+	return append([]string(nil), autoOrder...)
+}
+
+// NewProvider returns the provider called name. An empty name or "auto" picks the first
+// installed of kind and k3d.
+func NewProvider(name string) (Provider, error) {
+	if name == "" || name == "auto" {
+		for _, candidate := range autoOrder {
+			provider := providers[candidate]()
+			if provider.Available() == nil {
+				return provider, nil
+			}
+		}
+		return nil, fmt.Errorf("no local cluster tool available, install kind or k3d")
+	}
+
+	factory, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown local cluster provider %q, available providers: %v", name, Providers())
+	}
+	provider := factory()
+	if err := provider.Available(); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+func lookPath(binary string) error {
+	if _, err := exec.LookPath(binary); err != nil {
+		return fmt.Errorf("local clusters need %s in PATH", binary)
+	}
+	return nil
+}
+
+func runner(run build.CommandRunner) build.CommandRunner {
+	if run == nil {
+		return build.RunCommand
+	}
+	return run
+}
+
+/******************************************************/
+/************kind**************************************/
+/****************************************************/
+
+// Kind runs the cluster in docker containers with kind.
+type Kind struct {
+	Run build.CommandRunner
+}
+
+// Name returns "kind".
+func (k *Kind) Name() string {
+	return ProviderKind
+}
+
+// Available checks that the kind CLI is installed.
+func (k *Kind) Available() error {
+	return lookPath("kind")
+}
+
+// ConfigFile returns "kind.yaml".
+func (k *Kind) ConfigFile() string {
+	return "kind.yaml"
+}
+
+// KindConfig is the subset of a kind cluster config written by Config.
+type KindConfig struct {
+	Kind       string     `yaml:"kind"`
+	APIVersion string     `yaml:"apiVersion"`
+	Name       string     `yaml:"name"`
+	Nodes      []KindNode `yaml:"nodes"`
+}
+
+// KindNode is a node of a kind cluster.
+type KindNode struct {
+	Role string `yaml:"role"`
+}
+
+// Config returns a cluster of a single control-plane node.
+func (k *Kind) Config(cluster string) ([]byte, error) {
+	return marshal(&KindConfig{
+		Kind:       "Cluster",
+		APIVersion: "kind.x-k8s.io/v1alpha4",
+		Name:       cluster,
+		Nodes:      []KindNode{{Role: "control-plane"}},
+	})
+}
+
+// Context returns "kind-<cluster>", the context kind adds to the kubeconfig.
+func (k *Kind) Context(cluster string) string {
+	return "kind-" + cluster
+}
+
+// Exists looks for the cluster in 'kind get clusters'.
+func (k *Kind) Exists(ctx context.Context, cluster string) (bool, error) {
+	var out bytes.Buffer
+	if err := runner(k.Run)(ctx, &out, "kind", "get", "clusters"); err != nil {
+		return false, err
+	}
+	return containsLine(out.String(), cluster), nil
+}
+
+// Create creates the cluster from the config and waits for its control plane.
+func (k *Kind) Create(ctx context.Context, cluster, configPath string, out io.Writer) error {
+	return runner(k.Run)(ctx, out, "kind", "create", "cluster", "--name", cluster, "--config", configPath, "--wait", "2m")
+}
+
+// Delete deletes the cluster.
+func (k *Kind) Delete(ctx context.Context, cluster string, out io.Writer) error {
+	return runner(k.Run)(ctx, out, "kind", "delete", "cluster", "--name", cluster)
+}
+
+// LoadImage copies an image of the docker daemon to the nodes.
+func (k *Kind) LoadImage(ctx context.Context, cluster, image string, out io.Writer) error {
+	return runner(k.Run)(ctx, out, "kind", "load", "docker-image", image, "--name", cluster)
+}
+
+/******************************************************/
+/************k3d***************************************/
+/****************************************************/
+
+// K3d runs a k3s cluster in docker containers with k3d.
+type K3d struct {
+	Run build.CommandRunner
+}
+
+// Name returns "k3d".
+func (k *K3d) Name() string {
+	return ProviderK3d
+}
+
+// Available checks that the k3d CLI is installed.
+func (k *K3d) Available() error {
+	return lookPath("k3d")
+}
+
+// ConfigFile returns "k3d.yaml".
+func (k *K3d) ConfigFile() string {
+	return "k3d.yaml"
+}
+
+// K3dConfig is the subset of a k3d "Simple" config written by Config.
+type K3dConfig struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   map[string]string `yaml:"metadata"`
+	Servers    int               `yaml:"servers"`
+	Agents     int               `yaml:"agents"`
+	Options    K3dOptions        `yaml:"options"`
+}
+
+// K3dOptions are the options of a k3d cluster.
+type K3dOptions struct {
+	K3s K3sOptions `yaml:"k3s"`
+}
+
+// K3sOptions are the options given to k3s.
+type K3sOptions struct {
+	ExtraArgs []K3sArg `yaml:"extraArgs"`
+}
+
+// K3sArg is an argument of k3s and the nodes it applies to.
+type K3sArg struct {
+	Arg         string   `yaml:"arg"`
+	NodeFilters []string `yaml:"nodeFilters"`
+}
+
+// Config returns a cluster of one server without agents or the bundled traefik.
+func (k *K3d) Config(cluster string) ([]byte, error) {
+	return marshal(&K3dConfig{
+		APIVersion: "k3d.io/v1alpha5",
+		Kind:       "Simple",
+		Metadata:   map[string]string{"name": cluster},
+		Servers:    1,
+		Agents:     0,
+		// The services are reached with port-forwards, the bundled ingress is not needed
+		Options: K3dOptions{K3s: K3sOptions{ExtraArgs: []K3sArg{{Arg: "--disable=traefik", NodeFilters: []string{"server:*"}}}}},
+	})
+}
+
+// Context returns "k3d-<cluster>", the context k3d adds to the kubeconfig.
+func (k *K3d) Context(cluster string) string {
+	return "k3d-" + cluster
+}
+
+// Exists looks for the cluster in 'k3d cluster list'.
+func (k *K3d) Exists(ctx context.Context, cluster string) (bool, error) {
+	var out bytes.Buffer
+	if err := runner(k.Run)(ctx, &out, "k3d", "cluster", "list", "--no-headers"); err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == cluster {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Create creates the cluster from the config and waits for it.
+func (k *K3d) Create(ctx context.Context, cluster, configPath string, out io.Writer) error {
+	return runner(k.Run)(ctx, out, "k3d", "cluster", "create", cluster, "--config", configPath, "--wait")
+}
+
+// Delete deletes the cluster.
+func (k *K3d) Delete(ctx context.Context, cluster string, out io.Writer) error {
+	return runner(k.Run)(ctx, out, "k3d", "cluster", "delete", cluster)
+}
+
+// LoadImage imports an image of the docker daemon into the cluster.
+func (k *K3d) LoadImage(ctx context.Context, cluster, image string, out io.Writer) error {
+	return runner(k.Run)(ctx, out, "k3d", "image", "import", image, "--cluster", cluster)
+}
+
+/******************************************************/
+/************State*************************************/
+/****************************************************/
+
+// Cluster records the local cluster of a project, so 'daab down' deletes the cluster
+// 'daab up' created.
+type Cluster struct {
+	Provider  string    `yaml:"provider"`
+	Name      string    `yaml:"name"`
+	Context   string    `yaml:"context"`
+	CreatedAt time.Time `yaml:"created_at"`
+}
+
+// LoadCluster reads the cluster recorded at path, nil when there is none.
+func LoadCluster(path string) (*Cluster, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cluster := &Cluster{}
+	if err := yaml.Unmarshal(data, cluster); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cluster, nil
+}
+
+// Marshal renders the cluster record.
+func (c *Cluster) Marshal() ([]byte, error) {
+	return marshal(c)
+}
+
+func marshal(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func containsLine(text, line string) bool {
+	for _, candidate := range strings.Split(text, "\n") {
+		if strings.TrimSpace(candidate) == line {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Layer is a set of files and folders added to the image filesystem. Entries are
+// written sorted by path with fixed owners and timestamps, so a layer only depends on
+// its content.
+type Layer struct {
+	// CreatedBy describes the layer in the image history
+	CreatedBy string
+	entries   map[string]*tar.Header
+	data      map[string][]byte
+}
+
+// NewLayer returns an empty layer described by createdBy.
+func NewLayer(createdBy string) *Layer {
+	return &Layer{
+		CreatedBy: createdBy,
+		entries:   map[string]*tar.Header{},
+		data:      map[string][]byte{},
+	}
+}
+
+// AddDir adds a folder. mode holds the permission bits, including the sticky bit (01000).
+func (l *Layer) AddDir(name string, mode int64, uid, gid int) {
+	name = cleanName(name)
+	l.addParents(name)
+	l.entries[name] = &tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: mode, Uid: uid, Gid: gid}
+}
+
+// AddFile adds a file owned by root. Missing parent folders are added with mode 0755.
+func (l *Layer) AddFile(name string, mode int64, data []byte) {
+	name = cleanName(name)
+	l.addParents(name)
+	l.entries[name] = &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: mode, Size: int64(len(data))}
+	l.data[name] = data
+}
+
+func (l *Layer) addParents(name string) {
+	for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if _, ok := l.entries[dir]; ok {
+			continue
+		}
+		l.entries[dir] = &tar.Header{Typeflag: tar.TypeDir, Name: dir + "/", Mode: 0755}
+	}
+}
+
+// cleanName returns the path of an entry relative to the root of the filesystem.
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// Blob is a compressed layer.
+type Blob struct {
+	Data []byte
+	// DiffID is the digest of the uncompressed tarball
+	DiffID string
+}
+
+// Build writes the layer as a gzipped tarball, every entry dated mtime.
+func (l *Layer) Build(mtime time.Time) (*Blob, error) {
+	names := make([]string, 0, len(l.entries))
+	for name := range l.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tarball bytes.Buffer
+	tw := tar.NewWriter(&tarball)
+	for _, name := range names {
+		header := *l.entries[name]
+		header.ModTime = mtime
+		header.Format = tar.FormatPAX
+		if err := tw.WriteHeader(&header); err != nil {
+			return nil, fmt.Errorf("failed to add %s to the layer: %w", name, err)
+		}
+		if _, err := tw.Write(l.data[name]); err != nil {
+			return nil, fmt.Errorf("failed to add %s to the layer: %w", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	var compressed bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&compressed, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gz.Write(tarball.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return &Blob{Data: compressed.Bytes(), DiffID: Digest(tarball.Bytes())}, nil
+}
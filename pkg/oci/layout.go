@@ -0,0 +1,130 @@
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Image is an image to assemble: its configuration and its layers, base layer first.
+// The rootfs and history of Config are filled from the layers.
+type Image struct {
+	Config ImageConfig
+	Layers []*Layer
+}
+
+// WriteLayout assembles img as an OCI image layout in dir, named with every reference of
+// refs, and returns the descriptor of its manifest. The layout replaces the content of
+// dir only once it is complete.
+func WriteLayout(dir string, img *Image, refs []string) (Descriptor, error) {
+	parent := filepath.Dir(dir)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return Descriptor{}, err
+	}
+	tmp, err := os.MkdirTemp(parent, "."+filepath.Base(dir)+"-")
+	if err != nil {
+		return Descriptor{}, err
+	}
+	defer os.RemoveAll(tmp)
+
+	manifest, err := writeImage(tmp, img, refs)
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return Descriptor{}, err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return Descriptor{}, fmt.Errorf("failed to write the image layout: %w", err)
+	}
+	return manifest, nil
+}
+
+func writeImage(dir string, img *Image, refs []string) (Descriptor, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755); err != nil {
+		return Descriptor{}, err
+	}
+
+	config := img.Config
+	config.RootFS = RootFS{Type: "layers"}
+	config.History = nil
+
+	mtime := SourceDateEpoch()
+	if config.Created != nil {
+		mtime = *config.Created
+	}
+
+	manifest := Manifest{SchemaVersion: 2, MediaType: MediaTypeManifest}
+	for _, layer := range img.Layers {
+		blob, err := layer.Build(mtime)
+		if err != nil {
+			return Descriptor{}, err
+		}
+		descriptor, err := writeBlob(dir, MediaTypeLayer, blob.Data)
+		if err != nil {
+			return Descriptor{}, err
+		}
+		manifest.Layers = append(manifest.Layers, descriptor)
+		config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, blob.DiffID)
+		config.History = append(config.History, History{Created: config.Created, CreatedBy: layer.CreatedBy})
+	}
+
+	configDescriptor, err := writeJSONBlob(dir, MediaTypeConfig, config)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	manifest.Config = configDescriptor
+
+	manifestDescriptor, err := writeJSONBlob(dir, MediaTypeManifest, manifest)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	manifestDescriptor.Platform = &Platform{Architecture: config.Architecture, OS: config.OS}
+
+	index := Index{SchemaVersion: 2, MediaType: MediaTypeIndex, Manifests: []Descriptor{}}
+	for _, ref := range refs {
+		named := manifestDescriptor
+		_, tag := SplitReference(ref)
+		named.Annotations = map[string]string{AnnotationRefName: tag, AnnotationImageName: ref}
+		index.Manifests = append(index.Manifests, named)
+	}
+	if len(refs) == 0 {
+		index.Manifests = append(index.Manifests, manifestDescriptor)
+	}
+
+	if err := writeJSON(filepath.Join(dir, "index.json"), index); err != nil {
+		return Descriptor{}, err
+	}
+	if err := writeJSON(filepath.Join(dir, "oci-layout"), map[string]string{"imageLayoutVersion": "1.0.0"}); err != nil {
+		return Descriptor{}, err
+	}
+	return manifestDescriptor, nil
+}
+
+func writeBlob(dir, mediaType string, data []byte) (Descriptor, error) {
+	digest := Digest(data)
+	path := filepath.Join(dir, "blobs", "sha256", strings.TrimPrefix(digest, "sha256:"))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return Descriptor{}, fmt.Errorf("failed to write blob %s: %w", digest, err)
+	}
+	return Descriptor{MediaType: mediaType, Digest: digest, Size: int64(len(data))}, nil
+}
+
+func writeJSONBlob(dir, mediaType string, value any) (Descriptor, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	return writeBlob(dir, mediaType, data)
+}
+
+func writeJSON(path string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,404 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mouad4949/DAAB/pkg/oci"
+)
+
+// Client pushes images to registries. It is safe for concurrent use.
+type Client struct {
+	// HTTP is the client used for every request, http.DefaultClient when nil
+	HTTP *http.Client
+	// Keychain returns the credentials of the registries, DefaultKeychain when nil
+	Keychain Keychain
+	// Retries is the number of attempts after a network error, a 429 or a 5xx
+	Retries int
+	// Backoff is the wait before the first retry, doubled after each attempt
+	Backoff time.Duration
+	// PlainHTTP talks to every registry over HTTP instead of HTTPS; registries on
+	// localhost always use HTTP
+	PlainHTTP bool
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+// NewClient returns a client with the default keychain, 3 retries and a 1s backoff.
+func NewClient() *Client {
+	return &Client{Keychain: DefaultKeychain(), Retries: 3, Backoff: time.Second}
+}
+
+// PushLayout pushes the image of the OCI layout in dir as ref, writing the progress to
+// out, and returns the digest of the pushed manifest. The image of the layout named ref
+// is pushed, or its only image.
+func (c *Client) PushLayout(ctx context.Context, dir, ref string, out io.Writer) (string, error) {
+	if out == nil {
+		out = io.Discard
+	}
+	target, err := ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+
+	descriptor, err := findManifest(dir, ref)
+	if err != nil {
+		return "", err
+	}
+	manifestData, err := os.ReadFile(blobPath(dir, descriptor.Digest))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the manifest: %w", err)
+	}
+	var manifest oci.Manifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return "", fmt.Errorf("failed to parse the manifest: %w", err)
+	}
+
+	blobs := append([]oci.Descriptor{manifest.Config}, manifest.Layers...)
+	for _, blob := range blobs {
+		if err := c.pushBlob(ctx, target, dir, blob, out); err != nil {
+			return "", err
+		}
+	}
+
+	digest, err := c.PutManifest(ctx, target, descriptor.MediaType, manifestData)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(out, "pushed %s@%s\n", target.Host+"/"+target.Repository, digest)
+	return digest, nil
+}
+
+// findManifest returns the manifest of the layout index named ref, or its only manifest.
+func findManifest(dir, ref string) (oci.Descriptor, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return oci.Descriptor{}, fmt.Errorf("failed to read the image layout: %w", err)
+	}
+	var index oci.Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return oci.Descriptor{}, fmt.Errorf("failed to parse %s: %w", filepath.Join(dir, "index.json"), err)
+	}
+
+	for _, descriptor := range index.Manifests {
+		if descriptor.Annotations[oci.AnnotationImageName] == ref {
+			return descriptor, nil
+		}
+	}
+	if len(index.Manifests) == 1 {
+		return index.Manifests[0], nil
+	}
+	return oci.Descriptor{}, fmt.Errorf("no image named %s in %s", ref, dir)
+}
+
+func blobPath(dir, digest string) string {
+	return filepath.Join(dir, "blobs", "sha256", strings.TrimPrefix(digest, "sha256:"))
+}
+
+// pushBlob uploads a blob of the layout in a single request, unless the registry has it.
+func (c *Client) pushBlob(ctx context.Context, target Reference, dir string, blob oci.Descriptor, out io.Writer) error {
+	short := shortDigest(blob.Digest)
+	exists, err := c.HasBlob(ctx, target, blob.Digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		fmt.Fprintf(out, "%s: already exists\n", short)
+		return nil
+	}
+
+	fmt.Fprintf(out, "%s: pushing %s\n", short, formatSize(blob.Size))
+	start := time.Now()
+	resp, err := c.do(ctx, target, http.MethodPost, c.url(target, "/blobs/uploads/"), nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return statusError("start the upload of "+short, resp)
+	}
+	location, err := resp.Location()
+	if err != nil {
+		return fmt.Errorf("the registry did not return an upload location: %w", err)
+	}
+	query := location.Query()
+	query.Set("digest", blob.Digest)
+	location.RawQuery = query.Encode()
+
+	path := blobPath(dir, blob.Digest)
+	body := func() (io.ReadCloser, error) { return os.Open(path) }
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	resp, err = c.doBody(ctx, target, http.MethodPut, location.String(), body, blob.Size, header)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return statusError("upload "+short, resp)
+	}
+	fmt.Fprintf(out, "%s: pushed in %s\n", short, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// HasBlob reports whether the repository of target has the blob digest.
+func (c *Client) HasBlob(ctx context.Context, target Reference, digest string) (bool, error) {
+	resp, err := c.do(ctx, target, http.MethodHead, c.url(target, "/blobs/"+digest), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, statusError("check blob "+shortDigest(digest), resp)
+}
+
+// PutManifest uploads a manifest as the tag of target and returns its digest.
+func (c *Client) PutManifest(ctx context.Context, target Reference, mediaType string, manifest []byte) (string, error) {
+	header := http.Header{"Content-Type": {mediaType}}
+	resp, err := c.do(ctx, target, http.MethodPut, c.url(target, "/manifests/"+target.Tag), manifest, header)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", statusError("push the manifest of "+target.String(), resp)
+	}
+
+	digest := oci.Digest(manifest)
+	if returned := resp.Header.Get("Docker-Content-Digest"); returned != "" && returned != digest {
+		return "", fmt.Errorf("the registry stored the manifest as %s, expected %s", returned, digest)
+	}
+	return digest, nil
+}
+
+func (c *Client) url(target Reference, path string) string {
+	scheme := "https"
+	if c.PlainHTTP || isLocal(target.Host) {
+		scheme = "http"
+	}
+	return scheme + "://" + target.Host + "/v2/" + target.Repository + path
+}
+
+func (c *Client) do(ctx context.Context, target Reference, method, url string, body []byte, header http.Header) (*http.Response, error) {
+	var open func() (io.ReadCloser, error)
+	if body != nil {
+		open = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	}
+	return c.doBody(ctx, target, method, url, open, int64(len(body)), header)
+}
+
+// doBody sends a request, authenticating when the registry asks for it and retrying
+// network errors, 429 and 5xx responses. body is reopened for every attempt.
+func (c *Client) doBody(ctx context.Context, target Reference, method, url string, body func() (io.ReadCloser, error), size int64, header http.Header) (*http.Response, error) {
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, target, method, url, body, size, header)
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			resp.Body.Close()
+			if err = c.authenticate(ctx, target, resp); err == nil {
+				resp, err = c.send(ctx, target, method, url, body, size, header)
+			}
+		}
+
+		retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retryable || attempt >= c.Retries || ctx.Err() != nil || errors.Is(err, errUnauthorized) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, target Reference, method, url string, body func() (io.ReadCloser, error), size int64, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		reader, err := body()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = reader
+		req.ContentLength = size
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if auth := c.authorization(target); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// errUnauthorized is returned when the registry rejects the credentials, it is not retried.
+var errUnauthorized = errors.New("unauthorized")
+
+func tokenKey(target Reference) string {
+	return target.Host + "/" + target.Repository
+}
+
+func (c *Client) authorization(target Reference) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens[tokenKey(target)]
+}
+
+func (c *Client) setAuthorization(target Reference, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokens == nil {
+		c.tokens = map[string]string{}
+	}
+	c.tokens[tokenKey(target)] = value
+}
+
+// authenticate answers the challenge of a 401 response: Basic credentials, or a Bearer
+// token requested from the token service of the registry with push and pull scope.
+func (c *Client) authenticate(ctx context.Context, target Reference, resp *http.Response) error {
+	keychain := c.Keychain
+	if keychain == nil {
+		keychain = DefaultKeychain()
+	}
+	creds, err := keychain.Resolve(target.Host)
+	if err != nil {
+		return err
+	}
+
+	scheme, params := parseChallenge(resp.Header.Get("WWW-Authenticate"))
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if creds.Empty() {
+			return fmt.Errorf("%w: %s needs credentials, run 'docker login %s' or set $%s and $%s", errUnauthorized, target.Host, target.Host, EnvUsername, EnvPassword)
+		}
+		basic := base64.StdEncoding.EncodeToString([]byte(creds.Username + ":" + creds.Password))
+		c.setAuthorization(target, "Basic "+basic)
+		return nil
+	case "bearer":
+		token, err := c.fetchToken(ctx, params, target, creds)
+		if err != nil {
+			return err
+		}
+		c.setAuthorization(target, "Bearer "+token)
+		return nil
+	}
+	return fmt.Errorf("%w: unsupported authentication %q from %s", errUnauthorized, scheme, target.Host)
+}
+
+func (c *Client) fetchToken(ctx context.Context, params map[string]string, target Reference, creds Credentials) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("%w: invalid token realm %q from %s", errUnauthorized, params["realm"], target.Host)
+	}
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", "repository:"+target.Repository+":pull,push")
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if !creds.Empty() {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get a token from %s: %w", realm.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: %s refused the credentials for %s (%s)", errUnauthorized, realm.Host, target.Repository, resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("invalid token response from %s: %w", realm.Host, err)
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// parseChallenge parses a WWW-Authenticate header, e.g.
+// `Bearer realm="https://auth.docker.io/token",service="registry.docker.io"`.
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := map[string]string{}
+	for rest != "" {
+		var key, value string
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		params[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	return scheme, params
+}
+
+func statusError(action string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("failed to %s: %w (%s)", action, errUnauthorized, resp.Status)
+	}
+	return fmt.Errorf("failed to %s: %s", action, resp.Status)
+}
+
+func shortDigest(digest string) string {
+	digest = strings.TrimPrefix(digest, "sha256:")
+	if len(digest) > 12 {
+		return digest[:12]
+	}
+	return digest
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f kB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", size)
+}
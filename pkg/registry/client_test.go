@@ -0,0 +1,302 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mouad4949/DAAB/pkg/oci"
+)
+
+// staticKeychain returns the same credentials for every host.
+type staticKeychain Credentials
+
+func (k staticKeychain) Resolve(host string) (Credentials, error) {
+	return Credentials(k), nil
+}
+
+// recorder counts the requests reaching a handler by method and path kind.
+type recorder struct {
+	handler http.Handler
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	r.requests = append(r.requests, req.Method+" "+pathKind(req.URL.Path))
+	r.mu.Unlock()
+	r.handler.ServeHTTP(w, req)
+}
+
+func (r *recorder) count(request string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, got := range r.requests {
+		if got == request {
+			n++
+		}
+	}
+	return n
+}
+
+func pathKind(path string) string {
+	for _, kind := range []string{"/blobs/uploads/", "/blobs/", "/manifests/"} {
+		if strings.Contains(path, kind) {
+			return strings.Trim(kind, "/")
+		}
+	}
+	return path
+}
+
+// writeLayout writes an image of one layer named ref to a new layout and returns its
+// folder.
+func writeLayout(t *testing.T, ref string) string {
+	t.Helper()
+	layer := oci.NewLayer("test")
+	layer.AddFile("/app", 0755, []byte("#!/app"))
+	dir := filepath.Join(t.TempDir(), "oci")
+	img := &oci.Image{
+		Config: oci.ImageConfig{Architecture: "amd64", OS: "linux"},
+		Layers: []*oci.Layer{layer},
+	}
+	if _, err := oci.WriteLayout(dir, img, []string{ref}); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func newClient(keychain Keychain) *Client {
+	return &Client{Keychain: keychain, Retries: 3, Backoff: time.Millisecond}
+}
+
+func TestPushLayout(t *testing.T) {
+	memory := NewMemory()
+	rec := &recorder{handler: memory}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	ref := strings.TrimPrefix(server.URL, "http://") + "/shop/api:v1"
+	dir := writeLayout(t, ref)
+	client := newClient(staticKeychain{})
+
+	var out bytes.Buffer
+	digest, err := client.PushLayout(context.Background(), dir, ref, &out)
+	if err != nil {
+		t.Fatalf("PushLayout(): %v", err)
+	}
+
+	manifestData, stored, ok := memory.Manifest("shop/api", "v1")
+	if !ok {
+		t.Fatal("no manifest stored as shop/api:v1")
+	}
+	if stored != digest {
+		t.Errorf("PushLayout() = %s, the registry stored %s", digest, stored)
+	}
+	var manifest oci.Manifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	for _, blob := range append([]oci.Descriptor{manifest.Config}, manifest.Layers...) {
+		if _, ok := memory.Blob(blob.Digest); !ok {
+			t.Errorf("blob %s was not uploaded", blob.Digest)
+		}
+	}
+	// The config and the layer are each uploaded in a single PUT
+	if n := rec.count("PUT blobs/uploads"); n != 2 {
+		t.Errorf("%d blob uploads, want 2", n)
+	}
+	if n := rec.count("PATCH blobs/uploads"); n != 0 {
+		t.Errorf("%d chunked uploads, want a monolithic upload", n)
+	}
+
+	// The registry has every blob now, only the manifest is pushed again
+	out.Reset()
+	if _, err := client.PushLayout(context.Background(), dir, ref, &out); err != nil {
+		t.Fatalf("PushLayout() again: %v", err)
+	}
+	if n := strings.Count(out.String(), "already exists"); n != 2 {
+		t.Errorf("second push output:\n%s\nwant both blobs to already exist", out.String())
+	}
+	if n := rec.count("POST blobs/uploads"); n != 2 {
+		t.Errorf("%d uploads started over both pushes, want 2", n)
+	}
+	if n := rec.count("PUT manifests"); n != 2 {
+		t.Errorf("%d manifest pushes, want 2", n)
+	}
+}
+
+func TestPushLayoutBasicAuth(t *testing.T) {
+	memory := NewMemory()
+	memory.Username, memory.Password = "ci", "s3cr3t"
+	server := httptest.NewServer(memory)
+	defer server.Close()
+
+	ref := strings.TrimPrefix(server.URL, "http://") + "/shop/api:v1"
+	dir := writeLayout(t, ref)
+
+	tests := []struct {
+		name    string
+		creds   Credentials
+		wantErr string
+	}{
+		{name: "valid credentials", creds: Credentials{Username: "ci", Password: "s3cr3t"}},
+		{name: "no credentials", wantErr: "needs credentials"},
+		{name: "wrong password", creds: Credentials{Username: "ci", Password: "wrong"}, wantErr: "unauthorized"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newClient(staticKeychain(tt.creds)).PushLayout(context.Background(), dir, ref, nil)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("PushLayout(): %v", err)
+				}
+				if _, _, ok := memory.Manifest("shop/api", "v1"); !ok {
+					t.Error("no manifest stored as shop/api:v1")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("PushLayout() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if !errors.Is(err, errUnauthorized) {
+				t.Errorf("PushLayout() error = %v, want errUnauthorized", err)
+			}
+		})
+	}
+}
+
+func TestPushLayoutBearerToken(t *testing.T) {
+	memory := NewMemory()
+	var tokens int
+	var scope string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "ci" || password != "s3cr3t" {
+			http.Error(w, "denied", http.StatusUnauthorized)
+			return
+		}
+		tokens++
+		scope = r.URL.Query().Get("scope")
+		json.NewEncoder(w).Encode(map[string]string{"token": "t0k3n"})
+	})
+	var server *httptest.Server
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0k3n" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		memory.ServeHTTP(w, r)
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	ref := strings.TrimPrefix(server.URL, "http://") + "/shop/api:v1"
+	dir := writeLayout(t, ref)
+	client := newClient(staticKeychain{Username: "ci", Password: "s3cr3t"})
+
+	if _, err := client.PushLayout(context.Background(), dir, ref, nil); err != nil {
+		t.Fatalf("PushLayout(): %v", err)
+	}
+	if _, _, ok := memory.Manifest("shop/api", "v1"); !ok {
+		t.Error("no manifest stored as shop/api:v1")
+	}
+	// The token is reused for the following requests of the repository
+	if tokens != 1 {
+		t.Errorf("%d tokens requested, want 1", tokens)
+	}
+	if scope != "repository:shop/api:pull,push" {
+		t.Errorf("token scope = %q", scope)
+	}
+
+	denied := newClient(staticKeychain{Username: "ci", Password: "wrong"})
+	if _, err := denied.PushLayout(context.Background(), dir, ref, nil); err == nil || !errors.Is(err, errUnauthorized) {
+		t.Errorf("PushLayout() with a wrong password error = %v, want errUnauthorized", err)
+	}
+}
+
+// flaky fails the first failures requests of a kind with status, after reading their
+// body, then passes the requests to handler.
+type flaky struct {
+	handler  http.Handler
+	request  string
+	status   int
+	failures int
+
+	mu sync.Mutex
+}
+
+func (f *flaky) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	fail := r.Method+" "+pathKind(r.URL.Path) == f.request && f.failures > 0
+	if fail {
+		f.failures--
+	}
+	f.mu.Unlock()
+	if fail {
+		var body bytes.Buffer
+		body.ReadFrom(r.Body)
+		http.Error(w, "try again", f.status)
+		return
+	}
+	f.handler.ServeHTTP(w, r)
+}
+
+func TestPushLayoutRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		status   int
+		failures int
+		retries  int
+		wantErr  bool
+	}{
+		{name: "blob check", request: "HEAD blobs", status: http.StatusServiceUnavailable, failures: 2, retries: 3},
+		{name: "upload rate limited", request: "PUT blobs/uploads", status: http.StatusTooManyRequests, failures: 3, retries: 3},
+		{name: "manifest", request: "PUT manifests", status: http.StatusBadGateway, failures: 1, retries: 3},
+		{name: "out of retries", request: "PUT blobs/uploads", status: http.StatusInternalServerError, failures: 2, retries: 1, wantErr: true},
+		{name: "client errors are not retried", request: "PUT manifests", status: http.StatusBadRequest, failures: 1, retries: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := NewMemory()
+			server := httptest.NewServer(&flaky{handler: memory, request: tt.request, status: tt.status, failures: tt.failures})
+			defer server.Close()
+
+			ref := strings.TrimPrefix(server.URL, "http://") + "/shop/api:v1"
+			dir := writeLayout(t, ref)
+			client := newClient(staticKeychain{})
+			client.Retries = tt.retries
+
+			_, err := client.PushLayout(context.Background(), dir, ref, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("PushLayout() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PushLayout(): %v", err)
+			}
+			// The registry checks the digest of the uploads, so a retried upload sent the
+			// whole blob again
+			if _, _, ok := memory.Manifest("shop/api", "v1"); !ok {
+				t.Error("no manifest stored as shop/api:v1")
+			}
+		})
+	}
+}
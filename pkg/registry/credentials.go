@@ -0,0 +1,180 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Environment variables holding credentials for every registry, checked first.
+const (
+	EnvUsername = "DAAB_REGISTRY_USERNAME"
+	EnvPassword = "DAAB_REGISTRY_PASSWORD"
+)
+
+// Credentials authenticate to a registry. Empty credentials mean anonymous access.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Empty reports whether there are no credentials.
+func (c Credentials) Empty() bool {
+	return c.Username == "" && c.Password == ""
+}
+
+// Keychain returns the credentials of a registry host.
+type Keychain interface {
+	Resolve(host string) (Credentials, error)
+}
+
+// Keychains tries every keychain in order and returns the first credentials found.
+type Keychains []Keychain
+
+// Resolve returns the credentials of the first keychain that has some for host.
+func (k Keychains) Resolve(host string) (Credentials, error) {
+	for _, keychain := range k {
+		creds, err := keychain.Resolve(host)
+		if err != nil {
+			return Credentials{}, err
+		}
+		if !creds.Empty() {
+			return creds, nil
+		}
+	}
+	return Credentials{}, nil
+}
+
+// DefaultKeychain looks for credentials in $DAAB_REGISTRY_USERNAME and
+// $DAAB_REGISTRY_PASSWORD, then in the docker config.json and its credential helpers.
+func DefaultKeychain() Keychain {
+	return Keychains{EnvKeychain{}, &DockerConfig{}}
+}
+
+// EnvKeychain reads the credentials from $DAAB_REGISTRY_USERNAME and $DAAB_REGISTRY_PASSWORD,
+// for any registry.
+type EnvKeychain struct{}
+
+// Resolve returns the credentials of the environment, whatever the host.
+func (EnvKeychain) Resolve(host string) (Credentials, error) {
+	return Credentials{Username: os.Getenv(EnvUsername), Password: os.Getenv(EnvPassword)}, nil
+}
+
+// DockerConfig reads the credentials saved by 'docker login': the auths of config.json,
+// or the credential helper configured for the host (credHelpers) or for every host
+// (credsStore), e.g. docker-credential-ecr-login.
+type DockerConfig struct {
+	// Path of config.json, $DOCKER_CONFIG/config.json or ~/.docker/config.json when empty
+	Path string
+}
+
+type dockerConfigFile struct {
+	Auths map[string]struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"auths"`
+	CredHelpers map[string]string `json:"credHelpers"`
+	CredsStore  string            `json:"credsStore"`
+}
+
+func (d *DockerConfig) path() string {
+	if d.Path != "" {
+		return d.Path
+	}
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// Resolve asks the credential helper of host, else reads its auth in config.json, else
+// asks the credsStore.
+func (d *DockerConfig) Resolve(host string) (Credentials, error) {
+	path := d.path()
+	if path == "" {
+		return Credentials{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Credentials{}, nil
+	}
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to read docker config: %w", err)
+	}
+	var config dockerConfigFile
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Credentials{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if helper, ok := config.CredHelpers[host]; ok {
+		return credentialHelper(helper, host)
+	}
+
+	for key, auth := range config.Auths {
+		if normalizeHost(key) != normalizeHost(host) {
+			continue
+		}
+		if auth.Auth == "" {
+			return Credentials{Username: auth.Username, Password: auth.Password}, nil
+		}
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("invalid auth for %s in %s: %w", key, path, err)
+		}
+		username, password, _ := strings.Cut(string(decoded), ":")
+		return Credentials{Username: username, Password: password}, nil
+	}
+
+	if config.CredsStore != "" {
+		return credentialHelper(config.CredsStore, host)
+	}
+	return Credentials{}, nil
+}
+
+// normalizeHost strips the scheme and path of config.json keys, such as
+// "https://index.docker.io/v1/", and maps the Docker Hub aliases to DockerHub.
+func normalizeHost(key string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	host, _, _ = strings.Cut(host, "/")
+	switch host {
+	case "index.docker.io", "docker.io":
+		return DockerHub
+	}
+	return host
+}
+
+// credentialHelper runs docker-credential-<helper> get for host. A helper that knows no
+// credentials for host means anonymous access.
+func credentialHelper(helper, host string) (Credentials, error) {
+	binary := "docker-credential-" + helper
+	cmd := exec.Command(binary, "get")
+	cmd.Stdin = strings.NewReader(host)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(string(out)+stderr.String(), "credentials not found") {
+			return Credentials{}, nil
+		}
+		return Credentials{}, fmt.Errorf("%s failed for %s: %w %s", binary, host, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var response struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(out, &response); err != nil {
+		return Credentials{}, fmt.Errorf("invalid output of %s: %w", binary, err)
+	}
+	return Credentials{Username: response.Username, Password: response.Secret}, nil
+}
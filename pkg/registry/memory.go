@@ -0,0 +1,200 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/mouad4949/DAAB/pkg/oci"
+)
+
+// Memory is an in-process registry keeping blobs and manifests in memory. It implements
+// the parts of the distribution API used to push and pull, so pushes can be tested with
+// httptest.NewServer(registry.NewMemory()) and no network registry.
+type Memory struct {
+	// Username and Password, when set, are required with Basic authentication
+	Username string
+	Password string
+
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string]memoryManifest
+	uploads   map[string]*bytes.Buffer
+}
+
+type memoryManifest struct {
+	mediaType string
+	data      []byte
+}
+
+// NewMemory returns an empty registry that accepts anonymous pushes.
+func NewMemory() *Memory {
+	return &Memory{
+		blobs:     map[string][]byte{},
+		manifests: map[string]memoryManifest{},
+		uploads:   map[string]*bytes.Buffer{},
+	}
+}
+
+// Manifest returns the manifest stored as repository:reference, where reference is a tag
+// or a digest, and its digest.
+func (m *Memory) Manifest(repository, reference string) ([]byte, string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	manifest, ok := m.manifests[repository+"@"+reference]
+	if !ok {
+		return nil, "", false
+	}
+	return manifest.data, oci.Digest(manifest.data), true
+}
+
+// Blob returns the blob digest.
+func (m *Memory) Blob(digest string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.blobs[digest]
+	return data, ok
+}
+
+// ServeHTTP serves the blob, upload and manifest endpoints of the distribution API.
+func (m *Memory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if m.Username != "" || m.Password != "" {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != m.Username || password != m.Password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="daab"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	if path == r.URL.Path {
+		http.NotFound(w, r)
+		return
+	}
+	if path == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	switch {
+	case strings.Contains(path, "/blobs/uploads/"):
+		name, id, _ := cutLast(path, "/blobs/uploads/")
+		m.serveUpload(w, r, name, id)
+	case strings.Contains(path, "/blobs/"):
+		_, digest, _ := cutLast(path, "/blobs/")
+		m.serveBlob(w, r, digest)
+	case strings.Contains(path, "/manifests/"):
+		name, reference, _ := cutLast(path, "/manifests/")
+		m.serveManifest(w, r, name, reference)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	idx := strings.LastIndex(s, sep)
+	if idx < 0 {
+		return s, "", false
+	}
+	return s[:idx], s[idx+len(sep):], true
+}
+
+func (m *Memory) serveUpload(w http.ResponseWriter, r *http.Request, name, id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r.Method == http.MethodPost && id == "" {
+		random := make([]byte, 16)
+		rand.Read(random)
+		id = hex.EncodeToString(random)
+		m.uploads[id] = &bytes.Buffer{}
+		w.Header().Set("Location", "/v2/"+name+"/blobs/uploads/"+id)
+		w.Header().Set("Docker-Upload-UUID", id)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	upload, ok := m.uploads[id]
+	if !ok {
+		http.Error(w, "unknown upload", http.StatusNotFound)
+		return
+	}
+	if _, err := io.Copy(upload, r.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPatch:
+		w.Header().Set("Location", "/v2/"+name+"/blobs/uploads/"+id)
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodPut:
+		digest := r.URL.Query().Get("digest")
+		if oci.Digest(upload.Bytes()) != digest {
+			http.Error(w, fmt.Sprintf("digest mismatch, expected %s", digest), http.StatusBadRequest)
+			return
+		}
+		delete(m.uploads, id)
+		m.blobs[digest] = upload.Bytes()
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (m *Memory) serveBlob(w http.ResponseWriter, r *http.Request, digest string) {
+	data, ok := m.Blob(digest)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write(data)
+	}
+}
+
+func (m *Memory) serveManifest(w http.ResponseWriter, r *http.Request, name, reference string) {
+	if r.Method == http.MethodPut {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		digest := oci.Digest(data)
+		manifest := memoryManifest{mediaType: r.Header.Get("Content-Type"), data: data}
+
+		m.mu.Lock()
+		m.manifests[name+"@"+reference] = manifest
+		m.manifests[name+"@"+digest] = manifest
+		m.mu.Unlock()
+
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	m.mu.Lock()
+	manifest, ok := m.manifests[name+"@"+reference]
+	m.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", manifest.mediaType)
+	w.Header().Set("Content-Length", fmt.Sprint(len(manifest.data)))
+	w.Header().Set("Docker-Content-Digest", oci.Digest(manifest.data))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write(manifest.data)
+	}
+}
@@ -0,0 +1,72 @@
+// Package registry pushes images to container registries with the distribution (v2) HTTP
+// API. Credentials come from the environment, the docker config.json or docker
+// credential helpers. Memory is an in-process registry to push to without any network.
+package registry
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DockerHub is the host of images without a registry, e.g. "nginx:latest".
+const DockerHub = "registry-1.docker.io"
+
+// Reference is a parsed image reference, e.g. "ghcr.io/acme/api:v1".
+type Reference struct {
+	Host       string
+	Repository string
+	// Tag, or digest ("sha256:...") when the reference is pinned
+	Tag string
+}
+
+// ParseReference parses ref. The registry host is the first component when it looks like
+// a host (a '.', a ':' or "localhost"), Docker Hub otherwise; the tag defaults to "latest".
+func ParseReference(ref string) (Reference, error) {
+	if ref == "" {
+		return Reference{}, fmt.Errorf("empty image reference")
+	}
+
+	parsed := Reference{Host: DockerHub, Tag: "latest"}
+	name := ref
+	if at := strings.Index(name, "@"); at >= 0 {
+		parsed.Tag = name[at+1:]
+		name = name[:at]
+	} else if colon := strings.LastIndex(name, ":"); colon > strings.LastIndex(name, "/") {
+		parsed.Tag = name[colon+1:]
+		name = name[:colon]
+	}
+
+	if slash := strings.Index(name, "/"); slash >= 0 {
+		first := name[:slash]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			parsed.Host = first
+			name = name[slash+1:]
+		}
+	}
+	if parsed.Host == DockerHub && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	if name == "" || parsed.Tag == "" {
+		return Reference{}, fmt.Errorf("invalid image reference %q", ref)
+	}
+	parsed.Repository = name
+	return parsed, nil
+}
+
+// String returns the reference in its usual form.
+func (r Reference) String() string {
+	separator := ":"
+	if strings.HasPrefix(r.Tag, "sha256:") {
+		separator = "@"
+	}
+	return r.Host + "/" + r.Repository + separator + r.Tag
+}
+
+// isLocal reports whether host is this machine, where registries usually serve plain HTTP.
+func isLocal(host string) bool {
+	hostname := host
+	if idx := strings.LastIndex(host, ":"); idx >= 0 && !strings.HasSuffix(host, "]") {
+		hostname = host[:idx]
+	}
+	return hostname == "localhost" || hostname == "127.0.0.1" || hostname == "[::1]"
+}
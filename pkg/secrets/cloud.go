@@ -0,0 +1,137 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// The cloud providers talk to their secret stores through these narrow client
+// interfaces so that an SDK-backed client, or a FakeStore, can be plugged in.
+
+// AWSSecretsManagerClient returns the SecretString of a secret.
+type AWSSecretsManagerClient interface {
+	GetSecretValue(ctx context.Context, secretID string) (string, error)
+}
+
+// GCPSecretManagerClient returns the payload of a secret version
+// (projects/<project>/secrets/<secret>/versions/<version>).
+type GCPSecretManagerClient interface {
+	AccessSecretVersion(ctx context.Context, name string) (string, error)
+}
+
+// AzureKeyVaultClient returns the value of a secret in a vault. An empty version means latest.
+type AzureKeyVaultClient interface {
+	GetSecret(ctx context.Context, vault, name, version string) (string, error)
+}
+
+// AWSSecretsManagerProvider resolves secret://aws/<secret-id>[#key].
+type AWSSecretsManagerProvider struct {
+	client AWSSecretsManagerClient
+}
+
+// NewAWSSecretsManagerProvider returns a provider reading secrets through client, which
+// reports an error on every reference when nil.
+func NewAWSSecretsManagerProvider(client AWSSecretsManagerClient) *AWSSecretsManagerProvider {
+	return &AWSSecretsManagerProvider{
+		client: client,
+	}
+}
+
+// Name returns "aws".
+func (p *AWSSecretsManagerProvider) Name() string {
+	return "aws"
+}
+
+// Resolve reads the SecretString of the secret ref.Path.
+func (p *AWSSecretsManagerProvider) Resolve(ctx context.Context, ref Reference) (string, error) {
+	if p.client == nil {
+		return "", fmt.Errorf("AWS Secrets Manager client is not configured")
+	}
+	secret, err := p.client.GetSecretValue(ctx, ref.Path)
+	if err != nil {
+		return "", err
+	}
+	return selectKey(secret, ref.Key)
+}
+
+// GCPSecretManagerProvider resolves secret://gcp/<project>/<secret>[/<version>][#key].
+type GCPSecretManagerProvider struct {
+	client GCPSecretManagerClient
+}
+
+// NewGCPSecretManagerProvider returns a provider reading secrets through client, which
+// reports an error on every reference when nil.
+func NewGCPSecretManagerProvider(client GCPSecretManagerClient) *GCPSecretManagerProvider {
+	return &GCPSecretManagerProvider{
+		client: client,
+	}
+}
+
+// Name returns "gcp".
+func (p *GCPSecretManagerProvider) Name() string {
+	return "gcp"
+}
+
+// Resolve reads the payload of the secret version, the latest when ref.Path has none.
+func (p *GCPSecretManagerProvider) Resolve(ctx context.Context, ref Reference) (string, error) {
+	if p.client == nil {
+		return "", fmt.Errorf("GCP Secret Manager client is not configured")
+	}
+
+	parts := strings.Split(ref.Path, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", fmt.Errorf("expected secret://gcp/<project>/<secret>[/<version>]")
+	}
+	version := "latest"
+	if len(parts) == 3 {
+		version = parts[2]
+	}
+
+	name := fmt.Sprintf("projects/%s/secrets/%s/versions/%s", parts[0], parts[1], version)
+	secret, err := p.client.AccessSecretVersion(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return selectKey(secret, ref.Key)
+}
+
+// AzureKeyVaultProvider resolves secret://azure/<vault>/<name>[/<version>][#key].
+type AzureKeyVaultProvider struct {
+	client AzureKeyVaultClient
+}
+
+// NewAzureKeyVaultProvider returns a provider reading secrets through client, which
+// reports an error on every reference when nil.
+func NewAzureKeyVaultProvider(client AzureKeyVaultClient) *AzureKeyVaultProvider {
+	return &AzureKeyVaultProvider{
+		client: client,
+	}
+}
+
+// Name returns "azure".
+func (p *AzureKeyVaultProvider) Name() string {
+	return "azure"
+}
+
+// Resolve reads the secret from its vault, the latest version when ref.Path has none.
+func (p *AzureKeyVaultProvider) Resolve(ctx context.Context, ref Reference) (string, error) {
+	if p.client == nil {
+		return "", fmt.Errorf("Azure Key Vault client is not configured")
+	}
+
+	parts := strings.Split(ref.Path, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return "", fmt.Errorf("expected secret://azure/<vault>/<name>[/<version>]")
+	}
+	version := ""
+	if len(parts) == 3 {
+		version = parts[2]
+	}
+
+	secret, err := p.client.GetSecret(ctx, parts[0], parts[1], version)
+	if err != nil {
+		return "", err
+	}
+	return selectKey(secret, ref.Key)
+}
@@ -0,0 +1,68 @@
+package secrets
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCloudProviders(t *testing.T) {
+	store := NewFakeStore()
+	store.Set("prod/db", `{"username":"app","password":"aws-p4ss"}`)
+	store.Set("prod/token", "aws-t0k3n")
+	store.Set("projects/shop/secrets/db/versions/latest", "gcp-latest")
+	store.Set("projects/shop/secrets/db/versions/2", "gcp-v2")
+	store.Set("shop-vault/db", "azure-latest")
+	store.Set("shop-vault/db/abc", `{"password":"azure-abc"}`)
+
+	resolver := NewResolver(
+		NewAWSSecretsManagerProvider(store),
+		NewGCPSecretManagerProvider(store),
+		NewAzureKeyVaultProvider(store),
+	)
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "secret://aws/prod/token", want: "aws-t0k3n"},
+		{value: "secret://aws/prod/db#password", want: "aws-p4ss"},
+		{value: "secret://aws/prod/missing", wantErr: true},
+		{value: "secret://gcp/shop/db", want: "gcp-latest"},
+		{value: "secret://gcp/shop/db/2", want: "gcp-v2"},
+		{value: "secret://gcp/shop", wantErr: true},
+		{value: "secret://gcp/shop/db/2/extra", wantErr: true},
+		{value: "secret://azure/shop-vault/db", want: "azure-latest"},
+		{value: "secret://azure/shop-vault/db/abc#password", want: "azure-abc"},
+		{value: "secret://azure/shop-vault", wantErr: true},
+		{value: "secret://azure/other-vault/db", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := resolver.Resolve(context.Background(), tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Resolve(%q) = %q, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q): %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloudProvidersWithoutClient(t *testing.T) {
+	resolver := NewDefaultResolver(t.TempDir())
+
+	for _, value := range []string{"secret://aws/prod/db", "secret://gcp/shop/db", "secret://azure/shop-vault/db"} {
+		if _, err := resolver.Resolve(context.Background(), value); err == nil {
+			t.Errorf("Resolve(%q) succeeded without a client, want an error", value)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// EnvProvider resolves secret://env/<NAME> from the process environment.
+type EnvProvider struct {
+	lookup func(string) (string, bool)
+}
+
+// NewEnvProvider returns a provider reading the environment of the process.
+func NewEnvProvider() *EnvProvider {
+	return &EnvProvider{
+		lookup: os.LookupEnv,
+	}
+}
+
+// Name returns "env".
+func (p *EnvProvider) Name() string {
+	return "env"
+}
+
+// Resolve returns the value of the environment variable ref.Path.
+func (p *EnvProvider) Resolve(ctx context.Context, ref Reference) (string, error) {
+	value, ok := p.lookup(ref.Path)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref.Path)
+	}
+	return selectKey(value, ref.Key)
+}
+
+// selectKey extracts key from a JSON object secret, or returns secret when key is empty.
+func selectKey(secret, key string) (string, error) {
+	if key == "" {
+		return secret, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object, cannot select key %q", key)
+	}
+	value, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found in secret", key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
@@ -0,0 +1,56 @@
+package secrets
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEnvProvider(t *testing.T) {
+	provider := &EnvProvider{lookup: mapLookup(map[string]string{
+		"DB_PASSWORD": "p4ss",
+		"DB":          `{"user":"app","port":5432}`,
+	})}
+
+	tests := []struct {
+		ref     Reference
+		want    string
+		wantErr bool
+	}{
+		{ref: Reference{Provider: "env", Path: "DB_PASSWORD"}, want: "p4ss"},
+		{ref: Reference{Provider: "env", Path: "DB", Key: "user"}, want: "app"},
+		{ref: Reference{Provider: "env", Path: "DB", Key: "port"}, want: "5432"},
+		{ref: Reference{Provider: "env", Path: "DB", Key: "password"}, wantErr: true},
+		{ref: Reference{Provider: "env", Path: "DB_PASSWORD", Key: "user"}, wantErr: true},
+		{ref: Reference{Provider: "env", Path: "MISSING"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref.String(), func(t *testing.T) {
+			got, err := provider.Resolve(context.Background(), tt.ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Resolve() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(): %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvProviderReadsTheEnvironment(t *testing.T) {
+	t.Setenv("DAAB_TEST_SECRET", "from-env")
+
+	got, err := NewResolver(NewEnvProvider()).Resolve(context.Background(), "secret://env/DAAB_TEST_SECRET")
+	if err != nil {
+		t.Fatalf("Resolve(): %v", err)
+	}
+	if got != "from-env" {
+		t.Errorf("Resolve() = %q, want from-env", got)
+	}
+}
@@ -0,0 +1,58 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FakeStore is an in-memory secret store implementing the AWS, GCP and Azure client
+// interfaces, for exercising the cloud providers without network access.
+type FakeStore struct {
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+// NewFakeStore returns an empty store.
+func NewFakeStore() *FakeStore {
+	return &FakeStore{
+		secrets: map[string]string{},
+	}
+}
+
+// Set stores a secret under name. Names use the client specific format:
+// the secret id for AWS, the full version name for GCP and <vault>/<name>[/<version>] for Azure.
+func (s *FakeStore) Set(name, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[name] = value
+}
+
+func (s *FakeStore) get(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("secret %s not found", name)
+	}
+	return value, nil
+}
+
+// GetSecretValue implements AWSSecretsManagerClient.
+func (s *FakeStore) GetSecretValue(ctx context.Context, secretID string) (string, error) {
+	return s.get(secretID)
+}
+
+// AccessSecretVersion implements GCPSecretManagerClient.
+func (s *FakeStore) AccessSecretVersion(ctx context.Context, name string) (string, error) {
+	return s.get(name)
+}
+
+// GetSecret implements AzureKeyVaultClient.
+func (s *FakeStore) GetSecret(ctx context.Context, vault, name, version string) (string, error) {
+	key := vault + "/" + name
+	if version != "" {
+		key += "/" + version
+	}
+	return s.get(key)
+}
@@ -0,0 +1,285 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultSecretsFile returns the encrypted secrets file used by the file provider,
+// overridable with DAAB_SECRETS_FILE.
+func DefaultSecretsFile(projectPath string) string {
+	if path := os.Getenv("DAAB_SECRETS_FILE"); path != "" {
+		return path
+	}
+	return filepath.Join(projectPath, ".init", "secrets.enc.yaml")
+}
+
+// FileProvider resolves secret://file/<key>[/<nested key>...] from a local encrypted file.
+// Two formats are supported:
+//   - a YAML/JSON document encrypted as a whole with age (binary or armored)
+//   - a SOPS YAML/JSON document whose values are encrypted with an age recipient
+//
+// Identities are read from DAAB_AGE_KEY_FILE, SOPS_AGE_KEY_FILE, SOPS_AGE_KEY or
+// the SOPS default ~/.config/sops/age/keys.txt.
+type FileProvider struct {
+	path string
+
+	once   sync.Once
+	values map[string]interface{}
+	err    error
+}
+
+// NewFileProvider returns a provider reading the encrypted file at path. The file is
+// decrypted once, on the first reference.
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{
+		path: path,
+	}
+}
+
+// Name returns "file".
+func (p *FileProvider) Name() string {
+	return "file"
+}
+
+// Resolve returns the value at ref.Path, a slash-separated list of keys.
+func (p *FileProvider) Resolve(ctx context.Context, ref Reference) (string, error) {
+	p.once.Do(func() {
+		p.values, p.err = p.load()
+	})
+	if p.err != nil {
+		return "", p.err
+	}
+
+	var current interface{} = p.values
+	for _, part := range strings.Split(ref.Path, "/") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("secret %s not found in %s", ref.Path, p.path)
+		}
+		if current, ok = m[part]; !ok {
+			return "", fmt.Errorf("secret %s not found in %s", ref.Path, p.path)
+		}
+	}
+
+	switch value := current.(type) {
+	case string:
+		return selectKey(value, ref.Key)
+	case map[string]interface{}:
+		if ref.Key == "" {
+			return "", fmt.Errorf("secret %s is a map, select a key with #<key>", ref.Path)
+		}
+		if v, ok := value[ref.Key]; ok {
+			return fmt.Sprint(v), nil
+		}
+		return "", fmt.Errorf("key %q not found in secret %s", ref.Key, ref.Path)
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+func (p *FileProvider) load() (map[string]interface{}, error) {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+
+	if isAgeEncrypted(data) {
+		identities, err := loadAgeIdentities()
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := ageDecrypt(data, identities)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", p.path, err)
+		}
+		values := map[string]interface{}{}
+		if err := yaml.Unmarshal(plaintext, &values); err != nil {
+			return nil, fmt.Errorf("failed to parse decrypted %s: %w", p.path, err)
+		}
+		return values, nil
+	}
+
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", p.path, err)
+	}
+	if _, ok := values["sops"]; !ok {
+		return nil, fmt.Errorf("%s is not encrypted: encrypt it with age or sops", p.path)
+	}
+
+	identities, err := loadAgeIdentities()
+	if err != nil {
+		return nil, err
+	}
+	return decryptSops(values, identities)
+}
+
+/******************************************************/
+/************age**************************************/
+/****************************************************/
+
+func isAgeEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("age-encryption.org/v1")) ||
+		bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header))
+}
+
+func ageDecrypt(data []byte, identities []age.Identity) ([]byte, error) {
+	var src io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func loadAgeIdentities() ([]age.Identity, error) {
+	if key := os.Getenv("SOPS_AGE_KEY"); key != "" {
+		return age.ParseIdentities(strings.NewReader(key))
+	}
+
+	candidates := []string{os.Getenv("DAAB_AGE_KEY_FILE"), os.Getenv("SOPS_AGE_KEY_FILE")}
+	if configDir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(configDir, "sops", "age", "keys.txt"))
+	}
+
+	for _, path := range candidates {
+		if path == "" {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+		return age.ParseIdentities(f)
+	}
+
+	return nil, fmt.Errorf("no age identity found: set DAAB_AGE_KEY_FILE, SOPS_AGE_KEY_FILE or SOPS_AGE_KEY")
+}
+
+/******************************************************/
+/************sops*************************************/
+/****************************************************/
+
+var sopsValuePattern = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.*),tag:(.*),type:(.*)\]$`)
+
+// decryptSops decrypts the values of a SOPS document. The data key is recovered from the
+// age recipients in the sops metadata, each value is AES-256-GCM with its key path as
+// additional data. The document MAC is not verified.
+func decryptSops(doc map[string]interface{}, identities []age.Identity) (map[string]interface{}, error) {
+	metadata, _ := doc["sops"].(map[string]interface{})
+	recipients, _ := metadata["age"].([]interface{})
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("sops file has no age recipients")
+	}
+
+	var dataKey []byte
+	for _, recipient := range recipients {
+		entry, _ := recipient.(map[string]interface{})
+		enc, _ := entry["enc"].(string)
+		if enc == "" {
+			continue
+		}
+		key, err := ageDecrypt([]byte(enc), identities)
+		if err == nil {
+			dataKey = key
+			break
+		}
+	}
+	if dataKey == nil {
+		return nil, fmt.Errorf("none of the age identities can decrypt the sops data key")
+	}
+
+	delete(doc, "sops")
+	decrypted, err := decryptSopsBranch(doc, nil, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return decrypted.(map[string]interface{}), nil
+}
+
+func decryptSopsBranch(value interface{}, path []string, key []byte) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			childPath := append(append([]string{}, path...), k)
+			decrypted, err := decryptSopsBranch(child, childPath, key)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = decrypted
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			decrypted, err := decryptSopsBranch(child, path, key)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = decrypted
+		}
+		return out, nil
+	case string:
+		if !strings.HasPrefix(v, "ENC[") {
+			return v, nil
+		}
+		return decryptSopsValue(v, strings.Join(path, ":")+":", key)
+	default:
+		return v, nil
+	}
+}
+
+func decryptSopsValue(value, additionalData string, key []byte) (string, error) {
+	match := sopsValuePattern.FindStringSubmatch(value)
+	if match == nil {
+		return "", fmt.Errorf("invalid sops value at %s", strings.TrimSuffix(additionalData, ":"))
+	}
+
+	data, err := base64.StdEncoding.DecodeString(match[1])
+	if err != nil {
+		return "", err
+	}
+	iv, err := base64.StdEncoding.DecodeString(match[2])
+	if err != nil {
+		return "", err
+	}
+	tag, err := base64.StdEncoding.DecodeString(match[3])
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt sops value at %s: %w", strings.TrimSuffix(additionalData, ":"), err)
+	}
+	return string(plaintext), nil
+}
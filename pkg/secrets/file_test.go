@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The fixtures in testdata are encrypted for the identity in testdata/age.key.
+
+// useIdentity makes keyFile the only age identity the file provider finds.
+func useIdentity(t *testing.T, keyFile string) {
+	t.Helper()
+	t.Setenv("SOPS_AGE_KEY", "")
+	t.Setenv("SOPS_AGE_KEY_FILE", "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("DAAB_AGE_KEY_FILE", keyFile)
+}
+
+func TestFileProvider(t *testing.T) {
+	useIdentity(t, filepath.Join("testdata", "age.key"))
+
+	tests := []struct {
+		name  string
+		file  string
+		value string
+		want  string
+	}{
+		{name: "age", file: "secrets.age", value: "secret://file/database/password", want: "s3cr3t"},
+		{name: "age map key", file: "secrets.age", value: "secret://file/database#url", want: "postgres://app@db:5432/app"},
+		{name: "age JSON key", file: "secrets.age", value: "secret://file/api_token#token", want: "abc123"},
+		{name: "sops", file: "secrets.sops.yaml", value: "secret://file/database/password", want: "s3cr3t"},
+		{name: "sops top level", file: "secrets.sops.yaml", value: "secret://file/api_token", want: "abc123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewResolver(NewFileProvider(filepath.Join("testdata", tt.file)))
+			got, err := resolver.Resolve(context.Background(), tt.value)
+			if err != nil {
+				t.Fatalf("Resolve(%q): %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileProviderErrors(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.yaml")
+	if err := os.WriteFile(plain, []byte("password: p4ss\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	otherKey := filepath.Join(dir, "other.key")
+	// An identity the fixtures are not encrypted for
+	if err := os.WriteFile(otherKey, []byte("AGE-SECRET-KEY-1GFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPYYSJZGFPQ4EGAEX\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		keyFile string
+		file    string
+		value   string
+		wantErr string
+	}{
+		{name: "missing secret", keyFile: filepath.Join("testdata", "age.key"), file: filepath.Join("testdata", "secrets.age"), value: "secret://file/database/user", wantErr: "not found"},
+		{name: "map without key", keyFile: filepath.Join("testdata", "age.key"), file: filepath.Join("testdata", "secrets.age"), value: "secret://file/database", wantErr: "select a key"},
+		{name: "wrong identity", keyFile: otherKey, file: filepath.Join("testdata", "secrets.age"), value: "secret://file/database/password", wantErr: "failed to decrypt"},
+		{name: "wrong sops identity", keyFile: otherKey, file: filepath.Join("testdata", "secrets.sops.yaml"), value: "secret://file/api_token", wantErr: "can decrypt the sops data key"},
+		{name: "no identity", keyFile: filepath.Join(dir, "missing.key"), file: filepath.Join("testdata", "secrets.age"), value: "secret://file/api_token", wantErr: "no age identity"},
+		{name: "not encrypted", keyFile: filepath.Join("testdata", "age.key"), file: plain, value: "secret://file/password", wantErr: "is not encrypted"},
+		{name: "missing file", keyFile: filepath.Join("testdata", "age.key"), file: filepath.Join(dir, "missing.yaml"), value: "secret://file/password", wantErr: "failed to read"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useIdentity(t, tt.keyFile)
+			resolver := NewResolver(NewFileProvider(tt.file))
+			_, err := resolver.Resolve(context.Background(), tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Resolve(%q) error = %v, want it to contain %q", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultSecretsFile(t *testing.T) {
+	t.Setenv("DAAB_SECRETS_FILE", "")
+	if got, want := DefaultSecretsFile("project"), filepath.Join("project", ".init", "secrets.enc.yaml"); got != want {
+		t.Errorf("DefaultSecretsFile() = %q, want %q", got, want)
+	}
+
+	t.Setenv("DAAB_SECRETS_FILE", "/etc/daab/secrets.age")
+	if got := DefaultSecretsFile("project"); got != "/etc/daab/secrets.age" {
+		t.Errorf("DefaultSecretsFile() = %q, want the DAAB_SECRETS_FILE override", got)
+	}
+}
@@ -0,0 +1,227 @@
+// Package secrets resolves the secret:// references of a DAAB configuration through
+// pluggable providers: environment variables, a local file encrypted with age or sops,
+// and the secret stores of AWS, GCP and Azure.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Scheme is the prefix that marks a config value as a secret reference,
+// e.g. secret://env/DB_PASSWORD or secret://aws/prod/db#password.
+const Scheme = "secret://"
+
+// SecretProvider resolves secret references for one backend.
+type SecretProvider interface {
+	// Name is the provider part of a reference (env, file, aws, gcp, azure).
+	Name() string
+	Resolve(ctx context.Context, ref Reference) (string, error)
+}
+
+// Reference is a parsed secret:// value.
+type Reference struct {
+	Provider string
+	Path     string
+	// Key selects a field when the secret holds a JSON object (the part after '#').
+	Key string
+}
+
+// String returns the reference in its secret:// form.
+func (r Reference) String() string {
+	s := Scheme + r.Provider + "/" + r.Path
+	if r.Key != "" {
+		s += "#" + r.Key
+	}
+	return s
+}
+
+// IsReference reports whether value is a secret:// reference.
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, Scheme)
+}
+
+// ParseReference parses secret://<provider>/<path>[#key].
+func ParseReference(value string) (Reference, error) {
+	if !IsReference(value) {
+		return Reference{}, fmt.Errorf("not a secret reference: %s", value)
+	}
+
+	rest := strings.TrimPrefix(value, Scheme)
+	ref := Reference{}
+	if idx := strings.LastIndex(rest, "#"); idx >= 0 {
+		ref.Key = rest[idx+1:]
+		rest = rest[:idx]
+	}
+
+	provider, path, ok := strings.Cut(rest, "/")
+	if !ok || provider == "" || path == "" {
+		return Reference{}, fmt.Errorf("invalid secret reference %q: expected secret://<provider>/<path>", value)
+	}
+	ref.Provider = provider
+	ref.Path = path
+	return ref, nil
+}
+
+// Resolver dispatches secret references to the registered providers.
+type Resolver struct {
+	providers map[string]SecretProvider
+}
+
+// NewResolver returns a resolver dispatching to providers.
+func NewResolver(providers ...SecretProvider) *Resolver {
+	r := &Resolver{
+		providers: map[string]SecretProvider{},
+	}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+// NewDefaultResolver registers the built-in providers. The cloud providers have no
+// client configured and report an error until one is set with Register.
+func NewDefaultResolver(projectPath string) *Resolver {
+	return NewResolver(
+		NewEnvProvider(),
+		NewFileProvider(DefaultSecretsFile(projectPath)),
+		NewAWSSecretsManagerProvider(nil),
+		NewGCPSecretManagerProvider(nil),
+		NewAzureKeyVaultProvider(nil),
+	)
+}
+
+// Register adds or replaces the provider handling p.Name().
+func (r *Resolver) Register(p SecretProvider) {
+	r.providers[p.Name()] = p
+}
+
+// Resolve returns value unchanged unless it is a secret reference.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+
+	ref, err := ParseReference(value)
+	if err != nil {
+		return "", err
+	}
+
+	provider, ok := r.providers[ref.Provider]
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider %q in %s", ref.Provider, value)
+	}
+
+	secret, err := provider.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", value, err)
+	}
+	return secret, nil
+}
+
+// ResolveAll replaces every secret reference found in the strings of v (a pointer to a
+// config struct) with its resolved value.
+func (r *Resolver) ResolveAll(ctx context.Context, v interface{}) error {
+	return walkStrings(reflect.ValueOf(v), "", func(_ string, value string) (string, error) {
+		return r.Resolve(ctx, value)
+	})
+}
+
+// FindReferences returns the secret references in v keyed by their yaml path.
+func FindReferences(v interface{}) map[string]string {
+	refs := map[string]string{}
+	_ = walkStrings(reflect.ValueOf(v), "", func(path string, value string) (string, error) {
+		if IsReference(value) {
+			refs[path] = value
+		}
+		return value, nil
+	})
+	return refs
+}
+
+// SortedPaths returns the keys of refs in a stable order for printing.
+func SortedPaths(refs map[string]string) []string {
+	paths := make([]string, 0, len(refs))
+	for path := range refs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// walkStrings calls fn on every string reachable from v and stores the returned value.
+func walkStrings(v reflect.Value, path string, fn func(path, value string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Interface {
+			// Values held in interfaces are not addressable, so copy, update and store back
+			elem := reflect.New(v.Elem().Type()).Elem()
+			elem.Set(v.Elem())
+			if err := walkStrings(elem, path, fn); err != nil {
+				return err
+			}
+			if v.CanSet() {
+				v.Set(elem)
+			}
+			return nil
+		}
+		return walkStrings(v.Elem(), path, fn)
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			fieldPath := joinPath(path, name)
+			if field.Anonymous || name == "" {
+				fieldPath = path
+			}
+			if err := walkStrings(v.Field(i), fieldPath, fn); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkStrings(v.Index(i), fmt.Sprintf("%s[%d]", path, i), fn); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			if err := walkStrings(elem, joinPath(path, fmt.Sprint(key.Interface())), fn); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+
+	case reflect.String:
+		resolved, err := fn(path, v.String())
+		if err != nil {
+			return err
+		}
+		if v.CanSet() && resolved != v.String() {
+			v.SetString(resolved)
+		}
+	}
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
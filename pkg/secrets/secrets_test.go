@@ -0,0 +1,145 @@
+package secrets
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Reference
+		wantErr bool
+	}{
+		{value: "secret://env/DB_PASSWORD", want: Reference{Provider: "env", Path: "DB_PASSWORD"}},
+		{value: "secret://aws/prod/db#password", want: Reference{Provider: "aws", Path: "prod/db", Key: "password"}},
+		{value: "secret://gcp/project/db/3", want: Reference{Provider: "gcp", Path: "project/db/3"}},
+		{value: "secret://env", wantErr: true},
+		{value: "secret:///path", wantErr: true},
+		{value: "env/DB_PASSWORD", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseReference(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseReference(%q) = %+v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseReference(%q): %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseReference(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+			if got.String() != tt.value {
+				t.Errorf("String() = %q, want %q", got.String(), tt.value)
+			}
+		})
+	}
+}
+
+func TestResolverResolve(t *testing.T) {
+	resolver := NewResolver(&EnvProvider{lookup: mapLookup(map[string]string{"TOKEN": "t0k3n"})})
+	ctx := context.Background()
+
+	if got, err := resolver.Resolve(ctx, "plain value"); err != nil || got != "plain value" {
+		t.Errorf("Resolve(plain value) = %q, %v; want the value unchanged", got, err)
+	}
+	if got, err := resolver.Resolve(ctx, "secret://env/TOKEN"); err != nil || got != "t0k3n" {
+		t.Errorf("Resolve(secret://env/TOKEN) = %q, %v; want t0k3n", got, err)
+	}
+	if _, err := resolver.Resolve(ctx, "secret://vault/TOKEN"); err == nil || !strings.Contains(err.Error(), "unknown secret provider") {
+		t.Errorf("Resolve(secret://vault/TOKEN) error = %v, want an unknown provider error", err)
+	}
+	if _, err := resolver.Resolve(ctx, "secret://env/MISSING"); err == nil || !strings.Contains(err.Error(), "secret://env/MISSING") {
+		t.Errorf("Resolve(secret://env/MISSING) error = %v, want an error naming the reference", err)
+	}
+}
+
+type testConfig struct {
+	Name     string            `yaml:"name"`
+	Password string            `yaml:"password"`
+	Labels   map[string]string `yaml:"labels"`
+	Nested   *testNested       `yaml:"nested"`
+	Hosts    []string          `yaml:"hosts"`
+	Extra    interface{}       `yaml:"extra"`
+}
+
+type testNested struct {
+	Token string `yaml:"token"`
+}
+
+func newTestConfig() *testConfig {
+	return &testConfig{
+		Name:     "api",
+		Password: "secret://env/PASSWORD",
+		Labels:   map[string]string{"team": "core", "key": "secret://env/LABEL"},
+		Nested:   &testNested{Token: "secret://env/TOKEN"},
+		Hosts:    []string{"a.example.com", "secret://env/HOST"},
+		Extra:    "secret://env/TOKEN",
+	}
+}
+
+func TestResolveAll(t *testing.T) {
+	resolver := NewResolver(&EnvProvider{lookup: mapLookup(map[string]string{
+		"PASSWORD": "p4ss",
+		"LABEL":    "l4bel",
+		"TOKEN":    "t0k3n",
+		"HOST":     "b.example.com",
+	})})
+
+	cfg := newTestConfig()
+	if err := resolver.ResolveAll(context.Background(), cfg); err != nil {
+		t.Fatalf("ResolveAll: %v", err)
+	}
+
+	want := &testConfig{
+		Name:     "api",
+		Password: "p4ss",
+		Labels:   map[string]string{"team": "core", "key": "l4bel"},
+		Nested:   &testNested{Token: "t0k3n"},
+		Hosts:    []string{"a.example.com", "b.example.com"},
+		Extra:    "t0k3n",
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("ResolveAll() = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestResolveAllStopsAtFirstError(t *testing.T) {
+	resolver := NewResolver(&EnvProvider{lookup: mapLookup(nil)})
+	if err := resolver.ResolveAll(context.Background(), newTestConfig()); err == nil {
+		t.Fatal("ResolveAll() succeeded with unset variables, want an error")
+	}
+}
+
+func TestFindReferences(t *testing.T) {
+	got := FindReferences(newTestConfig())
+	want := map[string]string{
+		"password":     "secret://env/PASSWORD",
+		"labels.key":   "secret://env/LABEL",
+		"nested.token": "secret://env/TOKEN",
+		"hosts[1]":     "secret://env/HOST",
+		"extra":        "secret://env/TOKEN",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindReferences() = %v, want %v", got, want)
+	}
+
+	paths := SortedPaths(got)
+	if !reflect.DeepEqual(paths, []string{"extra", "hosts[1]", "labels.key", "nested.token", "password"}) {
+		t.Errorf("SortedPaths() = %v", paths)
+	}
+}
+
+func mapLookup(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}